@@ -10,10 +10,14 @@ import (
 
 	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/auth"
 	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/config"
+	metricsdomain "github.com/asian-code/myapp-kubernetes/services/api-service/internal/domain/metrics"
+	oauthdomain "github.com/asian-code/myapp-kubernetes/services/api-service/internal/domain/oauth"
+	userdomain "github.com/asian-code/myapp-kubernetes/services/api-service/internal/domain/user"
 	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/handler"
-	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/oauth"
 	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/repository"
-	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/user"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/logging"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/middleware"
 	"github.com/asian-code/myapp-kubernetes/services/shared/database"
 	"github.com/asian-code/myapp-kubernetes/services/shared/logger"
 	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
@@ -46,46 +50,57 @@ func main() {
 	log.Info("Database connection established")
 
 	// Initialize repository
-	repo := repository.New(db, log)
+	repo := repository.New(db, log).WithLowWearThreshold(time.Duration(cfg.LowWearHours) * time.Hour)
 
 	// Initialize metrics
 	m := metrics.New("api-service")
 
-	// Create handlers
-	h := handler.New(repo, log, m, cfg.JWTSecret)
-	userHandler := user.NewHandler(db, cfg.JWTSecret, log)
-	oauthHandler := oauth.NewHandler(db, oauth.Config{
-		ClientID:     cfg.OuraClientID,
-		ClientSecret: cfg.OuraClientSecret,
-		RedirectURI:  cfg.OuraRedirectURI,
-	}, log)
+	// Create domain services
+	serviceLogger := logging.New(log)
+	userService := userdomain.NewService(repo, cfg.JWTSecret, serviceLogger)
+	oauthService := oauthdomain.NewService(repo, cfg.OuraClientID, cfg.OuraClientSecret, cfg.OuraRedirectURI, serviceLogger)
+	metricsService := metricsdomain.NewService(repo, serviceLogger)
+
+	// Create handler
+	h := handler.New(userService, oauthService, metricsService, log, m, cfg.JWTSecret)
 
 	// Setup router
 	router := mux.NewRouter()
+	router.Use(middleware.ErrorHandler(log))
+	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestLogger(log))
 
 	// Public routes (no authentication required)
 	router.HandleFunc("/health", h.Health).Methods("GET")
 	router.HandleFunc("/metrics", h.PrometheusMetrics).Methods("GET")
 
 	// Auth routes
-	router.HandleFunc("/api/register", userHandler.Register).Methods("POST")
-	router.HandleFunc("/api/login", userHandler.Login).Methods("POST")
+	router.HandleFunc("/api/register", h.Instrument("/register", h.Register)).Methods("POST")
+	router.HandleFunc("/api/login", h.Instrument("/login", h.Login)).Methods("POST")
 
 	// OAuth routes (require authentication to initiate)
-	router.Handle("/api/oauth/authorize", auth.AuthMiddleware(cfg.JWTSecret)(http.HandlerFunc(oauthHandler.Authorize))).Methods("GET")
-	router.HandleFunc("/api/callback", oauthHandler.Callback).Methods("GET")
-	router.HandleFunc("/oauth/success", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("OAuth authorization successful! You can close this window."))
-	}).Methods("GET")
+	router.Handle("/api/oauth/authorize", auth.AuthMiddleware(cfg.JWTSecret)(h.Instrument("/oauth/authorize", h.Authorize))).Methods("GET")
+	router.HandleFunc("/api/callback", h.Instrument("/callback", h.Callback)).Methods("GET")
+	router.HandleFunc("/oauth/success", h.OAuthSuccess).Methods("GET")
 
 	// Protected API routes (require JWT authentication)
 	api := router.PathPrefix("/api/v1").Subrouter()
 	api.Use(auth.AuthMiddleware(cfg.JWTSecret))
-	api.HandleFunc("/me", userHandler.Me).Methods("GET")
-	api.HandleFunc("/dashboard", h.Dashboard).Methods("GET")
-	api.HandleFunc("/sleep", h.GetSleep).Methods("GET")
-	api.HandleFunc("/activity", h.GetActivity).Methods("GET")
-	api.HandleFunc("/readiness", h.GetReadiness).Methods("GET")
+	api.HandleFunc("/me", h.Instrument("/me", h.Me)).Methods("GET")
+	api.HandleFunc("/dashboard", h.Instrument("/dashboard", h.Dashboard)).Methods("GET")
+	for _, t := range ingest.Records() {
+		path := "/" + t.Name
+		api.HandleFunc(path, h.Instrument(path, h.GetHistory(t.Name))).Methods("GET")
+		api.HandleFunc(path+"/{id}", h.Instrument(path+"/{id}", h.GetRecord(t.Name))).Methods("GET")
+	}
+	for _, t := range ingest.Trends() {
+		path := "/trends/" + t.Name
+		api.HandleFunc(path, h.Instrument(path, h.GetTrend(t.Name))).Methods("GET")
+	}
+	api.HandleFunc("/sleep/{day}/periods", h.Instrument("/sleep/{day}/periods", h.GetSleepPeriods)).Methods("GET")
+	api.HandleFunc("/activity/{day}/timeline", h.Instrument("/activity/{day}/timeline", h.GetActivityTimeline)).Methods("GET")
+	api.HandleFunc("/readiness/{day}/breakdown", h.Instrument("/readiness/{day}/breakdown", h.GetReadinessBreakdown)).Methods("GET")
+	api.HandleFunc("/heartrate", h.Instrument("/heartrate", h.GetHeartRate)).Methods("GET")
 
 	// Setup CORS
 	c := cors.New(cors.Options{
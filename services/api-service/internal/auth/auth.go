@@ -45,6 +45,12 @@ func ValidateToken(tokenString, secret string) (*Claims, error) {
 	return nil, errors.New("invalid token")
 }
 
+// UserIDFromContext returns the authenticated user ID injected by AuthMiddleware
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value("user_id").(string)
+	return userID, ok && userID != ""
+}
+
 // AuthMiddleware validates JWT tokens and injects user_id into context
 func AuthMiddleware(secret string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"testing"
 )
 
@@ -42,3 +43,15 @@ func TestValidateTokenInvalid(t *testing.T) {
 		t.Error("Expected error for invalid token")
 	}
 }
+
+func TestUserIDFromContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user_id", "test-user")
+	userID, ok := UserIDFromContext(ctx)
+	if !ok || userID != "test-user" {
+		t.Errorf("Expected userID test-user, got %q (ok=%v)", userID, ok)
+	}
+
+	if _, ok := UserIDFromContext(context.Background()); ok {
+		t.Error("Expected no user ID in empty context")
+	}
+}
@@ -20,12 +20,15 @@ type Config struct {
 	OuraClientID     string `validate:"required"`
 	OuraClientSecret string `validate:"required"`
 	OuraRedirectURI  string `validate:"required,url"`
+	// LowWearHours is the non-wear time beyond which a day is low confidence
+	LowWearHours int `validate:"required,min=1,max=24"`
 }
 
 // Load loads and validates configuration from environment variables
 func Load() *Config {
 	dbPort, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
 	dbMaxConns, _ := strconv.Atoi(getEnv("DB_MAX_CONNS", "10"))
+	lowWearHours, _ := strconv.Atoi(getEnv("LOW_WEAR_HOURS", "6"))
 
 	cfg := &Config{
 		DBHost:           getEnv("DB_HOST", "localhost"),
@@ -40,6 +43,7 @@ func Load() *Config {
 		OuraClientID:     os.Getenv("OURA_CLIENT_ID"),
 		OuraClientSecret: os.Getenv("OURA_CLIENT_SECRET"),
 		OuraRedirectURI:  getEnv("OURA_REDIRECT_URI", "https://myhealth.eric-n.com/api/callback"),
+		LowWearHours:     lowWearHours,
 	}
 
 	// Validate configuration and panic if invalid
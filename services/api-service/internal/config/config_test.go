@@ -39,6 +39,10 @@ func TestLoad_Success(t *testing.T) {
 	if cfg.LogLevel != "info" {
 		t.Errorf("expected LogLevel to be info, got %s", cfg.LogLevel)
 	}
+
+	if cfg.LowWearHours != 6 {
+		t.Errorf("expected LowWearHours to be 6, got %d", cfg.LowWearHours)
+	}
 }
 
 func TestLoad_MissingRequiredField(t *testing.T) {
@@ -63,6 +67,7 @@ func TestLoad_CustomValues(t *testing.T) {
 	os.Setenv("DB_NAME", "custom_db")
 	os.Setenv("DB_SSLMODE", "disable")
 	os.Setenv("LOG_LEVEL", "debug")
+	os.Setenv("LOW_WEAR_HOURS", "4")
 	os.Setenv("OURA_CLIENT_ID", "custom_client")
 	os.Setenv("OURA_CLIENT_SECRET", "custom_secret")
 	os.Setenv("JWT_SECRET", "custom_jwt_secret_12345678901234567890")
@@ -92,4 +97,8 @@ func TestLoad_CustomValues(t *testing.T) {
 	if cfg.DBSSLMode != "disable" {
 		t.Errorf("expected DBSSLMode to be disable, got %s", cfg.DBSSLMode)
 	}
+
+	if cfg.LowWearHours != 4 {
+		t.Errorf("expected LowWearHours to be 4, got %d", cfg.LowWearHours)
+	}
 }
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"math"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+)
+
+// downsample reduces samples, which must be sorted by time, to threshold
+// points with the largest-triangle-three-buckets algorithm. It keeps the
+// first and last sample and, from each bucket in between, the sample forming
+// the largest triangle with the previously kept sample and the average of
+// the next bucket, which preserves the peaks and troughs a chart needs.
+// Samples are returned unchanged if there are no more than threshold.
+func downsample(samples []*interfaces.HeartRateSample, threshold int) []*interfaces.HeartRateSample {
+	n := len(samples)
+	if threshold >= n || threshold < 3 {
+		return samples
+	}
+
+	origin := samples[0].Timestamp
+	x := func(i int) float64 { return samples[i].Timestamp.Sub(origin).Seconds() }
+	y := func(i int) float64 { return float64(samples[i].BPM) }
+
+	sampled := make([]*interfaces.HeartRateSample, 0, threshold)
+	sampled = append(sampled, samples[0])
+
+	// Buckets exclude the first and last sample, which are always kept
+	every := float64(n-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// Average of the next bucket, or the last sample for the final one
+		nextStart := int(math.Floor(float64(i+1)*every)) + 1
+		nextEnd := int(math.Floor(float64(i+2)*every)) + 1
+		if nextEnd > n {
+			nextEnd = n
+		}
+		var avgX, avgY float64
+		for j := nextStart; j < nextEnd; j++ {
+			avgX += x(j)
+			avgY += y(j)
+		}
+		avgX /= float64(nextEnd - nextStart)
+		avgY /= float64(nextEnd - nextStart)
+
+		// Pick the point in this bucket with the largest triangle
+		start := int(math.Floor(float64(i)*every)) + 1
+		end := int(math.Floor(float64(i+1)*every)) + 1
+		ax, ay := x(a), y(a)
+		maxArea, next := -1.0, start
+		for j := start; j < end; j++ {
+			area := math.Abs((ax-avgX)*(y(j)-ay)-(ax-x(j))*(avgY-ay)) / 2
+			if area > maxArea {
+				maxArea, next = area, j
+			}
+		}
+
+		sampled = append(sampled, samples[next])
+		a = next
+	}
+
+	return append(sampled, samples[n-1])
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func heartRateSeries(bpms ...int) []*interfaces.HeartRateSample {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := make([]*interfaces.HeartRateSample, len(bpms))
+	for i, bpm := range bpms {
+		samples[i] = &interfaces.HeartRateSample{Timestamp: start.Add(time.Duration(i) * time.Minute), BPM: bpm}
+	}
+	return samples
+}
+
+func TestDownsample_KeepsEndpointsAndPeaks(t *testing.T) {
+	samples := heartRateSeries(60, 61, 60, 62, 140, 61, 45, 59, 61, 60, 60, 62)
+
+	got := downsample(samples, 4)
+
+	assert.Len(t, got, 4)
+	assert.Equal(t, samples[0], got[0])
+	assert.Equal(t, samples[len(samples)-1], got[3])
+	// The spike and the dip each dominate their bucket
+	assert.Equal(t, 140, got[1].BPM)
+	assert.Equal(t, 45, got[2].BPM)
+}
+
+func TestDownsample_ReturnsShortSeriesUnchanged(t *testing.T) {
+	samples := heartRateSeries(60, 61, 62)
+
+	assert.Equal(t, samples, downsample(samples, 10))
+	assert.Equal(t, samples, downsample(samples, 3))
+	assert.Empty(t, downsample(nil, 10))
+}
+
+func TestDownsample_KeepsOrder(t *testing.T) {
+	var bpms []int
+	for i := 0; i < 1000; i++ {
+		bpms = append(bpms, 50+(i*37)%90)
+	}
+
+	got := downsample(heartRateSeries(bpms...), 100)
+
+	assert.Len(t, got, 100)
+	for i := 1; i < len(got); i++ {
+		assert.True(t, got[i].Timestamp.After(got[i-1].Timestamp), "sample %d out of order", i)
+	}
+}
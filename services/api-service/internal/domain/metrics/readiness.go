@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"sort"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+)
+
+// readinessBreakdown explains a readiness record's score: its contributors,
+// weakest first with unscored ones last, and the temperature deviations
+func readinessBreakdown(record *interfaces.MetricDTO) *interfaces.ReadinessBreakdownDTO {
+	breakdown := &interfaces.ReadinessBreakdownDTO{
+		OuraID:        record.OuraID,
+		Day:           record.Day,
+		Contributors:  make([]interfaces.ReadinessContributorDTO, 0, len(ingest.ReadinessContributorNames)),
+		RestMode:      record.RestMode,
+		LowConfidence: record.LowConfidence,
+	}
+	if score, ok := number(record.Values["score"]); ok {
+		breakdown.Score = int(score)
+	}
+
+	for _, name := range ingest.ReadinessContributorNames {
+		contributor := interfaces.ReadinessContributorDTO{Name: name}
+		if score, ok := number(record.Values[name]); ok {
+			s := int(score)
+			contributor.Score = &s
+		}
+		breakdown.Contributors = append(breakdown.Contributors, contributor)
+	}
+	sort.SliceStable(breakdown.Contributors, func(i, j int) bool {
+		a, b := breakdown.Contributors[i].Score, breakdown.Contributors[j].Score
+		return a != nil && (b == nil || *a < *b)
+	})
+	if first := breakdown.Contributors; len(first) > 0 && first[0].Score != nil {
+		breakdown.Weakest = first[0].Name
+	}
+
+	if v, ok := number(record.Values["temperature_deviation"]); ok {
+		breakdown.TemperatureDeviation = &v
+	}
+	if v, ok := number(record.Values["temperature_trend_deviation"]); ok {
+		breakdown.TemperatureTrendDeviation = &v
+	}
+	return breakdown
+}
+
+// number converts a numeric column read back from the database
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -2,11 +2,14 @@ package metrics
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
 	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
-	"github.com/asian-code/myapp-kubernetes/services/pkg/validation"
 )
 
 type service struct {
@@ -22,355 +25,520 @@ func NewService(repo interfaces.MetricsRepository, logger interfaces.Logger) int
 	}
 }
 
-// IngestSleepData validates and saves sleep metrics to the database
-func (s *service) IngestSleepData(ctx context.Context, userID string, data *interfaces.SleepDataDTO) error {
+// IngestMetric validates a payload against the current schema of its metric
+// type and saves it to the database
+func (s *service) IngestMetric(ctx context.Context, userID, metricType string, payload json.RawMessage) error {
 	if userID == "" {
 		return errors.New(errors.ErrCodeBadRequest, "user ID is required")
 	}
 
-	// Validate input
-	if err := validation.Validate(data); err != nil {
-		return errors.Wrap(err, errors.ErrCodeBadRequest, "invalid sleep data")
-	}
-
-	// Additional business validation
-	if data.Score < 0 || data.Score > 100 {
-		return errors.New(errors.ErrCodeBadRequest, "sleep score must be between 0 and 100")
+	// Validate input with the same schema the data-processor ingests
+	env := &ingest.Envelope{Type: metricType, SchemaVersion: ingest.CurrentVersion, UserID: userID, Payload: payload}
+	decoded, err := env.DecodePayload()
+	if err != nil {
+		return err
 	}
-
-	if data.Duration < 0 {
-		return errors.New(errors.ErrCodeBadRequest, "sleep duration cannot be negative")
+	t, _ := ingest.Lookup(metricType)
+	if t.Series {
+		return errors.New(errors.ErrCodeBadRequest, fmt.Sprintf("%s is a time series and is ingested by the data-processor", metricType))
 	}
 
-	// Convert DTO to entity
-	metric := &interfaces.SleepMetric{
-		UserID:   userID,
-		OuraID:   data.OuraID,
-		Day:      data.Day,
-		Score:    data.Score,
-		Duration: data.Duration,
+	// Convert payload to entity
+	ouraID, day := decoded.Document()
+	metric := &interfaces.Metric{
+		UserID: userID,
+		OuraID: ouraID,
+		Values: t.Row(decoded),
 	}
+	metric.Day, _ = time.Parse("2006-01-02", day)
 
 	// Save to database
-	if err := s.repo.SaveSleepMetric(ctx, metric); err != nil {
-		s.logger.Error("Failed to save sleep metric", err, map[string]interface{}{
+	if err := s.repo.SaveMetric(ctx, metricType, metric); err != nil {
+		s.logger.Error("Failed to save metric", err, map[string]interface{}{
 			"user_id": userID,
-			"day":     data.Day,
+			"type":    metricType,
+			"day":     day,
 		})
-		return errors.Wrap(err, errors.ErrCodeInternal, "failed to save sleep data")
+		return errors.Wrap(err, errors.ErrCodeInternal, fmt.Sprintf("failed to save %s data", metricType))
 	}
 
-	s.logger.Info("Sleep metric ingested successfully", map[string]interface{}{
+	s.logger.Info("Metric ingested successfully", map[string]interface{}{
 		"user_id": userID,
-		"day":     data.Day,
-		"score":   data.Score,
+		"type":    metricType,
+		"day":     day,
 	})
 
 	return nil
 }
 
-// IngestActivityData validates and saves activity metrics to the database
-func (s *service) IngestActivityData(ctx context.Context, userID string, data *interfaces.ActivityDataDTO) error {
-	if userID == "" {
-		return errors.New(errors.ErrCodeBadRequest, "user ID is required")
+// GetHistory retrieves one metric type's records for a date range
+func (s *service) GetHistory(ctx context.Context, userID, metricType string, startDate, endDate time.Time) ([]*interfaces.MetricDTO, error) {
+	if err := validateHistory(userID, metricType, startDate, endDate); err != nil {
+		return nil, err
 	}
 
-	// Validate input
-	if err := validation.Validate(data); err != nil {
-		return errors.Wrap(err, errors.ErrCodeBadRequest, "invalid activity data")
+	metrics, err := s.repo.GetMetrics(ctx, metricType, userID, startDate, endDate)
+	if err != nil {
+		s.logger.Error("Failed to retrieve metrics", err, map[string]interface{}{
+			"user_id":    userID,
+			"type":       metricType,
+			"start_date": startDate,
+			"end_date":   endDate,
+		})
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, fmt.Sprintf("failed to retrieve %s history", metricType))
 	}
 
-	// Additional business validation
-	if data.Score < 0 || data.Score > 100 {
-		return errors.New(errors.ErrCodeBadRequest, "activity score must be between 0 and 100")
-	}
+	return metricDTOs(metrics), nil
+}
 
-	if data.ActiveCalories < 0 || data.Steps < 0 {
-		return errors.New(errors.ErrCodeBadRequest, "calories and steps cannot be negative")
+// GetTaggedHistory retrieves the records of a metric type that a tag
+// affected, or with filter.Without those it did not
+func (s *service) GetTaggedHistory(ctx context.Context, userID, metricType string, startDate, endDate time.Time, filter interfaces.TagFilter) ([]*interfaces.MetricDTO, error) {
+	if err := validateHistory(userID, metricType, startDate, endDate); err != nil {
+		return nil, err
 	}
 
-	if data.MediumActivityMin < 0 || data.HighActivityMin < 0 {
-		return errors.New(errors.ErrCodeBadRequest, "activity minutes cannot be negative")
+	if _, ok := ingest.TagLags[metricType]; !ok {
+		return nil, errors.New(errors.ErrCodeBadRequest, fmt.Sprintf("%s history cannot be filtered by tag", metricType))
 	}
 
-	// Convert DTO to entity
-	metric := &interfaces.ActivityMetric{
-		UserID:            userID,
-		OuraID:            data.OuraID,
-		Day:               data.Day,
-		Score:             data.Score,
-		ActiveCalories:    data.ActiveCalories,
-		Steps:             data.Steps,
-		MediumActivityMin: data.MediumActivityMin,
-		HighActivityMin:   data.HighActivityMin,
+	if filter.Tag == "" {
+		return nil, errors.New(errors.ErrCodeBadRequest, "tag is required")
 	}
 
-	// Save to database
-	if err := s.repo.SaveActivityMetric(ctx, metric); err != nil {
-		s.logger.Error("Failed to save activity metric", err, map[string]interface{}{
-			"user_id": userID,
-			"day":     data.Day,
+	metrics, err := s.repo.GetMetricsByTag(ctx, metricType, userID, startDate, endDate, filter)
+	if err != nil {
+		s.logger.Error("Failed to retrieve tagged metrics", err, map[string]interface{}{
+			"user_id":    userID,
+			"type":       metricType,
+			"tag":        filter.Tag,
+			"without":    filter.Without,
+			"start_date": startDate,
+			"end_date":   endDate,
 		})
-		return errors.Wrap(err, errors.ErrCodeInternal, "failed to save activity data")
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, fmt.Sprintf("failed to retrieve %s history", metricType))
 	}
 
-	s.logger.Info("Activity metric ingested successfully", map[string]interface{}{
-		"user_id": userID,
-		"day":     data.Day,
-		"score":   data.Score,
-		"steps":   data.Steps,
-	})
-
-	return nil
+	return metricDTOs(metrics), nil
 }
 
-// IngestReadinessData validates and saves readiness metrics to the database
-func (s *service) IngestReadinessData(ctx context.Context, userID string, data *interfaces.ReadinessDataDTO) error {
+// validateHistory checks a history request for a user, type and date range
+func validateHistory(userID, metricType string, startDate, endDate time.Time) error {
 	if userID == "" {
 		return errors.New(errors.ErrCodeBadRequest, "user ID is required")
 	}
 
-	// Validate input
-	if err := validation.Validate(data); err != nil {
-		return errors.Wrap(err, errors.ErrCodeBadRequest, "invalid readiness data")
+	if t, ok := ingest.Lookup(metricType); !ok || t.Series {
+		return errors.New(errors.ErrCodeBadRequest, fmt.Sprintf("unknown metric type %q", metricType))
 	}
 
-	// Additional business validation
-	if data.Score < 0 || data.Score > 100 {
-		return errors.New(errors.ErrCodeBadRequest, "readiness score must be between 0 and 100")
+	if endDate.Before(startDate) {
+		return errors.New(errors.ErrCodeBadRequest, "end date must be after start date")
 	}
 
-	// Convert DTO to entity
-	metric := &interfaces.ReadinessMetric{
-		UserID: userID,
-		OuraID: data.OuraID,
-		Day:    data.Day,
-		Score:  data.Score,
+	// Limit date range to prevent excessive queries
+	if endDate.Sub(startDate) > 365*24*time.Hour {
+		return errors.New(errors.ErrCodeBadRequest, "date range cannot exceed 365 days")
 	}
 
-	// Save to database
-	if err := s.repo.SaveReadinessMetric(ctx, metric); err != nil {
-		s.logger.Error("Failed to save readiness metric", err, map[string]interface{}{
+	return nil
+}
+
+// metricDTOs converts metric entities to DTOs
+func metricDTOs(metrics []*interfaces.Metric) []*interfaces.MetricDTO {
+	dtos := make([]*interfaces.MetricDTO, 0, len(metrics))
+	for _, m := range metrics {
+		dtos = append(dtos, metricDTO(m))
+	}
+	return dtos
+}
+
+func metricDTO(m *interfaces.Metric) *interfaces.MetricDTO {
+	return &interfaces.MetricDTO{
+		OuraID:        m.OuraID,
+		Day:           m.Day,
+		Values:        m.Values,
+		RestMode:      m.RestMode,
+		LowConfidence: m.LowConfidence,
+	}
+}
+
+// GetRecord retrieves one of the user's records by its Oura ID. Records
+// spanning a period, such as workouts, that do not carry their own heart rate
+// summary get one from the heart rate series over that period.
+func (s *service) GetRecord(ctx context.Context, userID, metricType, ouraID string) (*interfaces.MetricDTO, error) {
+	if userID == "" {
+		return nil, errors.New(errors.ErrCodeBadRequest, "user ID is required")
+	}
+
+	if t, ok := ingest.Lookup(metricType); !ok || t.Series {
+		return nil, errors.New(errors.ErrCodeBadRequest, fmt.Sprintf("unknown metric type %q", metricType))
+	}
+
+	m, err := s.repo.GetMetricByOuraID(ctx, metricType, userID, ouraID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve record", err, map[string]interface{}{
 			"user_id": userID,
-			"day":     data.Day,
+			"type":    metricType,
+			"oura_id": ouraID,
 		})
-		return errors.Wrap(err, errors.ErrCodeInternal, "failed to save readiness data")
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, fmt.Sprintf("failed to retrieve %s", metricType))
+	}
+	if m == nil {
+		return nil, errors.NotFound(fmt.Sprintf("%s %q not found", metricType, ouraID))
 	}
 
-	s.logger.Info("Readiness metric ingested successfully", map[string]interface{}{
-		"user_id": userID,
-		"day":     data.Day,
-		"score":   data.Score,
-	})
+	dto := metricDTO(m)
 
-	return nil
+	start, hasStart := m.Values["start_datetime"].(time.Time)
+	end, hasEnd := m.Values["end_datetime"].(time.Time)
+	if _, summarised := m.Values["heart_rate_average"]; hasStart && hasEnd && !summarised {
+		summary, err := s.heartRateSummary(ctx, userID, start, end)
+		if err != nil {
+			// Log but still return the record
+			s.logger.Warn("Failed to summarise heart rate", map[string]interface{}{
+				"user_id": userID,
+				"type":    metricType,
+				"oura_id": ouraID,
+				"error":   err.Error(),
+			})
+		} else if summary != nil {
+			dto.Values["heart_rate"] = summary
+		}
+	}
+
+	return dto, nil
 }
 
-// GetSleepHistory retrieves sleep metrics for a date range
-func (s *service) GetSleepHistory(ctx context.Context, userID string, startDate, endDate time.Time) ([]*interfaces.SleepDataDTO, error) {
+// heartRateSummary summarises the heart rate samples taken in [start, end),
+// or returns nil if there are none
+func (s *service) heartRateSummary(ctx context.Context, userID string, start, end time.Time) (*interfaces.HeartRateSummaryDTO, error) {
+	samples, err := s.repo.GetHeartRate(ctx, userID, start, end)
+	if err != nil || len(samples) == 0 {
+		return nil, err
+	}
+
+	summary := &interfaces.HeartRateSummaryDTO{Min: samples[0].BPM, Max: samples[0].BPM, Samples: len(samples)}
+	var sum int
+	for _, sample := range samples {
+		sum += sample.BPM
+		summary.RestMode = summary.RestMode || sample.RestMode
+		summary.LowConfidence = summary.LowConfidence || sample.LowConfidence
+		if sample.BPM < summary.Min {
+			summary.Min = sample.BPM
+		}
+		if sample.BPM > summary.Max {
+			summary.Max = sample.BPM
+		}
+	}
+	summary.Average = float64(sum) / float64(len(samples))
+	return summary, nil
+}
+
+// GetDashboard retrieves aggregated dashboard data for the specified number of days.
+// Days in rest mode or with low ring wear are left out of the summary unless
+// includeFlagged is set
+func (s *service) GetDashboard(ctx context.Context, userID string, days int, includeFlagged bool) (*interfaces.DashboardDTO, error) {
 	if userID == "" {
 		return nil, errors.New(errors.ErrCodeBadRequest, "user ID is required")
 	}
 
-	if endDate.Before(startDate) {
-		return nil, errors.New(errors.ErrCodeBadRequest, "end date must be after start date")
+	if days <= 0 {
+		return nil, errors.New(errors.ErrCodeBadRequest, "days must be greater than 0")
 	}
 
-	// Limit date range to prevent excessive queries
-	if endDate.Sub(startDate) > 365*24*time.Hour {
-		return nil, errors.New(errors.ErrCodeBadRequest, "date range cannot exceed 365 days")
+	// Limit to prevent excessive queries
+	if days > 365 {
+		return nil, errors.New(errors.ErrCodeBadRequest, "days cannot exceed 365")
 	}
 
-	metrics, err := s.repo.GetSleepMetrics(ctx, userID, startDate, endDate)
+	// Get aggregated summary
+	summary, err := s.repo.GetDashboardSummary(ctx, userID, days, includeFlagged)
 	if err != nil {
-		s.logger.Error("Failed to retrieve sleep metrics", err, map[string]interface{}{
-			"user_id":    userID,
-			"start_date": startDate,
-			"end_date":   endDate,
+		s.logger.Error("Failed to retrieve dashboard summary", err, map[string]interface{}{
+			"user_id": userID,
+			"days":    days,
 		})
-		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to retrieve sleep history")
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to retrieve dashboard data")
 	}
 
-	// Convert entities to DTOs
-	dtos := make([]*interfaces.SleepDataDTO, 0, len(metrics))
-	for _, m := range metrics {
-		dtos = append(dtos, &interfaces.SleepDataDTO{
-			OuraID:   m.OuraID,
-			Day:      m.Day,
-			Score:    m.Score,
-			Duration: m.Duration,
-		})
+	// Calculate date range for recent metrics
+	endDate := time.Now().UTC()
+
+	// Get recent metrics (last 7 days regardless of request)
+	recentDays := 7
+	if days < 7 {
+		recentDays = days
 	}
+	recentStartDate := endDate.AddDate(0, 0, -recentDays)
 
-	return dtos, nil
+	// Fetch recent data in parallel would be ideal, but keeping it simple for now
+	recent := make(map[string][]*interfaces.MetricDTO)
+	logFields := map[string]interface{}{
+		"user_id": userID,
+		"days":    days,
+	}
+	for _, t := range ingest.Records() {
+		metricType := t.Name
+		records, err := s.GetHistory(ctx, userID, metricType, recentStartDate, endDate)
+		if err != nil {
+			// Log but don't fail the entire request
+			s.logger.Warn("Failed to retrieve recent data for dashboard", map[string]interface{}{
+				"user_id": userID,
+				"type":    metricType,
+				"error":   err.Error(),
+			})
+			records = []*interfaces.MetricDTO{}
+		}
+		recent[metricType] = records
+		logFields["recent_"+metricType] = len(records)
+	}
+
+	// Build dashboard response
+	dashboard := &interfaces.DashboardDTO{
+		Summary: &interfaces.DashboardSummaryDTO{
+			TotalDays:         summary.TotalDays,
+			AvgSleepScore:     summary.AvgSleepScore,
+			AvgActivityScore:  summary.AvgActivityScore,
+			AvgReadinessScore: summary.AvgReadinessScore,
+			TotalSteps:        summary.TotalSteps,
+			AvgSleepDuration:  summary.AvgSleepDuration,
+
+			AvgSpO2:                      summary.AvgSpO2,
+			AvgBreathingDisturbanceIndex: summary.AvgBreathingDisturbanceIndex,
+			AvgStressHigh:                summary.AvgStressHigh,
+			AvgRecoveryHigh:              summary.AvgRecoveryHigh,
+			AvgResilienceSleepRecovery:   summary.AvgResilienceSleepRecovery,
+			AvgResilienceDaytimeRecovery: summary.AvgResilienceDaytimeRecovery,
+			AvgResilienceStress:          summary.AvgResilienceStress,
+
+			WeakestReadinessContributor:      summary.WeakestReadinessContributor,
+			WeakestReadinessContributorScore: summary.WeakestReadinessContributorScore,
+
+			ExcludedDays: summary.ExcludedDays,
+		},
+		Recent: recent,
+	}
+
+	s.logger.Info("Dashboard data retrieved successfully", logFields)
+
+	return dashboard, nil
 }
 
-// GetActivityHistory retrieves activity metrics for a date range
-func (s *service) GetActivityHistory(ctx context.Context, userID string, startDate, endDate time.Time) ([]*interfaces.ActivityDataDTO, error) {
+// GetSleepPeriods retrieves the sleep periods that ended on day, in the order
+// they started, each with its decoded hypnogram
+func (s *service) GetSleepPeriods(ctx context.Context, userID string, day time.Time) ([]*interfaces.SleepPeriodDTO, error) {
 	if userID == "" {
 		return nil, errors.New(errors.ErrCodeBadRequest, "user ID is required")
 	}
 
-	if endDate.Before(startDate) {
-		return nil, errors.New(errors.ErrCodeBadRequest, "end date must be after start date")
-	}
-
-	// Limit date range to prevent excessive queries
-	if endDate.Sub(startDate) > 365*24*time.Hour {
-		return nil, errors.New(errors.ErrCodeBadRequest, "date range cannot exceed 365 days")
+	periods, err := s.GetHistory(ctx, userID, ingest.TypeSleepPeriod, day, day)
+	if err != nil {
+		return nil, err
 	}
 
-	metrics, err := s.repo.GetActivityMetrics(ctx, userID, startDate, endDate)
-	if err != nil {
-		s.logger.Error("Failed to retrieve activity metrics", err, map[string]interface{}{
-			"user_id":    userID,
-			"start_date": startDate,
-			"end_date":   endDate,
-		})
-		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to retrieve activity history")
+	bedtime := func(p *interfaces.MetricDTO, col string) time.Time {
+		t, _ := p.Values[col].(time.Time)
+		return t
 	}
+	sort.SliceStable(periods, func(i, j int) bool {
+		return bedtime(periods[i], "bedtime_start").Before(bedtime(periods[j], "bedtime_start"))
+	})
 
-	// Convert entities to DTOs
-	dtos := make([]*interfaces.ActivityDataDTO, 0, len(metrics))
-	for _, m := range metrics {
-		dtos = append(dtos, &interfaces.ActivityDataDTO{
-			OuraID:            m.OuraID,
-			Day:               m.Day,
-			Score:             m.Score,
-			ActiveCalories:    m.ActiveCalories,
-			Steps:             m.Steps,
-			MediumActivityMin: m.MediumActivityMin,
-			HighActivityMin:   m.HighActivityMin,
+	dtos := make([]*interfaces.SleepPeriodDTO, 0, len(periods))
+	for _, p := range periods {
+		phases, _ := p.Values["sleep_phase_5_min"].(string)
+		dtos = append(dtos, &interfaces.SleepPeriodDTO{
+			MetricDTO: p,
+			Hypnogram: decodeHypnogram(phases, bedtime(p, "bedtime_start"), bedtime(p, "bedtime_end")),
 		})
 	}
 
 	return dtos, nil
 }
 
-// GetReadinessHistory retrieves readiness metrics for a date range
-func (s *service) GetReadinessHistory(ctx context.Context, userID string, startDate, endDate time.Time) ([]*interfaces.ReadinessDataDTO, error) {
+// GetActivityTimeline retrieves the activity recorded for day with its
+// class_5_min intensities decoded into a timeline and its MET readings
+// timestamped. Days ingested without them have an empty timeline.
+func (s *service) GetActivityTimeline(ctx context.Context, userID string, day time.Time) (*interfaces.ActivityTimelineDTO, error) {
 	if userID == "" {
 		return nil, errors.New(errors.ErrCodeBadRequest, "user ID is required")
 	}
 
-	if endDate.Before(startDate) {
-		return nil, errors.New(errors.ErrCodeBadRequest, "end date must be after start date")
+	records, err := s.GetHistory(ctx, userID, ingest.TypeActivity, day, day)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.NotFound(fmt.Sprintf("no activity recorded on %s", day.Format("2006-01-02")))
 	}
+	record := records[0]
 
-	// Limit date range to prevent excessive queries
-	if endDate.Sub(startDate) > 365*24*time.Hour {
-		return nil, errors.New(errors.ErrCodeBadRequest, "date range cannot exceed 365 days")
+	timeline := &interfaces.ActivityTimelineDTO{
+		OuraID:        record.OuraID,
+		Day:           record.Day,
+		Intensity:     []interfaces.IntensitySegment{},
+		MET:           []interfaces.METSample{},
+		RestMode:      record.RestMode,
+		LowConfidence: record.LowConfidence,
+	}
+	if start, ok := record.Values["day_start"].(time.Time); ok {
+		timeline.Start = &start
+		classes, _ := record.Values["class_5_min"].(string)
+		timeline.Intensity = decodeIntensity(classes, start)
 	}
 
-	metrics, err := s.repo.GetReadinessMetrics(ctx, userID, startDate, endDate)
-	if err != nil {
-		s.logger.Error("Failed to retrieve readiness metrics", err, map[string]interface{}{
-			"user_id":    userID,
-			"start_date": startDate,
-			"end_date":   endDate,
+	metStart, _ := record.Values["met_timestamp"].(time.Time)
+	interval, _ := record.Values["met_interval"].(float64)
+	for i, met := range floats(record.Values["met_items"]) {
+		timeline.MET = append(timeline.MET, interfaces.METSample{
+			Timestamp: metStart.Add(time.Duration(float64(i) * interval * float64(time.Second))),
+			MET:       met,
 		})
-		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to retrieve readiness history")
 	}
 
-	// Convert entities to DTOs
-	dtos := make([]*interfaces.ReadinessDataDTO, 0, len(metrics))
-	for _, m := range metrics {
-		dtos = append(dtos, &interfaces.ReadinessDataDTO{
-			OuraID: m.OuraID,
-			Day:    m.Day,
-			Score:  m.Score,
-		})
+	return timeline, nil
+}
+
+// GetReadinessBreakdown explains the readiness score of one day
+func (s *service) GetReadinessBreakdown(ctx context.Context, userID string, day time.Time) (*interfaces.ReadinessBreakdownDTO, error) {
+	if userID == "" {
+		return nil, errors.New(errors.ErrCodeBadRequest, "user ID is required")
 	}
 
-	return dtos, nil
+	records, err := s.GetHistory(ctx, userID, ingest.TypeReadiness, day, day)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.NotFound(fmt.Sprintf("no readiness recorded on %s", day.Format("2006-01-02")))
+	}
+
+	return readinessBreakdown(records[0]), nil
 }
 
-// GetDashboard retrieves aggregated dashboard data for the specified number of days
-func (s *service) GetDashboard(ctx context.Context, userID string, days int) (*interfaces.DashboardDTO, error) {
+// maxTrendMonths bounds how far back a trend reaches
+const maxTrendMonths = 120
+
+// GetTrend summarises a trend type by month over the last months calendar
+// months, including the current one, with each month's change since the
+// first
+func (s *service) GetTrend(ctx context.Context, userID, metricType string, months int) (*interfaces.TrendDTO, error) {
 	if userID == "" {
 		return nil, errors.New(errors.ErrCodeBadRequest, "user ID is required")
 	}
 
-	if days <= 0 {
-		return nil, errors.New(errors.ErrCodeBadRequest, "days must be greater than 0")
+	if t, ok := ingest.Lookup(metricType); !ok || t.Trend == "" {
+		return nil, errors.New(errors.ErrCodeBadRequest, fmt.Sprintf("metric type %q has no trend", metricType))
 	}
 
-	// Limit to prevent excessive queries
-	if days > 365 {
-		return nil, errors.New(errors.ErrCodeBadRequest, "days cannot exceed 365")
+	if months < 1 || months > maxTrendMonths {
+		return nil, errors.New(errors.ErrCodeBadRequest, fmt.Sprintf("months must be between 1 and %d", maxTrendMonths))
 	}
 
-	// Get aggregated summary
-	summary, err := s.repo.GetDashboardSummary(ctx, userID, days)
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	start := time.Date(end.Year(), end.Month()-time.Month(months-1), 1, 0, 0, 0, 0, time.UTC)
+
+	aggregates, err := s.repo.GetMonthlyTrend(ctx, metricType, userID, start, end)
 	if err != nil {
-		s.logger.Error("Failed to retrieve dashboard summary", err, map[string]interface{}{
+		s.logger.Error("Failed to retrieve trend", err, map[string]interface{}{
 			"user_id": userID,
-			"days":    days,
+			"type":    metricType,
+			"months":  months,
 		})
-		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to retrieve dashboard data")
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to retrieve trend")
 	}
 
-	// Calculate date range for recent metrics
-	endDate := time.Now().UTC()
-
-	// Get recent metrics (last 7 days regardless of request)
-	recentDays := 7
-	if days < 7 {
-		recentDays = days
+	trend := &interfaces.TrendDTO{
+		Type:   metricType,
+		Months: make([]interfaces.TrendMonthDTO, 0, len(aggregates)),
+	}
+	if len(aggregates) == 0 {
+		return trend, nil
 	}
-	recentStartDate := endDate.AddDate(0, 0, -recentDays)
 
-	// Fetch recent data in parallel would be ideal, but keeping it simple for now
-	recentSleep, err := s.GetSleepHistory(ctx, userID, recentStartDate, endDate)
-	if err != nil {
-		// Log but don't fail the entire request
-		s.logger.Warn("Failed to retrieve recent sleep data for dashboard", map[string]interface{}{
-			"user_id": userID,
-			"error":   err.Error(),
+	baseline := aggregates[0].Average
+	for _, a := range aggregates {
+		trend.Months = append(trend.Months, interfaces.TrendMonthDTO{
+			Month:               a.Month.Format("2006-01"),
+			Average:             a.Average,
+			Min:                 a.Min,
+			Max:                 a.Max,
+			Samples:             a.Samples,
+			ChangeSinceBaseline: a.Average - baseline,
+			RestMode:            a.RestMode,
+			LowConfidence:       a.LowConfidence,
 		})
-		recentSleep = []*interfaces.SleepDataDTO{}
 	}
+	change := aggregates[len(aggregates)-1].Average - baseline
+	trend.Baseline, trend.Change = &baseline, &change
 
-	recentActivity, err := s.GetActivityHistory(ctx, userID, recentStartDate, endDate)
-	if err != nil {
-		s.logger.Warn("Failed to retrieve recent activity data for dashboard", map[string]interface{}{
-			"user_id": userID,
-			"error":   err.Error(),
-		})
-		recentActivity = []*interfaces.ActivityDataDTO{}
+	return trend, nil
+}
+
+// floats converts an array column read back from the database
+func floats(v interface{}) []float64 {
+	switch v := v.(type) {
+	case []float64:
+		return v
+	case []interface{}:
+		out := make([]float64, 0, len(v))
+		for _, item := range v {
+			if f, ok := item.(float64); ok {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// maxHeartRatePoints bounds the points a heart rate series is downsampled to
+const maxHeartRatePoints = 10000
+
+// GetHeartRate retrieves the heart rate samples taken from startDate through
+// endDate, downsampled to at most points samples
+func (s *service) GetHeartRate(ctx context.Context, userID string, startDate, endDate time.Time, points int) ([]*interfaces.HeartRateDTO, error) {
+	if userID == "" {
+		return nil, errors.New(errors.ErrCodeBadRequest, "user ID is required")
+	}
+
+	if endDate.Before(startDate) {
+		return nil, errors.New(errors.ErrCodeBadRequest, "end date must be after start date")
+	}
+
+	// Limit date range to prevent excessive queries
+	if endDate.Sub(startDate) > 365*24*time.Hour {
+		return nil, errors.New(errors.ErrCodeBadRequest, "date range cannot exceed 365 days")
 	}
 
-	recentReadiness, err := s.GetReadinessHistory(ctx, userID, recentStartDate, endDate)
+	if points < 3 || points > maxHeartRatePoints {
+		return nil, errors.New(errors.ErrCodeBadRequest, fmt.Sprintf("points must be between 3 and %d", maxHeartRatePoints))
+	}
+
+	// The end date is inclusive
+	samples, err := s.repo.GetHeartRate(ctx, userID, startDate, endDate.AddDate(0, 0, 1))
 	if err != nil {
-		s.logger.Warn("Failed to retrieve recent readiness data for dashboard", map[string]interface{}{
-			"user_id": userID,
-			"error":   err.Error(),
+		s.logger.Error("Failed to retrieve heart rate", err, map[string]interface{}{
+			"user_id":    userID,
+			"start_date": startDate,
+			"end_date":   endDate,
 		})
-		recentReadiness = []*interfaces.ReadinessDataDTO{}
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to retrieve heart rate")
 	}
 
-	// Build dashboard response
-	dashboard := &interfaces.DashboardDTO{
-		Summary: &interfaces.DashboardSummaryDTO{
-			TotalDays:         summary.TotalDays,
-			AvgSleepScore:     summary.AvgSleepScore,
-			AvgActivityScore:  summary.AvgActivityScore,
-			AvgReadinessScore: summary.AvgReadinessScore,
-			TotalSteps:        summary.TotalSteps,
-			AvgSleepDuration:  summary.AvgSleepDuration,
-		},
-		RecentSleep:     recentSleep,
-		RecentActivity:  recentActivity,
-		RecentReadiness: recentReadiness,
-	}
+	samples = downsample(samples, points)
 
-	s.logger.Info("Dashboard data retrieved successfully", map[string]interface{}{
-		"user_id":           userID,
-		"days":              days,
-		"recent_sleep":      len(recentSleep),
-		"recent_activity":   len(recentActivity),
-		"recent_readiness":  len(recentReadiness),
-	})
+	dtos := make([]*interfaces.HeartRateDTO, 0, len(samples))
+	for _, sample := range samples {
+		dtos = append(dtos, &interfaces.HeartRateDTO{
+			Timestamp:     sample.Timestamp,
+			BPM:           sample.BPM,
+			Source:        sample.Source,
+			RestMode:      sample.RestMode,
+			LowConfidence: sample.LowConfidence,
+		})
+	}
 
-	return dashboard, nil
+	return dtos, nil
 }
@@ -2,12 +2,16 @@ package metrics
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
+	"github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
 	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockMetricsRepository implements interfaces.MetricsRepository
@@ -15,71 +19,53 @@ type MockMetricsRepository struct {
 	mock.Mock
 }
 
-func (m *MockMetricsRepository) SaveSleepMetric(ctx context.Context, metric *interfaces.SleepMetric) error {
-	args := m.Called(ctx, metric)
+func (m *MockMetricsRepository) SaveMetric(ctx context.Context, metricType string, metric *interfaces.Metric) error {
+	args := m.Called(ctx, metricType, metric)
 	return args.Error(0)
 }
 
-func (m *MockMetricsRepository) GetSleepMetrics(ctx context.Context, userID string, startDate, endDate time.Time) ([]*interfaces.SleepMetric, error) {
-	args := m.Called(ctx, userID, startDate, endDate)
+func (m *MockMetricsRepository) GetMetrics(ctx context.Context, metricType, userID string, startDate, endDate time.Time) ([]*interfaces.Metric, error) {
+	args := m.Called(ctx, metricType, userID, startDate, endDate)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*interfaces.SleepMetric), args.Error(1)
+	return args.Get(0).([]*interfaces.Metric), args.Error(1)
 }
 
-func (m *MockMetricsRepository) GetSleepMetricByID(ctx context.Context, metricID string) (*interfaces.SleepMetric, error) {
-	args := m.Called(ctx, metricID)
+func (m *MockMetricsRepository) GetMetricByOuraID(ctx context.Context, metricType, userID, ouraID string) (*interfaces.Metric, error) {
+	args := m.Called(ctx, metricType, userID, ouraID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*interfaces.SleepMetric), args.Error(1)
+	return args.Get(0).(*interfaces.Metric), args.Error(1)
 }
 
-func (m *MockMetricsRepository) SaveActivityMetric(ctx context.Context, metric *interfaces.ActivityMetric) error {
-	args := m.Called(ctx, metric)
-	return args.Error(0)
-}
-
-func (m *MockMetricsRepository) GetActivityMetrics(ctx context.Context, userID string, startDate, endDate time.Time) ([]*interfaces.ActivityMetric, error) {
-	args := m.Called(ctx, userID, startDate, endDate)
+func (m *MockMetricsRepository) GetMetricsByTag(ctx context.Context, metricType, userID string, startDate, endDate time.Time, filter interfaces.TagFilter) ([]*interfaces.Metric, error) {
+	args := m.Called(ctx, metricType, userID, startDate, endDate, filter)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*interfaces.ActivityMetric), args.Error(1)
+	return args.Get(0).([]*interfaces.Metric), args.Error(1)
 }
 
-func (m *MockMetricsRepository) GetActivityMetricByID(ctx context.Context, metricID string) (*interfaces.ActivityMetric, error) {
-	args := m.Called(ctx, metricID)
+func (m *MockMetricsRepository) GetMonthlyTrend(ctx context.Context, metricType, userID string, start, end time.Time) ([]*interfaces.MonthlyAggregate, error) {
+	args := m.Called(ctx, metricType, userID, start, end)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*interfaces.ActivityMetric), args.Error(1)
+	return args.Get(0).([]*interfaces.MonthlyAggregate), args.Error(1)
 }
 
-func (m *MockMetricsRepository) SaveReadinessMetric(ctx context.Context, metric *interfaces.ReadinessMetric) error {
-	args := m.Called(ctx, metric)
-	return args.Error(0)
-}
-
-func (m *MockMetricsRepository) GetReadinessMetrics(ctx context.Context, userID string, startDate, endDate time.Time) ([]*interfaces.ReadinessMetric, error) {
-	args := m.Called(ctx, userID, startDate, endDate)
+func (m *MockMetricsRepository) GetHeartRate(ctx context.Context, userID string, start, end time.Time) ([]*interfaces.HeartRateSample, error) {
+	args := m.Called(ctx, userID, start, end)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*interfaces.ReadinessMetric), args.Error(1)
+	return args.Get(0).([]*interfaces.HeartRateSample), args.Error(1)
 }
 
-func (m *MockMetricsRepository) GetReadinessMetricByID(ctx context.Context, metricID string) (*interfaces.ReadinessMetric, error) {
-	args := m.Called(ctx, metricID)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*interfaces.ReadinessMetric), args.Error(1)
-}
-
-func (m *MockMetricsRepository) GetDashboardSummary(ctx context.Context, userID string, days int) (*interfaces.DashboardSummary, error) {
-	args := m.Called(ctx, userID, days)
+func (m *MockMetricsRepository) GetDashboardSummary(ctx context.Context, userID string, days int, includeFlagged bool) (*interfaces.DashboardSummary, error) {
+	args := m.Called(ctx, userID, days, includeFlagged)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -121,275 +107,619 @@ func (m *MockLogger) WithContext(ctx context.Context) interfaces.Logger {
 	return args.Get(0).(interfaces.Logger)
 }
 
-// Test IngestSleepData
-func TestMetricsService_IngestSleepData_Success(t *testing.T) {
+// Test IngestMetric
+func TestMetricsService_IngestMetric_Sleep(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
 	service := NewService(mockRepo, mockLogger)
 
 	ctx := context.Background()
 	userID := "user-123"
-	sleepData := &interfaces.SleepDataDTO{
-		OuraID:   "oura-sleep-123",
-		Day:      time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
-		Score:    85,
-		Duration: 28800, // 8 hours in seconds
+	payload := json.RawMessage(`{"id": "oura-sleep-123", "day": "2024-01-15", "score": 85, "duration": 28800}`)
+
+	want := &interfaces.Metric{
+		UserID: userID,
+		OuraID: "oura-sleep-123",
+		Day:    time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		Values: map[string]interface{}{"score": 85, "duration": 28800},
 	}
+	mockRepo.On("SaveMetric", ctx, "sleep", want).Return(nil)
+	mockLogger.On("Info", "Metric ingested successfully", mock.Anything).Return()
+
+	err := service.IngestMetric(ctx, userID, "sleep", payload)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestMetricsService_IngestMetric_Activity(t *testing.T) {
+	mockRepo := new(MockMetricsRepository)
+	mockLogger := new(MockLogger)
+	service := NewService(mockRepo, mockLogger)
+
+	ctx := context.Background()
+	userID := "user-123"
+	payload := json.RawMessage(`{"id": "oura-activity-123", "day": "2024-01-15", "score": 90, "active_calories": 500, "steps": 10000, "medium_activity_minutes": 30, "high_activity_minutes": 20}`)
 
-	mockRepo.On("SaveSleepMetric", ctx, mock.AnythingOfType("*interfaces.SleepMetric")).Return(nil)
-	mockLogger.On("Info", "Sleep metric ingested successfully", mock.Anything).Return()
+	mockRepo.On("SaveMetric", ctx, "activity", mock.MatchedBy(func(m *interfaces.Metric) bool {
+		return m.OuraID == "oura-activity-123" && m.Values["steps"] == 10000 && m.Values["high_activity_minutes"] == 20
+	})).Return(nil)
+	mockLogger.On("Info", "Metric ingested successfully", mock.Anything).Return()
 
-	err := service.IngestSleepData(ctx, userID, sleepData)
+	err := service.IngestMetric(ctx, userID, "activity", payload)
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 	mockLogger.AssertExpectations(t)
 }
 
-func TestMetricsService_IngestSleepData_MissingUserID(t *testing.T) {
+func TestMetricsService_IngestMetric_MissingUserID(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
 	service := NewService(mockRepo, mockLogger)
 
 	ctx := context.Background()
-	sleepData := &interfaces.SleepDataDTO{
-		OuraID:   "oura-sleep-123",
-		Day:      time.Now(),
-		Score:    85,
-		Duration: 28800,
-	}
+	payload := json.RawMessage(`{"id": "oura-sleep-123", "day": "2024-01-15", "score": 85, "duration": 28800}`)
 
-	err := service.IngestSleepData(ctx, "", sleepData)
+	err := service.IngestMetric(ctx, "", "sleep", payload)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "user ID is required")
 }
 
-func TestMetricsService_IngestSleepData_InvalidScore(t *testing.T) {
+func TestMetricsService_IngestMetric_InvalidPayload(t *testing.T) {
+	tests := []struct {
+		name       string
+		metricType string
+		payload    string
+		wantErr    string
+	}{
+		{
+			name:       "sleep score above 100",
+			metricType: "sleep",
+			payload:    `{"id": "s1", "day": "2024-01-15", "score": 150, "duration": 28800}`,
+			wantErr:    "Score",
+		},
+		{
+			name:       "negative sleep duration",
+			metricType: "sleep",
+			payload:    `{"id": "s1", "day": "2024-01-15", "score": 85, "duration": -100}`,
+			wantErr:    "Duration",
+		},
+		{
+			name:       "negative activity score",
+			metricType: "activity",
+			payload:    `{"id": "a1", "day": "2024-01-15", "score": -10, "steps": 10000}`,
+			wantErr:    "Score",
+		},
+		{
+			name:       "negative steps",
+			metricType: "activity",
+			payload:    `{"id": "a1", "day": "2024-01-15", "score": 90, "steps": -1000}`,
+			wantErr:    "Steps",
+		},
+		{
+			name:       "readiness score above 100",
+			metricType: "readiness",
+			payload:    `{"id": "r1", "day": "2024-01-15", "score": 101}`,
+			wantErr:    "Score",
+		},
+		{
+			name:       "unknown type",
+			metricType: "heart_rate",
+			payload:    `{}`,
+			wantErr:    "unknown metric type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockMetricsRepository)
+			service := NewService(mockRepo, new(MockLogger))
+
+			err := service.IngestMetric(context.Background(), "user-123", tt.metricType, json.RawMessage(tt.payload))
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			mockRepo.AssertNotCalled(t, "SaveMetric", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// Test GetHistory
+func TestMetricsService_GetHistory_Success(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
 	service := NewService(mockRepo, mockLogger)
 
 	ctx := context.Background()
 	userID := "user-123"
-	sleepData := &interfaces.SleepDataDTO{
-		OuraID:   "oura-sleep-123",
-		Day:      time.Now(),
-		Score:    150, // Invalid: exceeds 100
-		Duration: 28800,
+	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	mockMetrics := []*interfaces.Metric{
+		{
+			ID:     "metric-1",
+			UserID: userID,
+			OuraID: "oura-1",
+			Day:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Values: map[string]interface{}{"score": int32(85), "duration": int32(28800)},
+
+			RestMode: true,
+		},
 	}
 
-	err := service.IngestSleepData(ctx, userID, sleepData)
+	mockRepo.On("GetMetrics", ctx, "sleep", userID, startDate, endDate).Return(mockMetrics, nil)
+
+	result, err := service.GetHistory(ctx, userID, "sleep", startDate, endDate)
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "oura-1", result[0].OuraID)
+	assert.Equal(t, int32(85), result[0].Values["score"])
+	assert.True(t, result[0].RestMode)
+	assert.False(t, result[0].LowConfidence)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMetricsService_GetHistory_UnknownType(t *testing.T) {
+	mockRepo := new(MockMetricsRepository)
+	mockLogger := new(MockLogger)
+	service := NewService(mockRepo, mockLogger)
+
+	ctx := context.Background()
+	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	result, err := service.GetHistory(ctx, "user-123", "heart_rate", startDate, endDate)
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "sleep score must be between 0 and 100")
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "unknown metric type")
 }
 
-func TestMetricsService_IngestSleepData_NegativeDuration(t *testing.T) {
+func TestMetricsService_GetHistory_InvalidDateRange(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
 	service := NewService(mockRepo, mockLogger)
 
 	ctx := context.Background()
 	userID := "user-123"
-	sleepData := &interfaces.SleepDataDTO{
-		OuraID:   "oura-sleep-123",
-		Day:      time.Now(),
-		Score:    85,
-		Duration: -100, // Invalid: negative
-	}
+	startDate := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) // End before start
 
-	err := service.IngestSleepData(ctx, userID, sleepData)
+	result, err := service.GetHistory(ctx, userID, "sleep", startDate, endDate)
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "sleep duration cannot be negative")
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "end date must be after start date")
 }
 
-// Test IngestActivityData
-func TestMetricsService_IngestActivityData_Success(t *testing.T) {
+func TestMetricsService_GetHistory_DateRangeTooLarge(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
 	service := NewService(mockRepo, mockLogger)
 
 	ctx := context.Background()
 	userID := "user-123"
-	activityData := &interfaces.ActivityDataDTO{
-		OuraID:            "oura-activity-123",
-		Day:               time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
-		Score:             90,
-		ActiveCalories:    500,
-		Steps:             10000,
-		MediumActivityMin: 30,
-		HighActivityMin:   20,
-	}
+	startDate := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) // > 365 days
+
+	result, err := service.GetHistory(ctx, userID, "sleep", startDate, endDate)
+
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "date range cannot exceed 365 days")
+}
+
+// Test GetDashboard
+func TestMetricsService_GetTaggedHistory(t *testing.T) {
+	mockRepo := new(MockMetricsRepository)
+	service := NewService(mockRepo, new(MockLogger))
 
-	mockRepo.On("SaveActivityMetric", ctx, mock.AnythingOfType("*interfaces.ActivityMetric")).Return(nil)
-	mockLogger.On("Info", "Activity metric ingested successfully", mock.Anything).Return()
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	filter := interfaces.TagFilter{Tag: "alcohol", Without: true}
+	mockRepo.On("GetMetricsByTag", ctx, ingest.TypeSleep, "user-123", start, end, filter).Return([]*interfaces.Metric{
+		{OuraID: "sleep-2", Day: time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC), Values: map[string]interface{}{"score": int32(85)}},
+	}, nil)
 
-	err := service.IngestActivityData(ctx, userID, activityData)
+	result, err := service.GetTaggedHistory(ctx, "user-123", ingest.TypeSleep, start, end, filter)
 
 	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "sleep-2", result[0].OuraID)
 	mockRepo.AssertExpectations(t)
-	mockLogger.AssertExpectations(t)
 }
 
-func TestMetricsService_IngestActivityData_InvalidScore(t *testing.T) {
+func TestMetricsService_GetTaggedHistory_InvalidRequest(t *testing.T) {
+	service := NewService(new(MockMetricsRepository), new(MockLogger))
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// Only sleep, activity and readiness can be filtered by tag
+	_, err := service.GetTaggedHistory(ctx, "user-123", ingest.TypeWorkout, start, end, interfaces.TagFilter{Tag: "alcohol"})
+	assert.Equal(t, errors.ErrCodeBadRequest, errors.GetAppError(err).Code)
+
+	_, err = service.GetTaggedHistory(ctx, "user-123", ingest.TypeSleep, start, end, interfaces.TagFilter{})
+	assert.Equal(t, errors.ErrCodeBadRequest, errors.GetAppError(err).Code)
+
+	_, err = service.GetTaggedHistory(ctx, "user-123", ingest.TypeSleep, end, start, interfaces.TagFilter{Tag: "alcohol"})
+	assert.Equal(t, errors.ErrCodeBadRequest, errors.GetAppError(err).Code)
+}
+
+func TestMetricsService_GetRecord_WorkoutHeartRateSummary(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
 	service := NewService(mockRepo, mockLogger)
 
 	ctx := context.Background()
 	userID := "user-123"
-	activityData := &interfaces.ActivityDataDTO{
-		OuraID:            "oura-activity-123",
-		Day:               time.Now(),
-		Score:             -10, // Invalid: negative
-		ActiveCalories:    500,
-		Steps:             10000,
-		MediumActivityMin: 30,
-		HighActivityMin:   20,
-	}
+	start := time.Date(2024, 1, 10, 17, 0, 0, 0, time.UTC)
+	end := start.Add(45 * time.Minute)
+
+	mockRepo.On("GetMetricByOuraID", ctx, ingest.TypeWorkout, userID, "workout-1").Return(&interfaces.Metric{
+		OuraID:   "workout-1",
+		Day:      time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+		RestMode: true,
+		Values:   map[string]interface{}{"activity": "running", "start_datetime": start, "end_datetime": end},
+	}, nil)
+	mockRepo.On("GetHeartRate", ctx, userID, start, end).Return([]*interfaces.HeartRateSample{
+		{Timestamp: start, BPM: 120, RestMode: true},
+		{Timestamp: start.Add(time.Minute), BPM: 150, RestMode: true},
+		{Timestamp: start.Add(2 * time.Minute), BPM: 141, RestMode: true},
+	}, nil)
+
+	result, err := service.GetRecord(ctx, userID, ingest.TypeWorkout, "workout-1")
 
-	err := service.IngestActivityData(ctx, userID, activityData)
+	assert.NoError(t, err)
+	assert.Equal(t, "running", result.Values["activity"])
+	assert.True(t, result.RestMode)
+	assert.False(t, result.LowConfidence)
+	assert.Equal(t, &interfaces.HeartRateSummaryDTO{Average: 137, Min: 120, Max: 150, Samples: 3, RestMode: true}, result.Values["heart_rate"])
+	mockRepo.AssertExpectations(t)
+}
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "activity score must be between 0 and 100")
+func TestMetricsService_GetRecord_SessionKeepsOwnSummary(t *testing.T) {
+	mockRepo := new(MockMetricsRepository)
+	service := NewService(mockRepo, new(MockLogger))
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 10, 7, 0, 0, 0, time.UTC)
+	mockRepo.On("GetMetricByOuraID", ctx, ingest.TypeSession, "user-123", "session-1").Return(&interfaces.Metric{
+		OuraID: "session-1",
+		Values: map[string]interface{}{"type": "meditation", "start_datetime": start, "end_datetime": start.Add(10 * time.Minute), "heart_rate_average": 60.0},
+	}, nil)
+
+	result, err := service.GetRecord(ctx, "user-123", ingest.TypeSession, "session-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 60.0, result.Values["heart_rate_average"])
+	assert.NotContains(t, result.Values, "heart_rate")
+	mockRepo.AssertNotCalled(t, "GetHeartRate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
-func TestMetricsService_IngestActivityData_NegativeSteps(t *testing.T) {
+func TestMetricsService_GetRecord_NotFound(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
-	mockLogger := new(MockLogger)
-	service := NewService(mockRepo, mockLogger)
+	service := NewService(mockRepo, new(MockLogger))
 
 	ctx := context.Background()
-	userID := "user-123"
-	activityData := &interfaces.ActivityDataDTO{
-		OuraID:            "oura-activity-123",
-		Day:               time.Now(),
-		Score:             90,
-		ActiveCalories:    500,
-		Steps:             -1000, // Invalid: negative
-		MediumActivityMin: 30,
-		HighActivityMin:   20,
-	}
+	mockRepo.On("GetMetricByOuraID", ctx, ingest.TypeWorkout, "user-123", "missing").Return(nil, nil)
 
-	err := service.IngestActivityData(ctx, userID, activityData)
+	result, err := service.GetRecord(ctx, "user-123", ingest.TypeWorkout, "missing")
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "calories and steps cannot be negative")
+	assert.Nil(t, result)
+	assert.Equal(t, errors.ErrCodeNotFound, errors.GetAppError(err).Code)
 }
 
-// Test IngestReadinessData
-func TestMetricsService_IngestReadinessData_Success(t *testing.T) {
+func TestMetricsService_GetSleepPeriods(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
 	service := NewService(mockRepo, mockLogger)
 
 	ctx := context.Background()
 	userID := "user-123"
-	readinessData := &interfaces.ReadinessDataDTO{
-		OuraID: "oura-readiness-123",
-		Day:    time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
-		Score:  75,
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	nightStart := time.Date(2024, 1, 9, 23, 0, 0, 0, time.UTC)
+	napStart := time.Date(2024, 1, 10, 14, 0, 0, 0, time.UTC)
+
+	mockMetrics := []*interfaces.Metric{
+		{OuraID: "nap", Day: day, Values: map[string]interface{}{
+			"bedtime_start": napStart, "bedtime_end": napStart.Add(12 * time.Minute), "sleep_phase_5_min": "422",
+		}},
+		{OuraID: "night", Day: day, Values: map[string]interface{}{
+			"bedtime_start": nightStart, "bedtime_end": nightStart.Add(30 * time.Minute), "sleep_phase_5_min": "422113",
+		}},
 	}
+	mockRepo.On("GetMetrics", ctx, ingest.TypeSleepPeriod, userID, day, day).Return(mockMetrics, nil)
 
-	mockRepo.On("SaveReadinessMetric", ctx, mock.AnythingOfType("*interfaces.ReadinessMetric")).Return(nil)
-	mockLogger.On("Info", "Readiness metric ingested successfully", mock.Anything).Return()
-
-	err := service.IngestReadinessData(ctx, userID, readinessData)
+	result, err := service.GetSleepPeriods(ctx, userID, day)
 
 	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.Equal(t, "night", result[0].OuraID)
+	assert.Equal(t, []interfaces.HypnogramSegment{
+		{Stage: "awake", Start: nightStart, End: nightStart.Add(5 * time.Minute), Duration: 300},
+		{Stage: "light", Start: nightStart.Add(5 * time.Minute), End: nightStart.Add(15 * time.Minute), Duration: 600},
+		{Stage: "deep", Start: nightStart.Add(15 * time.Minute), End: nightStart.Add(25 * time.Minute), Duration: 600},
+		{Stage: "rem", Start: nightStart.Add(25 * time.Minute), End: nightStart.Add(30 * time.Minute), Duration: 300},
+	}, result[0].Hypnogram)
+	// The nap ends two minutes into its last interval
+	assert.Equal(t, "nap", result[1].OuraID)
+	assert.Equal(t, 420, result[1].Hypnogram[1].Duration)
 	mockRepo.AssertExpectations(t)
-	mockLogger.AssertExpectations(t)
 }
 
-func TestMetricsService_IngestReadinessData_InvalidScore(t *testing.T) {
+func TestMetricsService_GetActivityTimeline(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
 	service := NewService(mockRepo, mockLogger)
 
 	ctx := context.Background()
 	userID := "user-123"
-	readinessData := &interfaces.ReadinessDataDTO{
-		OuraID: "oura-readiness-123",
-		Day:    time.Now(),
-		Score:  101, // Invalid: exceeds 100
-	}
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 1, 10, 4, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetMetrics", ctx, ingest.TypeActivity, userID, day, day).Return([]*interfaces.Metric{{
+		OuraID:        "activity-1",
+		Day:           day,
+		LowConfidence: true,
+		Values: map[string]interface{}{
+			"score":         int32(80),
+			"day_start":     start,
+			"class_5_min":   "0112555",
+			"met_interval":  float64(60),
+			"met_timestamp": start,
+			// Arrays come back from the database as []interface{}
+			"met_items": []interface{}{0.9, 1.1, 7.5},
+		},
+	}}, nil)
 
-	err := service.IngestReadinessData(ctx, userID, readinessData)
+	result, err := service.GetActivityTimeline(ctx, userID, day)
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "readiness score must be between 0 and 100")
+	assert.NoError(t, err)
+	assert.Equal(t, "activity-1", result.OuraID)
+	assert.Equal(t, start, *result.Start)
+	assert.False(t, result.RestMode)
+	assert.True(t, result.LowConfidence)
+	assert.Equal(t, []interfaces.IntensitySegment{
+		{Intensity: "non_wear", Start: start, End: start.Add(5 * time.Minute), Duration: 300},
+		{Intensity: "rest", Start: start.Add(5 * time.Minute), End: start.Add(15 * time.Minute), Duration: 600},
+		{Intensity: "inactive", Start: start.Add(15 * time.Minute), End: start.Add(20 * time.Minute), Duration: 300},
+		{Intensity: "high", Start: start.Add(20 * time.Minute), End: start.Add(35 * time.Minute), Duration: 900},
+	}, result.Intensity)
+	assert.Equal(t, []interfaces.METSample{
+		{Timestamp: start, MET: 0.9},
+		{Timestamp: start.Add(time.Minute), MET: 1.1},
+		{Timestamp: start.Add(2 * time.Minute), MET: 7.5},
+	}, result.MET)
+	mockRepo.AssertExpectations(t)
 }
 
-// Test GetSleepHistory
-func TestMetricsService_GetSleepHistory_Success(t *testing.T) {
+func TestMetricsService_GetActivityTimeline_WithoutTimeline(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
-	mockLogger := new(MockLogger)
-	service := NewService(mockRepo, mockLogger)
+	service := NewService(mockRepo, new(MockLogger))
 
 	ctx := context.Background()
-	userID := "user-123"
-	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
-	endDate := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("GetMetrics", ctx, ingest.TypeActivity, "user-123", day, day).Return([]*interfaces.Metric{{
+		OuraID: "activity-1",
+		Day:    day,
+		Values: map[string]interface{}{"score": int32(80), "day_start": nil, "class_5_min": nil, "met_items": nil},
+	}}, nil)
 
-	mockMetrics := []*interfaces.SleepMetric{
-		{
-			ID:       "metric-1",
-			UserID:   userID,
-			OuraID:   "oura-1",
-			Day:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			Score:    85,
-			Duration: 28800,
+	result, err := service.GetActivityTimeline(ctx, "user-123", day)
+
+	assert.NoError(t, err)
+	assert.Nil(t, result.Start)
+	assert.Empty(t, result.Intensity)
+	assert.Empty(t, result.MET)
+}
+
+func TestMetricsService_GetReadinessBreakdown(t *testing.T) {
+	mockRepo := new(MockMetricsRepository)
+	service := NewService(mockRepo, new(MockLogger))
+
+	ctx := context.Background()
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("GetMetrics", ctx, ingest.TypeReadiness, "user-123", day, day).Return([]*interfaces.Metric{{
+		OuraID:   "readiness-1",
+		Day:      day,
+		RestMode: true,
+		Values: map[string]interface{}{
+			"score":                       int32(68),
+			"activity_balance":            int32(82),
+			"body_temperature":            nil,
+			"hrv_balance":                 int32(45),
+			"previous_day_activity":       int32(90),
+			"previous_night":              int32(70),
+			"recovery_index":              int32(45),
+			"resting_heart_rate":          int32(95),
+			"sleep_balance":               int32(60),
+			"temperature_deviation":       0.3,
+			"temperature_trend_deviation": nil,
 		},
+	}}, nil)
+
+	result, err := service.GetReadinessBreakdown(ctx, "user-123", day)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "readiness-1", result.OuraID)
+	assert.Equal(t, 68, result.Score)
+	assert.True(t, result.RestMode)
+	assert.False(t, result.LowConfidence)
+	// Weakest first, ties in Oura's order, unscored last
+	var names []string
+	for _, c := range result.Contributors {
+		names = append(names, c.Name)
 	}
+	assert.Equal(t, []string{
+		"hrv_balance", "recovery_index", "sleep_balance", "previous_night",
+		"activity_balance", "previous_day_activity", "resting_heart_rate", "body_temperature",
+	}, names)
+	assert.Equal(t, 45, *result.Contributors[0].Score)
+	assert.Nil(t, result.Contributors[7].Score)
+	assert.Equal(t, "hrv_balance", result.Weakest)
+	assert.Equal(t, 0.3, *result.TemperatureDeviation)
+	assert.Nil(t, result.TemperatureTrendDeviation)
+}
+
+func TestMetricsService_GetReadinessBreakdown_WithoutContributors(t *testing.T) {
+	mockRepo := new(MockMetricsRepository)
+	service := NewService(mockRepo, new(MockLogger))
 
-	mockRepo.On("GetSleepMetrics", ctx, userID, startDate, endDate).Return(mockMetrics, nil)
+	ctx := context.Background()
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("GetMetrics", ctx, ingest.TypeReadiness, "user-123", day, day).Return([]*interfaces.Metric{{
+		OuraID: "readiness-1",
+		Day:    day,
+		Values: map[string]interface{}{"score": int32(70)},
+	}}, nil)
 
-	result, err := service.GetSleepHistory(ctx, userID, startDate, endDate)
+	result, err := service.GetReadinessBreakdown(ctx, "user-123", day)
 
 	assert.NoError(t, err)
-	assert.Len(t, result, 1)
-	assert.Equal(t, "oura-1", result[0].OuraID)
-	assert.Equal(t, 85, result[0].Score)
+	assert.Equal(t, 70, result.Score)
+	assert.Len(t, result.Contributors, len(ingest.ReadinessContributorNames))
+	assert.Empty(t, result.Weakest)
+}
+
+func TestMetricsService_GetReadinessBreakdown_NotFound(t *testing.T) {
+	mockRepo := new(MockMetricsRepository)
+	service := NewService(mockRepo, new(MockLogger))
+
+	ctx := context.Background()
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("GetMetrics", ctx, ingest.TypeReadiness, "user-123", day, day).Return([]*interfaces.Metric{}, nil)
+
+	result, err := service.GetReadinessBreakdown(ctx, "user-123", day)
+
+	assert.Nil(t, result)
+	assert.Equal(t, errors.ErrCodeNotFound, errors.GetAppError(err).Code)
+}
+
+func TestMetricsService_GetTrend(t *testing.T) {
+	mockRepo := new(MockMetricsRepository)
+	service := NewService(mockRepo, new(MockLogger))
+
+	ctx := context.Background()
+	// The window starts on the first of the month five months before this one
+	firstOfMonth := mock.MatchedBy(func(start time.Time) bool {
+		now := time.Now().UTC()
+		want := time.Date(now.Year(), now.Month()-5, 1, 0, 0, 0, 0, time.UTC)
+		return start.Equal(want)
+	})
+	mockRepo.On("GetMonthlyTrend", ctx, ingest.TypeVO2Max, "user-123", firstOfMonth, mock.AnythingOfType("time.Time")).Return([]*interfaces.MonthlyAggregate{
+		{Month: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Average: 40, Min: 39, Max: 41, Samples: 4},
+		{Month: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Average: 41.5, Min: 41, Max: 42, Samples: 2, LowConfidence: true},
+		{Month: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), Average: 43, Min: 42, Max: 44, Samples: 3},
+	}, nil)
+
+	result, err := service.GetTrend(ctx, "user-123", ingest.TypeVO2Max, 6)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ingest.TypeVO2Max, result.Type)
+	require.Len(t, result.Months, 3)
+	assert.Equal(t, interfaces.TrendMonthDTO{Month: "2024-01", Average: 40, Min: 39, Max: 41, Samples: 4, ChangeSinceBaseline: 0}, result.Months[0])
+	assert.Equal(t, "2024-03", result.Months[1].Month)
+	assert.Equal(t, 1.5, result.Months[1].ChangeSinceBaseline)
+	assert.True(t, result.Months[1].LowConfidence)
+	assert.False(t, result.Months[1].RestMode)
+	assert.Equal(t, 40.0, *result.Baseline)
+	assert.Equal(t, 3.0, *result.Change)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestMetricsService_GetSleepHistory_InvalidDateRange(t *testing.T) {
+func TestMetricsService_GetTrend_NoReadings(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
-	mockLogger := new(MockLogger)
-	service := NewService(mockRepo, mockLogger)
+	service := NewService(mockRepo, new(MockLogger))
 
 	ctx := context.Background()
-	userID := "user-123"
-	startDate := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
-	endDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) // End before start
+	mockRepo.On("GetMonthlyTrend", ctx, ingest.TypeCardiovascularAge, "user-123", mock.Anything, mock.Anything).
+		Return([]*interfaces.MonthlyAggregate(nil), nil)
 
-	result, err := service.GetSleepHistory(ctx, userID, startDate, endDate)
+	result, err := service.GetTrend(ctx, "user-123", ingest.TypeCardiovascularAge, 12)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, result.Months)
+	assert.Empty(t, result.Months)
+	assert.Nil(t, result.Baseline)
+	assert.Nil(t, result.Change)
+}
+
+func TestMetricsService_GetTrend_InvalidRequest(t *testing.T) {
+	service := NewService(new(MockMetricsRepository), new(MockLogger))
+	ctx := context.Background()
+
+	for _, months := range []int{0, maxTrendMonths + 1} {
+		_, err := service.GetTrend(ctx, "user-123", ingest.TypeVO2Max, months)
+		assert.Equal(t, errors.ErrCodeBadRequest, errors.GetAppError(err).Code, "months %d", months)
+	}
+
+	_, err := service.GetTrend(ctx, "user-123", ingest.TypeSleep, 12)
+	assert.Equal(t, errors.ErrCodeBadRequest, errors.GetAppError(err).Code)
+}
+
+func TestMetricsService_GetActivityTimeline_NotFound(t *testing.T) {
+	mockRepo := new(MockMetricsRepository)
+	service := NewService(mockRepo, new(MockLogger))
+
+	ctx := context.Background()
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("GetMetrics", ctx, ingest.TypeActivity, "user-123", day, day).Return([]*interfaces.Metric{}, nil)
+
+	result, err := service.GetActivityTimeline(ctx, "user-123", day)
 
-	assert.Error(t, err)
 	assert.Nil(t, result)
-	assert.Contains(t, err.Error(), "end date must be after start date")
+	assert.Equal(t, errors.ErrCodeNotFound, errors.GetAppError(err).Code)
 }
 
-func TestMetricsService_GetSleepHistory_DateRangeTooLarge(t *testing.T) {
+func TestMetricsService_GetHeartRate_Downsamples(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
 	service := NewService(mockRepo, mockLogger)
 
 	ctx := context.Background()
 	userID := "user-123"
-	startDate := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
-	endDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) // > 365 days
+	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
 
-	result, err := service.GetSleepHistory(ctx, userID, startDate, endDate)
+	var samples []*interfaces.HeartRateSample
+	for i := 0; i < 100; i++ {
+		samples = append(samples, &interfaces.HeartRateSample{Timestamp: startDate.Add(time.Duration(i) * 5 * time.Minute), BPM: 60 + i%7, Source: "awake", LowConfidence: true})
+	}
+	// The end date is inclusive, so the query runs to the start of the next day
+	mockRepo.On("GetHeartRate", ctx, userID, startDate, endDate.AddDate(0, 0, 1)).Return(samples, nil)
 
-	assert.Error(t, err)
-	assert.Nil(t, result)
-	assert.Contains(t, err.Error(), "date range cannot exceed 365 days")
+	result, err := service.GetHeartRate(ctx, userID, startDate, endDate, 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 10)
+	assert.Equal(t, samples[0].Timestamp, result[0].Timestamp)
+	assert.Equal(t, samples[99].Timestamp, result[9].Timestamp)
+	assert.Equal(t, "awake", result[0].Source)
+	assert.True(t, result[0].LowConfidence)
+	assert.False(t, result[0].RestMode)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMetricsService_GetHeartRate_InvalidPoints(t *testing.T) {
+	service := NewService(new(MockMetricsRepository), new(MockLogger))
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, points := range []int{0, 2, maxHeartRatePoints + 1} {
+		result, err := service.GetHeartRate(context.Background(), "user-123", day, day, points)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		assert.Contains(t, err.Error(), "points must be between")
+	}
 }
 
-// Test GetDashboard
 func TestMetricsService_GetDashboard_Success(t *testing.T) {
 	mockRepo := new(MockMetricsRepository)
 	mockLogger := new(MockLogger)
@@ -406,15 +736,28 @@ func TestMetricsService_GetDashboard_Success(t *testing.T) {
 		AvgReadinessScore: 80.0,
 		TotalSteps:        300000,
 		AvgSleepDuration:  8.2,
+
+		AvgSpO2:                      96.4,
+		AvgBreathingDisturbanceIndex: 3.5,
+		AvgStressHigh:                1.5,
+		AvgRecoveryHigh:              2.25,
+		AvgResilienceSleepRecovery:   70.0,
+		AvgResilienceDaytimeRecovery: 45.0,
+		AvgResilienceStress:          60.0,
+
+		WeakestReadinessContributor:      "hrv_balance",
+		WeakestReadinessContributorScore: 52.5,
+
+		ExcludedDays: 2,
 	}
 
-	mockRepo.On("GetDashboardSummary", ctx, userID, days).Return(mockSummary, nil)
-	mockRepo.On("GetSleepMetrics", ctx, userID, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]*interfaces.SleepMetric{}, nil)
-	mockRepo.On("GetActivityMetrics", ctx, userID, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]*interfaces.ActivityMetric{}, nil)
-	mockRepo.On("GetReadinessMetrics", ctx, userID, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]*interfaces.ReadinessMetric{}, nil)
+	mockRepo.On("GetDashboardSummary", ctx, userID, days, false).Return(mockSummary, nil)
+	for _, mt := range ingest.Records() {
+		mockRepo.On("GetMetrics", ctx, mt.Name, userID, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]*interfaces.Metric{}, nil)
+	}
 	mockLogger.On("Info", "Dashboard data retrieved successfully", mock.Anything).Return()
 
-	result, err := service.GetDashboard(ctx, userID, days)
+	result, err := service.GetDashboard(ctx, userID, days, false)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
@@ -422,6 +765,17 @@ func TestMetricsService_GetDashboard_Success(t *testing.T) {
 	assert.Equal(t, 30, result.Summary.TotalDays)
 	assert.Equal(t, 85.5, result.Summary.AvgSleepScore)
 	assert.Equal(t, 90.0, result.Summary.AvgActivityScore)
+	assert.Equal(t, 96.4, result.Summary.AvgSpO2)
+	assert.Equal(t, 1.5, result.Summary.AvgStressHigh)
+	assert.Equal(t, 2.25, result.Summary.AvgRecoveryHigh)
+	assert.Equal(t, 45.0, result.Summary.AvgResilienceDaytimeRecovery)
+	assert.Equal(t, "hrv_balance", result.Summary.WeakestReadinessContributor)
+	assert.Equal(t, 52.5, result.Summary.WeakestReadinessContributorScore)
+	assert.Equal(t, 2, result.Summary.ExcludedDays)
+	assert.Len(t, result.Recent, len(ingest.Records()))
+	for _, metricType := range []string{ingest.TypeSpO2, ingest.TypeStress, ingest.TypeResilience} {
+		assert.Contains(t, result.Recent, metricType)
+	}
 	mockRepo.AssertExpectations(t)
 	mockLogger.AssertExpectations(t)
 }
@@ -435,7 +789,7 @@ func TestMetricsService_GetDashboard_InvalidDays(t *testing.T) {
 	userID := "user-123"
 	days := 0 // Invalid: must be > 0
 
-	result, err := service.GetDashboard(ctx, userID, days)
+	result, err := service.GetDashboard(ctx, userID, days, false)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -451,7 +805,7 @@ func TestMetricsService_GetDashboard_DaysExceedsLimit(t *testing.T) {
 	userID := "user-123"
 	days := 500 // Invalid: exceeds 365
 
-	result, err := service.GetDashboard(ctx, userID, days)
+	result, err := service.GetDashboard(ctx, userID, days, false)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+)
+
+// timelineInterval is the time each character of Oura's sleep_phase_5_min
+// and class_5_min strings covers
+const timelineInterval = 5 * time.Minute
+
+// sleepStages maps sleep_phase_5_min characters to stage names
+var sleepStages = map[rune]string{
+	'1': "deep",
+	'2': "light",
+	'3': "rem",
+	'4': "awake",
+}
+
+// activityIntensities maps class_5_min characters to intensity names
+var activityIntensities = map[rune]string{
+	'0': "non_wear",
+	'1': "rest",
+	'2': "inactive",
+	'3': "low",
+	'4': "medium",
+	'5': "high",
+}
+
+// span is a stretch of a decoded timeline spent in one state
+type span struct {
+	label      string
+	start, end time.Time
+}
+
+func (s span) seconds() int { return int(s.end.Sub(s.start) / time.Second) }
+
+// decodeTimeline turns a string of 5-minute codes into a timeline starting at
+// start, merging consecutive intervals with the same label. If end is set the
+// last span is cut off at it, as a period rarely ends on an interval
+// boundary. Unrecognised codes are labelled "unknown".
+func decodeTimeline(codes string, labels map[rune]string, start, end time.Time) []span {
+	spans := []span{}
+	at := start
+	for _, c := range codes {
+		label, ok := labels[c]
+		if !ok {
+			label = "unknown"
+		}
+
+		next := at.Add(timelineInterval)
+		if n := len(spans); n > 0 && spans[n-1].label == label {
+			spans[n-1].end = next
+		} else {
+			spans = append(spans, span{label: label, start: at, end: next})
+		}
+		at = next
+	}
+
+	if n := len(spans); n > 0 && end.After(spans[n-1].start) && end.Before(spans[n-1].end) {
+		spans[n-1].end = end
+	}
+	return spans
+}
+
+// decodeHypnogram decodes a sleep period's sleep_phase_5_min string
+func decodeHypnogram(phases string, start, end time.Time) []interfaces.HypnogramSegment {
+	spans := decodeTimeline(phases, sleepStages, start, end)
+	segments := make([]interfaces.HypnogramSegment, len(spans))
+	for i, s := range spans {
+		segments[i] = interfaces.HypnogramSegment{Stage: s.label, Start: s.start, End: s.end, Duration: s.seconds()}
+	}
+	return segments
+}
+
+// decodeIntensity decodes a day's class_5_min string
+func decodeIntensity(classes string, start time.Time) []interfaces.IntensitySegment {
+	spans := decodeTimeline(classes, activityIntensities, start, time.Time{})
+	segments := make([]interfaces.IntensitySegment, len(spans))
+	for i, s := range spans {
+		segments[i] = interfaces.IntensitySegment{Intensity: s.label, Start: s.start, End: s.end, Duration: s.seconds()}
+	}
+	return segments
+}
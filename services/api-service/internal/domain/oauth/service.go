@@ -45,7 +45,7 @@ func (s *service) GenerateAuthURL(ctx context.Context, userID string) (string, e
 	params.Add("response_type", "code")
 	params.Add("client_id", s.clientID)
 	params.Add("redirect_uri", s.redirectURI)
-	params.Add("scope", "daily personal email")
+	params.Add("scope", "daily heartrate workout session spo2 personal email")
 	params.Add("state", userID) // Use userID as state for CSRF protection
 
 	fullURL := authURL + "?" + params.Encode()
@@ -101,54 +101,6 @@ func (s *service) HandleCallback(ctx context.Context, code, state string) (*inte
 	}, nil
 }
 
-// RefreshAccessToken refreshes an expired access token using the refresh token
-func (s *service) RefreshAccessToken(ctx context.Context, userID, provider string) error {
-	if userID == "" {
-		return errors.BadRequest("user ID is required")
-	}
-	if provider == "" {
-		return errors.BadRequest("provider is required")
-	}
-
-	// Get existing token from database
-	existingToken, err := s.repo.GetToken(ctx, userID, provider)
-	if err != nil {
-		return errors.Wrap(err, errors.ErrCodeInternal, "failed to get token")
-	}
-	if existingToken == nil {
-		return errors.NotFound("no OAuth token found for user")
-	}
-
-	// Check if token is still valid (not expired)
-	if time.Now().Before(existingToken.ExpiresAt) {
-		s.logger.Info("Token still valid, no refresh needed", map[string]interface{}{
-			"user_id": userID,
-			"expires_at": existingToken.ExpiresAt,
-		})
-		return nil
-	}
-
-	// Token is expired, refresh it
-	tokenResp, err := s.refreshToken(ctx, existingToken.RefreshToken)
-	if err != nil {
-		return errors.Wrap(err, errors.ErrCodeInternal, "failed to refresh token")
-	}
-
-	// Update token in database using RefreshToken method
-	expiresAt := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
-	err = s.repo.RefreshToken(ctx, userID, provider, tokenResp.AccessToken, tokenResp.RefreshToken, expiresAt)
-	if err != nil {
-		return errors.Wrap(err, errors.ErrCodeInternal, "failed to update token")
-	}
-
-	s.logger.Info("Successfully refreshed OAuth token", map[string]interface{}{
-		"user_id": userID,
-		"expires_at": expiresAt,
-	})
-
-	return nil
-}
-
 // RevokeToken revokes the OAuth token and removes it from the database
 func (s *service) RevokeToken(ctx context.Context, userID, provider string) error {
 	if userID == "" {
@@ -231,47 +183,6 @@ func (s *service) exchangeCodeForToken(ctx context.Context, code string) (*token
 	return &tokenResp, nil
 }
 
-// refreshToken refreshes an access token using a refresh token
-func (s *service) refreshToken(ctx context.Context, refreshToken string) (*tokenResponse, error) {
-	tokenURL := "https://api.ouraring.com/oauth/token"
-
-	data := url.Values{}
-	data.Set("grant_type", "refresh_token")
-	data.Set("refresh_token", refreshToken)
-	data.Set("client_id", s.clientID)
-	data.Set("client_secret", s.clientSecret)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(data.Encode()))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var tokenResp tokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return &tokenResp, nil
-}
-
 // revokeTokenWithProvider revokes a token with the OAuth provider
 func (s *service) revokeTokenWithProvider(ctx context.Context, token string) error {
 	// Note: Oura API may not have a dedicated revoke endpoint
@@ -38,11 +38,6 @@ func (m *MockOAuthRepository) DeleteToken(ctx context.Context, userID, provider
 	return args.Error(0)
 }
 
-func (m *MockOAuthRepository) RefreshToken(ctx context.Context, userID, provider string, newAccessToken, newRefreshToken string, expiresAt time.Time) error {
-	args := m.Called(ctx, userID, provider, newAccessToken, newRefreshToken, expiresAt)
-	return args.Error(0)
-}
-
 // MockLogger is a simple mock implementation of interfaces.Logger
 type MockLogger struct{}
 
@@ -68,7 +63,7 @@ func TestOAuthService_GenerateAuthURL_Success(t *testing.T) {
 	assert.Contains(t, authURL, "client_id=test-client-id")
 	assert.Contains(t, authURL, "redirect_uri=http")
 	assert.Contains(t, authURL, "state=user-123")
-	assert.Contains(t, authURL, "scope=daily+personal+email")
+	assert.Contains(t, authURL, "scope=daily+heartrate+workout+session+spo2+personal+email")
 }
 
 func TestOAuthService_GenerateAuthURL_MissingUserID(t *testing.T) {
@@ -83,58 +78,6 @@ func TestOAuthService_GenerateAuthURL_MissingUserID(t *testing.T) {
 	assert.Contains(t, err.Error(), "user ID is required")
 }
 
-func TestOAuthService_RefreshAccessToken_TokenStillValid(t *testing.T) {
-	mockRepo := new(MockOAuthRepository)
-	mockLogger := &MockLogger{}
-	
-	// Token that expires in the future
-	futureExpiry := time.Now().Add(1 * time.Hour)
-	existingToken := &interfaces.OAuthToken{
-		UserID:       "user-123",
-		AccessToken:  "existing-access-token",
-		RefreshToken: "existing-refresh-token",
-		ExpiresAt:    futureExpiry,
-		Scope:        "daily personal",
-		Provider:     "oura",
-	}
-	
-	mockRepo.On("GetToken", mock.Anything, "user-123", "oura").Return(existingToken, nil)
-	
-	service := NewService(mockRepo, "test-client-id", "test-secret", "http://localhost/callback", mockLogger)
-	
-	err := service.RefreshAccessToken(context.Background(), "user-123", "oura")
-	
-	assert.NoError(t, err)
-	mockRepo.AssertExpectations(t)
-}
-
-func TestOAuthService_RefreshAccessToken_NoTokenFound(t *testing.T) {
-	mockRepo := new(MockOAuthRepository)
-	mockLogger := &MockLogger{}
-	
-	mockRepo.On("GetToken", mock.Anything, "user-123", "oura").Return(nil, nil)
-	
-	service := NewService(mockRepo, "test-client-id", "test-secret", "http://localhost/callback", mockLogger)
-	
-	err := service.RefreshAccessToken(context.Background(), "user-123", "oura")
-	
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "no OAuth token found")
-	mockRepo.AssertExpectations(t)
-}
-
-func TestOAuthService_RefreshAccessToken_MissingUserID(t *testing.T) {
-	mockRepo := new(MockOAuthRepository)
-	mockLogger := &MockLogger{}
-	
-	service := NewService(mockRepo, "test-client-id", "test-secret", "http://localhost/callback", mockLogger)
-	
-	err := service.RefreshAccessToken(context.Background(), "", "oura")
-	
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "user ID is required")
-}
-
 func TestOAuthService_RevokeToken_Success(t *testing.T) {
 	mockRepo := new(MockOAuthRepository)
 	mockLogger := &MockLogger{}
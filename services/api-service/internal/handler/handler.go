@@ -1,229 +1,102 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/auth"
-	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+	"github.com/asian-code/myapp-kubernetes/services/shared/logger"
 	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
+const dateLayout = "2006-01-02"
+
+// Handler exposes the domain services over HTTP
 type Handler struct {
-	repo      *repository.Repository
-	logger    *log.Entry
-	metrics   *metrics.Metrics
-	jwtSecret string
+	userService    interfaces.UserService
+	oauthService   interfaces.OAuthService
+	metricsService interfaces.MetricsService
+	logger         *log.Entry
+	metrics        *metrics.Metrics
+	jwtSecret      string
 }
 
-func New(repo *repository.Repository, logger *log.Entry, m *metrics.Metrics, jwtSecret string) *Handler {
+func New(
+	userService interfaces.UserService,
+	oauthService interfaces.OAuthService,
+	metricsService interfaces.MetricsService,
+	logger *log.Entry,
+	m *metrics.Metrics,
+	jwtSecret string,
+) *Handler {
 	return &Handler{
-		repo:      repo,
-		logger:    logger,
-		metrics:   m,
-		jwtSecret: jwtSecret,
+		userService:    userService,
+		oauthService:   oauthService,
+		metricsService: metricsService,
+		logger:         logger,
+		metrics:        m,
+		jwtSecret:      jwtSecret,
 	}
 }
 
-type DashboardResponse struct {
-	LatestSleep     *repository.SleepMetric     `json:"latest_sleep"`
-	LatestActivity  *repository.ActivityMetric  `json:"latest_activity"`
-	LatestReadiness *repository.ReadinessMetric `json:"latest_readiness"`
-	WeeklySummary   *WeeklySummary              `json:"weekly_summary"`
-}
-
-type WeeklySummary struct {
-	AvgSleepScore     float64 `json:"avg_sleep_score"`
-	AvgActivityScore  float64 `json:"avg_activity_score"`
-	AvgReadinessScore float64 `json:"avg_readiness_score"`
-	TotalSteps        int     `json:"total_steps"`
-}
-
-func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	defer func() {
-		h.metrics.HTTPRequestDuration.WithLabelValues(r.Method, "/dashboard").Observe(time.Since(start).Seconds())
-		h.metrics.HTTPRequestsTotal.WithLabelValues(r.Method, "/dashboard", "200").Inc()
-	}()
-
-	ctx := r.Context()
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -7)
-
-	// Get latest metrics
-	sleepMetrics, _ := h.repo.GetSleepMetrics(ctx, startDate, endDate)
-	activityMetrics, _ := h.repo.GetActivityMetrics(ctx, startDate, endDate)
-	readinessMetrics, _ := h.repo.GetReadinessMetrics(ctx, startDate, endDate)
-
-	response := &DashboardResponse{
-		WeeklySummary: &WeeklySummary{},
+// Instrument records request count and latency for the wrapped handler
+func (h *Handler) Instrument(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		h.metrics.RecordHTTPRequest(r.Method, endpoint, rec.status, time.Since(start))
 	}
-
-	// Get latest of each
-	if len(sleepMetrics) > 0 {
-		response.LatestSleep = sleepMetrics[0]
-		// Calculate average
-		total := 0
-		for _, m := range sleepMetrics {
-			total += m.Score
-		}
-		response.WeeklySummary.AvgSleepScore = float64(total) / float64(len(sleepMetrics))
-	}
-
-	if len(activityMetrics) > 0 {
-		response.LatestActivity = activityMetrics[0]
-		totalScore := 0
-		totalSteps := 0
-		for _, m := range activityMetrics {
-			totalScore += m.Score
-			totalSteps += m.Steps
-		}
-		response.WeeklySummary.AvgActivityScore = float64(totalScore) / float64(len(activityMetrics))
-		response.WeeklySummary.TotalSteps = totalSteps
-	}
-
-	if len(readinessMetrics) > 0 {
-		response.LatestReadiness = readinessMetrics[0]
-		total := 0
-		for _, m := range readinessMetrics {
-			total += m.Score
-		}
-		response.WeeklySummary.AvgReadinessScore = float64(total) / float64(len(readinessMetrics))
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-func (h *Handler) GetSleep(w http.ResponseWriter, r *http.Request) {
-	h.getMetrics(w, r, "sleep")
 }
 
-func (h *Handler) GetActivity(w http.ResponseWriter, r *http.Request) {
-	h.getMetrics(w, r, "activity")
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, r, map[string]string{"status": "healthy"}, http.StatusOK)
 }
 
-func (h *Handler) GetReadiness(w http.ResponseWriter, r *http.Request) {
-	h.getMetrics(w, r, "readiness")
+func (h *Handler) PrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	promhttp.Handler().ServeHTTP(w, r)
 }
 
-func (h *Handler) getMetrics(w http.ResponseWriter, r *http.Request, metricType string) {
-	start := time.Now()
-	defer func() {
-		endpoint := fmt.Sprintf("/metrics/%s", metricType)
-		h.metrics.HTTPRequestDuration.WithLabelValues(r.Method, endpoint).Observe(time.Since(start).Seconds())
-		h.metrics.HTTPRequestsTotal.WithLabelValues(r.Method, endpoint, "200").Inc()
-	}()
-
-	// Parse date range from query params (default: last 30 days)
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -30)
-
-	if startParam := r.URL.Query().Get("start"); startParam != "" {
-		if t, err := time.Parse("2006-01-02", startParam); err == nil {
-			startDate = t
-		}
-	}
-	if endParam := r.URL.Query().Get("end"); endParam != "" {
-		if t, err := time.Parse("2006-01-02", endParam); err == nil {
-			endDate = t
-		}
-	}
-
-	ctx := r.Context()
-
-	var result interface{}
-	var err error
-
-	switch metricType {
-	case "sleep":
-		result, err = h.repo.GetSleepMetrics(ctx, startDate, endDate)
-	case "activity":
-		result, err = h.repo.GetActivityMetrics(ctx, startDate, endDate)
-	case "readiness":
-		result, err = h.repo.GetReadinessMetrics(ctx, startDate, endDate)
+// userID returns the authenticated caller or writes a 401
+func (h *Handler) userID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		h.writeError(w, r, apperrors.Unauthorized("Authentication required"))
+		return "", false
 	}
-
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to get metrics")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
-}
-
-type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	return userID, true
 }
 
-type LoginResponse struct {
-	Token string `json:"token"`
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apperrors.WriteError(w, logger.WithContext(r.Context(), h.logger), err)
 }
 
-func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
-	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
+func (h *Handler) writeJSON(w http.ResponseWriter, r *http.Request, data interface{}, status int) {
+	if err := apperrors.WriteSuccess(w, data, status); err != nil {
+		logger.WithContext(r.Context(), h.logger).WithError(err).Error("Failed to encode response")
 	}
-
-	// TODO: Implement proper user authentication
-	// For now, just generate a token for any user
-	token, err := auth.GenerateToken(req.Username, h.jwtSecret)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to generate token")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(LoginResponse{Token: token})
 }
 
-func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
+// decodeJSON decodes the request body into v, rejecting malformed input
+func decodeJSON(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return apperrors.BadRequest("Invalid request body")
+	}
+	return nil
 }
 
-func (h *Handler) PrometheusMetrics(w http.ResponseWriter, r *http.Request) {
-	promhttp.Handler().ServeHTTP(w, r)
+// statusRecorder captures the response status code for metrics
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
 }
 
-// AuthMiddleware validates JWT tokens
-func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Missing authorization header", http.StatusUnauthorized)
-			return
-		}
-
-		// Extract token from "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
-			return
-		}
-
-		token := parts[1]
-		claims, err := auth.ValidateToken(token, h.jwtSecret)
-		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		// Add user ID to context
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, "user_id", claims.UserID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
 }
@@ -0,0 +1,581 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/auth"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test_jwt_secret_1234567890123456"
+
+// MockUserService implements interfaces.UserService
+type MockUserService struct {
+	mock.Mock
+}
+
+func (m *MockUserService) Register(ctx context.Context, username, email, password string) (*interfaces.UserDTO, error) {
+	args := m.Called(ctx, username, email, password)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.UserDTO), args.Error(1)
+}
+
+func (m *MockUserService) Login(ctx context.Context, username, password string) (string, error) {
+	args := m.Called(ctx, username, password)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockUserService) GetProfile(ctx context.Context, userID string) (*interfaces.UserDTO, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.UserDTO), args.Error(1)
+}
+
+func (m *MockUserService) UpdateProfile(ctx context.Context, userID string, updates *interfaces.UserUpdateDTO) error {
+	args := m.Called(ctx, userID, updates)
+	return args.Error(0)
+}
+
+func (m *MockUserService) DeleteAccount(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// MockMetricsService implements interfaces.MetricsService
+type MockMetricsService struct {
+	mock.Mock
+}
+
+func (m *MockMetricsService) IngestMetric(ctx context.Context, userID, metricType string, payload json.RawMessage) error {
+	args := m.Called(ctx, userID, metricType, payload)
+	return args.Error(0)
+}
+
+func (m *MockMetricsService) GetHistory(ctx context.Context, userID, metricType string, startDate, endDate time.Time) ([]*interfaces.MetricDTO, error) {
+	args := m.Called(ctx, userID, metricType, startDate, endDate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*interfaces.MetricDTO), args.Error(1)
+}
+
+func (m *MockMetricsService) GetTaggedHistory(ctx context.Context, userID, metricType string, startDate, endDate time.Time, filter interfaces.TagFilter) ([]*interfaces.MetricDTO, error) {
+	args := m.Called(ctx, userID, metricType, startDate, endDate, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*interfaces.MetricDTO), args.Error(1)
+}
+
+func (m *MockMetricsService) GetRecord(ctx context.Context, userID, metricType, ouraID string) (*interfaces.MetricDTO, error) {
+	args := m.Called(ctx, userID, metricType, ouraID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.MetricDTO), args.Error(1)
+}
+
+func (m *MockMetricsService) GetDashboard(ctx context.Context, userID string, days int, includeFlagged bool) (*interfaces.DashboardDTO, error) {
+	args := m.Called(ctx, userID, days, includeFlagged)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.DashboardDTO), args.Error(1)
+}
+
+func (m *MockMetricsService) GetSleepPeriods(ctx context.Context, userID string, day time.Time) ([]*interfaces.SleepPeriodDTO, error) {
+	args := m.Called(ctx, userID, day)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*interfaces.SleepPeriodDTO), args.Error(1)
+}
+
+func (m *MockMetricsService) GetHeartRate(ctx context.Context, userID string, startDate, endDate time.Time, points int) ([]*interfaces.HeartRateDTO, error) {
+	args := m.Called(ctx, userID, startDate, endDate, points)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*interfaces.HeartRateDTO), args.Error(1)
+}
+
+func (m *MockMetricsService) GetActivityTimeline(ctx context.Context, userID string, day time.Time) (*interfaces.ActivityTimelineDTO, error) {
+	args := m.Called(ctx, userID, day)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.ActivityTimelineDTO), args.Error(1)
+}
+
+func (m *MockMetricsService) GetReadinessBreakdown(ctx context.Context, userID string, day time.Time) (*interfaces.ReadinessBreakdownDTO, error) {
+	args := m.Called(ctx, userID, day)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.ReadinessBreakdownDTO), args.Error(1)
+}
+
+func (m *MockMetricsService) GetTrend(ctx context.Context, userID, metricType string, months int) (*interfaces.TrendDTO, error) {
+	args := m.Called(ctx, userID, metricType, months)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.TrendDTO), args.Error(1)
+}
+
+func newTestHandler(users interfaces.UserService, svc interfaces.MetricsService) *Handler {
+	l := log.New()
+	l.SetOutput(new(strings.Builder))
+	return New(users, nil, svc, log.NewEntry(l), metrics.New("api-service-test"), testSecret)
+}
+
+func withUser(r *http.Request, userID string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), "user_id", userID))
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) apperrors.ErrorResponse {
+	var resp apperrors.ErrorResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	return resp
+}
+
+func TestDashboard_RequiresAuthenticatedUser(t *testing.T) {
+	h := newTestHandler(nil, new(MockMetricsService))
+
+	rec := httptest.NewRecorder()
+	h.Dashboard(rec, httptest.NewRequest("GET", "/api/v1/dashboard", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, apperrors.ErrCodeUnauthorized, decodeError(t, rec).Error.Code)
+}
+
+func TestDashboard_UsesCallerAndDays(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	dashboard := &interfaces.DashboardDTO{
+		Summary: &interfaces.DashboardSummaryDTO{TotalDays: 14},
+		Recent:  map[string][]*interfaces.MetricDTO{"sleep": {}},
+	}
+	svc.On("GetDashboard", mock.Anything, "user-123", 14, false).Return(dashboard, nil)
+
+	rec := httptest.NewRecorder()
+	h.Dashboard(rec, withUser(httptest.NewRequest("GET", "/api/v1/dashboard?days=14", nil), "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got struct {
+		Summary     interfaces.DashboardSummaryDTO `json:"summary"`
+		RecentSleep []map[string]interface{}       `json:"recent_sleep"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, 14, got.Summary.TotalDays)
+	assert.NotNil(t, got.RecentSleep)
+	svc.AssertExpectations(t)
+}
+
+func TestDashboard_IncludeFlagged(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	dashboard := &interfaces.DashboardDTO{Summary: &interfaces.DashboardSummaryDTO{TotalDays: 7}}
+	svc.On("GetDashboard", mock.Anything, "user-123", defaultDashboardDays, true).Return(dashboard, nil)
+
+	rec := httptest.NewRecorder()
+	h.Dashboard(rec, withUser(httptest.NewRequest("GET", "/api/v1/dashboard?include_flagged=true", nil), "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestDashboard_RejectsInvalidIncludeFlagged(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	rec := httptest.NewRecorder()
+	h.Dashboard(rec, withUser(httptest.NewRequest("GET", "/api/v1/dashboard?include_flagged=maybe", nil), "user-123"))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, apperrors.ErrCodeBadRequest, decodeError(t, rec).Error.Code)
+	svc.AssertNotCalled(t, "GetDashboard", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetHistory_ParsesDateRange(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	svc.On("GetHistory", mock.Anything, "user-123", "sleep", start, end).
+		Return([]*interfaces.MetricDTO{{OuraID: "sleep-1", Day: start, Values: map[string]interface{}{"score": 80}, LowConfidence: true}}, nil)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/sleep?start=2024-01-01&end=2024-01-31", nil)
+	h.GetHistory("sleep")(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got []map[string]interface{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got, 1)
+	assert.Equal(t, "sleep-1", got[0]["oura_id"])
+	assert.Equal(t, float64(80), got[0]["score"])
+	assert.Equal(t, false, got[0]["rest_mode"])
+	assert.Equal(t, true, got[0]["low_confidence"])
+	svc.AssertExpectations(t)
+}
+
+func TestGetHistory_TagFilter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query string
+		want  interfaces.TagFilter
+	}{
+		{"tag=alcohol", interfaces.TagFilter{Tag: "alcohol"}},
+		{"without_tag=alcohol", interfaces.TagFilter{Tag: "alcohol", Without: true}},
+	}
+	for _, tt := range tests {
+		svc := new(MockMetricsService)
+		h := newTestHandler(nil, svc)
+		svc.On("GetTaggedHistory", mock.Anything, "user-123", "sleep", start, end, tt.want).
+			Return([]*interfaces.MetricDTO{{OuraID: "sleep-1", Day: start, Values: map[string]interface{}{"score": 70}}}, nil)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/v1/sleep?start=2024-01-01&end=2024-01-31&"+tt.query, nil)
+		h.GetHistory("sleep")(rec, withUser(req, "user-123"))
+
+		assert.Equal(t, http.StatusOK, rec.Code, tt.query)
+		svc.AssertExpectations(t)
+		svc.AssertNotCalled(t, "GetHistory", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestGetHistory_TagAndWithoutTag(t *testing.T) {
+	h := newTestHandler(nil, new(MockMetricsService))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/sleep?tag=alcohol&without_tag=caffeine", nil)
+	h.GetHistory("sleep")(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, apperrors.ErrCodeBadRequest, decodeError(t, rec).Error.Code)
+}
+
+func TestGetHistory_InvalidDate(t *testing.T) {
+	h := newTestHandler(nil, new(MockMetricsService))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/sleep?start=01-01-2024", nil)
+	h.GetHistory("sleep")(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, apperrors.ErrCodeBadRequest, decodeError(t, rec).Error.Code)
+}
+
+func TestGetHistory_PropagatesServiceError(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	svc.On("GetHistory", mock.Anything, "user-123", "activity", mock.Anything, mock.Anything).
+		Return(nil, apperrors.New(apperrors.ErrCodeBadRequest, "date range cannot exceed 365 days"))
+
+	rec := httptest.NewRecorder()
+	h.GetHistory("activity")(rec, withUser(httptest.NewRequest("GET", "/api/v1/activity", nil), "user-123"))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "date range cannot exceed 365 days", decodeError(t, rec).Error.Message)
+}
+
+func TestGetRecord_UsesPathID(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	svc.On("GetRecord", mock.Anything, "user-123", "workout", "workout-1").
+		Return(&interfaces.MetricDTO{OuraID: "workout-1", Day: day, Values: map[string]interface{}{"activity": "cycling"}}, nil)
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/workout/workout-1", nil), map[string]string{"id": "workout-1"})
+	h.GetRecord("workout")(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got map[string]interface{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, "workout-1", got["oura_id"])
+	assert.Equal(t, "cycling", got["activity"])
+	svc.AssertExpectations(t)
+}
+
+func TestGetRecord_NotFound(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	svc.On("GetRecord", mock.Anything, "user-123", "session", "missing").
+		Return(nil, apperrors.NotFound(`session "missing" not found`))
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/session/missing", nil), map[string]string{"id": "missing"})
+	h.GetRecord("session")(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, apperrors.ErrCodeNotFound, decodeError(t, rec).Error.Code)
+}
+
+func TestGetSleepPeriods_ReturnsHypnogram(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 1, 9, 23, 0, 0, 0, time.UTC)
+	svc.On("GetSleepPeriods", mock.Anything, "user-123", day).Return([]*interfaces.SleepPeriodDTO{{
+		MetricDTO: &interfaces.MetricDTO{OuraID: "period-1", Day: day, Values: map[string]interface{}{"efficiency": 90}},
+		Hypnogram: []interfaces.HypnogramSegment{{Stage: "light", Start: start, End: start.Add(10 * time.Minute), Duration: 600}},
+	}}, nil)
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/sleep/2024-01-10/periods", nil), map[string]string{"day": "2024-01-10"})
+	h.GetSleepPeriods(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got []struct {
+		OuraID     string                        `json:"oura_id"`
+		Efficiency int                           `json:"efficiency"`
+		Hypnogram  []interfaces.HypnogramSegment `json:"hypnogram"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got, 1)
+	assert.Equal(t, "period-1", got[0].OuraID)
+	assert.Equal(t, 90, got[0].Efficiency)
+	require.Len(t, got[0].Hypnogram, 1)
+	assert.Equal(t, "light", got[0].Hypnogram[0].Stage)
+	assert.Equal(t, 600, got[0].Hypnogram[0].Duration)
+	svc.AssertExpectations(t)
+}
+
+func TestGetSleepPeriods_InvalidDay(t *testing.T) {
+	h := newTestHandler(nil, new(MockMetricsService))
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/sleep/yesterday/periods", nil), map[string]string{"day": "yesterday"})
+	h.GetSleepPeriods(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, apperrors.ErrCodeBadRequest, decodeError(t, rec).Error.Code)
+}
+
+func TestGetActivityTimeline_ReturnsTimeline(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 1, 10, 4, 0, 0, 0, time.UTC)
+	svc.On("GetActivityTimeline", mock.Anything, "user-123", day).Return(&interfaces.ActivityTimelineDTO{
+		OuraID:    "activity-1",
+		Day:       day,
+		Start:     &start,
+		Intensity: []interfaces.IntensitySegment{{Intensity: "high", Start: start, End: start.Add(5 * time.Minute), Duration: 300}},
+		MET:       []interfaces.METSample{{Timestamp: start, MET: 6.5}},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/activity/2024-01-10/timeline", nil), map[string]string{"day": "2024-01-10"})
+	h.GetActivityTimeline(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got interfaces.ActivityTimelineDTO
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, "activity-1", got.OuraID)
+	require.Len(t, got.Intensity, 1)
+	assert.Equal(t, "high", got.Intensity[0].Intensity)
+	require.Len(t, got.MET, 1)
+	assert.Equal(t, 6.5, got.MET[0].MET)
+	svc.AssertExpectations(t)
+}
+
+func TestGetActivityTimeline_NotFound(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	svc.On("GetActivityTimeline", mock.Anything, "user-123", mock.Anything).
+		Return(nil, apperrors.NotFound("no activity recorded on 2024-01-10"))
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/activity/2024-01-10/timeline", nil), map[string]string{"day": "2024-01-10"})
+	h.GetActivityTimeline(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetReadinessBreakdown_ReturnsContributors(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	low, high := 45, 90
+	svc.On("GetReadinessBreakdown", mock.Anything, "user-123", day).Return(&interfaces.ReadinessBreakdownDTO{
+		OuraID: "readiness-1",
+		Day:    day,
+		Score:  68,
+		Contributors: []interfaces.ReadinessContributorDTO{
+			{Name: "hrv_balance", Score: &low},
+			{Name: "resting_heart_rate", Score: &high},
+			{Name: "body_temperature"},
+		},
+		Weakest: "hrv_balance",
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/readiness/2024-01-10/breakdown", nil), map[string]string{"day": "2024-01-10"})
+	h.GetReadinessBreakdown(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got map[string]interface{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, "hrv_balance", got["weakest"])
+	assert.Nil(t, got["temperature_deviation"])
+	contributors := got["contributors"].([]interface{})
+	require.Len(t, contributors, 3)
+	assert.Equal(t, map[string]interface{}{"name": "body_temperature", "score": nil}, contributors[2])
+	svc.AssertExpectations(t)
+}
+
+func TestGetReadinessBreakdown_InvalidDay(t *testing.T) {
+	h := newTestHandler(nil, new(MockMetricsService))
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/readiness/yesterday/breakdown", nil), map[string]string{"day": "yesterday"})
+	h.GetReadinessBreakdown(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, apperrors.ErrCodeBadRequest, decodeError(t, rec).Error.Code)
+}
+
+func TestGetTrend_DefaultsToTwelveMonths(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	baseline, change := 40.0, 2.5
+	svc.On("GetTrend", mock.Anything, "user-123", "vo2_max", defaultTrendMonths).Return(&interfaces.TrendDTO{
+		Type:     "vo2_max",
+		Months:   []interfaces.TrendMonthDTO{{Month: "2024-01", Average: 40, Min: 40, Max: 40, Samples: 1}},
+		Baseline: &baseline,
+		Change:   &change,
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	h.GetTrend("vo2_max")(rec, withUser(httptest.NewRequest("GET", "/api/v1/trends/vo2_max", nil), "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got interfaces.TrendDTO
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got.Months, 1)
+	assert.Equal(t, "2024-01", got.Months[0].Month)
+	assert.Equal(t, 2.5, *got.Change)
+	svc.AssertExpectations(t)
+}
+
+func TestGetTrend_InvalidMonths(t *testing.T) {
+	h := newTestHandler(nil, new(MockMetricsService))
+
+	rec := httptest.NewRecorder()
+	h.GetTrend("vo2_max")(rec, withUser(httptest.NewRequest("GET", "/api/v1/trends/vo2_max?months=all", nil), "user-123"))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, apperrors.ErrCodeBadRequest, decodeError(t, rec).Error.Code)
+}
+
+func TestGetHeartRate_ParsesRangeAndPoints(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	svc.On("GetHeartRate", mock.Anything, "user-123", start, end, 500).
+		Return([]*interfaces.HeartRateDTO{{Timestamp: start, BPM: 62, Source: "rest"}}, nil)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/heartrate?start=2024-01-01&end=2024-03-31&points=500", nil)
+	h.GetHeartRate(rec, withUser(req, "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got []interfaces.HeartRateDTO
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got, 1)
+	assert.Equal(t, 62, got[0].BPM)
+	svc.AssertExpectations(t)
+}
+
+func TestGetHeartRate_DefaultsPoints(t *testing.T) {
+	svc := new(MockMetricsService)
+	h := newTestHandler(nil, svc)
+
+	svc.On("GetHeartRate", mock.Anything, "user-123", mock.Anything, mock.Anything, defaultHeartRatePoints).
+		Return([]*interfaces.HeartRateDTO{}, nil)
+
+	rec := httptest.NewRecorder()
+	h.GetHeartRate(rec, withUser(httptest.NewRequest("GET", "/api/v1/heartrate", nil), "user-123"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestGetHeartRate_InvalidPoints(t *testing.T) {
+	h := newTestHandler(nil, new(MockMetricsService))
+
+	rec := httptest.NewRecorder()
+	h.GetHeartRate(rec, withUser(httptest.NewRequest("GET", "/api/v1/heartrate?points=many", nil), "user-123"))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "points must be an integer", decodeError(t, rec).Error.Message)
+}
+
+func TestLogin_ReturnsTokenAndUserID(t *testing.T) {
+	users := new(MockUserService)
+	h := newTestHandler(users, nil)
+
+	token, err := auth.GenerateToken("user-123", testSecret)
+	require.NoError(t, err)
+	users.On("Login", mock.Anything, "alice", "password123").Return(token, nil)
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"username":"alice","password":"password123"}`)
+	h.Login(rec, httptest.NewRequest("POST", "/api/login", body))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var resp AuthResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, token, resp.Token)
+	assert.Equal(t, "user-123", resp.UserID)
+}
+
+func TestLogin_InvalidCredentials(t *testing.T) {
+	users := new(MockUserService)
+	h := newTestHandler(users, nil)
+
+	users.On("Login", mock.Anything, "alice", "wrong").
+		Return("", apperrors.InvalidCredentials("Invalid username or password"))
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"username":"alice","password":"wrong"}`)
+	h.Login(rec, httptest.NewRequest("POST", "/api/login", body))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, apperrors.ErrCodeInvalidCredentials, decodeError(t, rec).Error.Code)
+}
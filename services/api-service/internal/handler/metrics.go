@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultDashboardDays   = 7
+	defaultHistoryDays     = 30
+	defaultHeartRatePoints = 1000
+	defaultTrendMonths     = 12
+)
+
+// Dashboard returns the caller's summary and recent metrics
+func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	days := defaultDashboardDays
+	if daysParam := r.URL.Query().Get("days"); daysParam != "" {
+		parsed, err := strconv.Atoi(daysParam)
+		if err != nil {
+			h.writeError(w, r, apperrors.BadRequest("days must be an integer"))
+			return
+		}
+		days = parsed
+	}
+
+	var includeFlagged bool
+	if flaggedParam := r.URL.Query().Get("include_flagged"); flaggedParam != "" {
+		parsed, err := strconv.ParseBool(flaggedParam)
+		if err != nil {
+			h.writeError(w, r, apperrors.BadRequest("include_flagged must be a boolean"))
+			return
+		}
+		includeFlagged = parsed
+	}
+
+	dashboard, err := h.metricsService.GetDashboard(r.Context(), userID, days, includeFlagged)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, dashboard, http.StatusOK)
+}
+
+// GetHistory returns a handler for the caller's records of one metric type.
+// The tag query parameter keeps only the records a tag affected and
+// without_tag only those it did not.
+func (h *Handler) GetHistory(metricType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, startDate, endDate, ok := h.historyParams(w, r)
+		if !ok {
+			return
+		}
+
+		query := r.URL.Query()
+		tag, withoutTag := query.Get("tag"), query.Get("without_tag")
+		if tag != "" && withoutTag != "" {
+			h.writeError(w, r, apperrors.BadRequest("tag and without_tag cannot be combined"))
+			return
+		}
+
+		var result []*interfaces.MetricDTO
+		var err error
+		switch {
+		case tag != "":
+			result, err = h.metricsService.GetTaggedHistory(r.Context(), userID, metricType, startDate, endDate, interfaces.TagFilter{Tag: tag})
+		case withoutTag != "":
+			result, err = h.metricsService.GetTaggedHistory(r.Context(), userID, metricType, startDate, endDate, interfaces.TagFilter{Tag: withoutTag, Without: true})
+		default:
+			result, err = h.metricsService.GetHistory(r.Context(), userID, metricType, startDate, endDate)
+		}
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+
+		h.writeJSON(w, r, result, http.StatusOK)
+	}
+}
+
+// GetRecord returns a handler for one of the caller's records of a metric
+// type, by the Oura ID in the {id} path variable
+func (h *Handler) GetRecord(metricType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.userID(w, r)
+		if !ok {
+			return
+		}
+
+		result, err := h.metricsService.GetRecord(r.Context(), userID, metricType, mux.Vars(r)["id"])
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+
+		h.writeJSON(w, r, result, http.StatusOK)
+	}
+}
+
+// GetSleepPeriods returns the caller's sleep periods for the {day} path
+// variable, each with a decoded hypnogram
+func (h *Handler) GetSleepPeriods(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	day, err := time.Parse(dateLayout, mux.Vars(r)["day"])
+	if err != nil {
+		h.writeError(w, r, apperrors.BadRequest("day must be a date in YYYY-MM-DD format"))
+		return
+	}
+
+	periods, err := h.metricsService.GetSleepPeriods(r.Context(), userID, day)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, periods, http.StatusOK)
+}
+
+// GetActivityTimeline returns the caller's activity intensity timeline for
+// the {day} path variable
+func (h *Handler) GetActivityTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	day, err := time.Parse(dateLayout, mux.Vars(r)["day"])
+	if err != nil {
+		h.writeError(w, r, apperrors.BadRequest("day must be a date in YYYY-MM-DD format"))
+		return
+	}
+
+	timeline, err := h.metricsService.GetActivityTimeline(r.Context(), userID, day)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, timeline, http.StatusOK)
+}
+
+// GetReadinessBreakdown returns the contributors behind the caller's
+// readiness score on the day in the path
+func (h *Handler) GetReadinessBreakdown(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	day, err := time.Parse(dateLayout, mux.Vars(r)["day"])
+	if err != nil {
+		h.writeError(w, r, apperrors.BadRequest("day must be a date in YYYY-MM-DD format"))
+		return
+	}
+
+	breakdown, err := h.metricsService.GetReadinessBreakdown(r.Context(), userID, day)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, breakdown, http.StatusOK)
+}
+
+// GetHeartRate returns the caller's heart rate over the start/end range,
+// downsampled to the points query parameter
+func (h *Handler) GetHeartRate(w http.ResponseWriter, r *http.Request) {
+	userID, startDate, endDate, ok := h.historyParams(w, r)
+	if !ok {
+		return
+	}
+
+	points := defaultHeartRatePoints
+	if pointsParam := r.URL.Query().Get("points"); pointsParam != "" {
+		parsed, err := strconv.Atoi(pointsParam)
+		if err != nil {
+			h.writeError(w, r, apperrors.BadRequest("points must be an integer"))
+			return
+		}
+		points = parsed
+	}
+
+	samples, err := h.metricsService.GetHeartRate(r.Context(), userID, startDate, endDate, points)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, samples, http.StatusOK)
+}
+
+// GetTrend returns a handler for the caller's monthly trend of metricType
+// over the months query parameter
+func (h *Handler) GetTrend(metricType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.userID(w, r)
+		if !ok {
+			return
+		}
+
+		months := defaultTrendMonths
+		if monthsParam := r.URL.Query().Get("months"); monthsParam != "" {
+			parsed, err := strconv.Atoi(monthsParam)
+			if err != nil {
+				h.writeError(w, r, apperrors.BadRequest("months must be an integer"))
+				return
+			}
+			months = parsed
+		}
+
+		trend, err := h.metricsService.GetTrend(r.Context(), userID, metricType, months)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+
+		h.writeJSON(w, r, trend, http.StatusOK)
+	}
+}
+
+// historyParams resolves the caller and the start/end query parameters.
+// The range defaults to the last 30 days.
+func (h *Handler) historyParams(w http.ResponseWriter, r *http.Request) (string, time.Time, time.Time, bool) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return "", time.Time{}, time.Time{}, false
+	}
+
+	endDate := time.Now().UTC()
+	startDate := endDate.AddDate(0, 0, -defaultHistoryDays)
+
+	if startParam := r.URL.Query().Get("start"); startParam != "" {
+		t, err := time.Parse(dateLayout, startParam)
+		if err != nil {
+			h.writeError(w, r, apperrors.BadRequest("start must be a date in YYYY-MM-DD format"))
+			return "", time.Time{}, time.Time{}, false
+		}
+		startDate = t
+	}
+	if endParam := r.URL.Query().Get("end"); endParam != "" {
+		t, err := time.Parse(dateLayout, endParam)
+		if err != nil {
+			h.writeError(w, r, apperrors.BadRequest("end must be a date in YYYY-MM-DD format"))
+			return "", time.Time{}, time.Time{}, false
+		}
+		endDate = t
+	}
+
+	return userID, startDate, endDate, true
+}
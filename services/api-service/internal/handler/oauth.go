@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+)
+
+// Authorize redirects the authenticated user to the Oura consent screen
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	authURL, err := h.oauthService.GenerateAuthURL(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+}
+
+// Callback exchanges the authorization code returned by Oura for tokens
+func (h *Handler) Callback(w http.ResponseWriter, r *http.Request) {
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		h.writeError(w, r, apperrors.BadRequest("Authorization was denied").WithDetails("error", errParam))
+		return
+	}
+
+	_, err := h.oauthService.HandleCallback(r.Context(), r.URL.Query().Get("code"), r.URL.Query().Get("state"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/oauth/success", http.StatusTemporaryRedirect)
+}
+
+// OAuthSuccess is the landing page after a completed authorization
+func (h *Handler) OAuthSuccess(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OAuth authorization successful! You can close this window."))
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/auth"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+)
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type AuthResponse struct {
+	Token  string `json:"token"`
+	UserID string `json:"user_id"`
+}
+
+// Register creates a new user account and returns a session token
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	user, err := h.userService.Register(r.Context(), req.Username, req.Email, req.Password)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	token, err := h.userService.Login(r.Context(), req.Username, req.Password)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, AuthResponse{Token: token, UserID: user.ID}, http.StatusCreated)
+}
+
+// Login authenticates a user and returns a session token
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	token, err := h.userService.Login(r.Context(), req.Username, req.Password)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	claims, err := auth.ValidateToken(token, h.jwtSecret)
+	if err != nil {
+		h.writeError(w, r, apperrors.Wrap(err, apperrors.ErrCodeInternal, "Failed to issue token"))
+		return
+	}
+
+	h.writeJSON(w, r, AuthResponse{Token: token, UserID: claims.UserID}, http.StatusOK)
+}
+
+// Me returns the authenticated user's profile
+func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	profile, err := h.userService.GetProfile(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, profile, http.StatusOK)
+}
@@ -0,0 +1,377 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+	"github.com/jackc/pgx/v5"
+)
+
+// Metrics of any registered type
+
+// metricType resolves a type name registered with pkg/ingest
+func metricType(name string) (*ingest.MetricType, error) {
+	t, ok := ingest.Lookup(name)
+	if !ok || t.Series {
+		return nil, fmt.Errorf("unknown metric type %q", name)
+	}
+	return t, nil
+}
+
+// DefaultLowWearThreshold is the non-wear time beyond which a day's metrics
+// are low confidence unless configured otherwise: a quarter of the day
+const DefaultLowWearThreshold = 6 * time.Hour
+
+// restModeFlag is an expression that is true if a user's day fell in one of
+// their rest mode periods
+func restModeFlag(user, day string) string {
+	return fmt.Sprintf(`EXISTS (
+				SELECT 1 FROM rest_mode_periods r
+				WHERE r.user_id = %s AND %s BETWEEN r.day AND COALESCE(r.end_day, CURRENT_DATE)
+			)`, user, day)
+}
+
+// lowConfidenceFlag is an expression that is true if the ring was off for
+// more than the low wear threshold of a user's day. Non-wear time comes from
+// the day's activity record, so a day without one, or with one that predates
+// non_wear_time, is never low confidence.
+func (r *Repository) lowConfidenceFlag(user, day string) string {
+	return fmt.Sprintf(`EXISTS (
+				SELECT 1 FROM activity_metrics a
+				WHERE a.user_id = %s AND a.day = %s AND a.non_wear_time > %d
+			)`, user, day, r.lowWearSeconds)
+}
+
+// selectMetrics starts a query for t's records, from its table aliased m,
+// with the fixed columns, the rest_mode and low_confidence flags and the
+// value columns, in the order scanMetric reads them
+func (r *Repository) selectMetrics(t *ingest.MetricType) string {
+	return fmt.Sprintf(`
+		SELECT id::text, user_id::text, oura_id, day, created_at, updated_at,
+			%s,
+			%s,
+			%s
+		FROM %s m`, restModeFlag("m.user_id", "m.day"), r.lowConfidenceFlag("m.user_id", "m.day"),
+		strings.Join(metricColumns(t), ", "), pgx.Identifier{t.Table}.Sanitize())
+}
+
+// metricColumns returns the quoted value columns of t's table
+func metricColumns(t *ingest.MetricType) []string {
+	cols := make([]string, len(t.Columns))
+	for i, col := range t.Columns {
+		cols[i] = pgx.Identifier{col}.Sanitize()
+	}
+	return cols
+}
+
+func (r *Repository) SaveMetric(ctx context.Context, name string, metric *interfaces.Metric) error {
+	t, err := metricType(name)
+	if err != nil {
+		return err
+	}
+
+	cols := metricColumns(t)
+	args := []interface{}{metric.UserID, metric.OuraID, metric.Day}
+	placeholders := []string{"$1", "$2", "$3"}
+	sets := []string{"day = $3"}
+	for i, col := range cols {
+		args = append(args, metric.Values[t.Columns[i]])
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+4))
+		sets = append(sets, fmt.Sprintf("%s = $%d", col, i+4))
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (user_id, oura_id, day, %s)
+		VALUES (%s)
+		ON CONFLICT (user_id, oura_id)
+		DO UPDATE SET %s, updated_at = CURRENT_TIMESTAMP
+	`, pgx.Identifier{t.Table}.Sanitize(), strings.Join(cols, ", "),
+		strings.Join(placeholders, ", "), strings.Join(sets, ", "))
+	_, err = r.db.Exec(ctx, query, args...)
+	return err
+}
+
+func (r *Repository) GetMetrics(ctx context.Context, name, userID string, startDate, endDate time.Time) ([]*interfaces.Metric, error) {
+	t, err := metricType(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.queryMetrics(ctx, t, "", userID, startDate, endDate)
+}
+
+// GetMetricsByTag returns the records a tag affected, or did not, where a
+// tag affects the record ingest.TagLags days after each day it covers
+func (r *Repository) GetMetricsByTag(ctx context.Context, name, userID string, startDate, endDate time.Time, filter interfaces.TagFilter) ([]*interfaces.Metric, error) {
+	t, err := metricType(name)
+	if err != nil {
+		return nil, err
+	}
+	lag, ok := ingest.TagLags[name]
+	if !ok {
+		return nil, fmt.Errorf("metric type %q cannot be filtered by tag", name)
+	}
+
+	exists := "EXISTS"
+	if filter.Without {
+		exists = "NOT EXISTS"
+	}
+	cond := fmt.Sprintf(`AND %s (
+			SELECT 1 FROM tags t
+			WHERE t.user_id = m.user_id
+				AND m.day - $4::int BETWEEN t.day AND COALESCE(t.end_day, t.day)
+				AND (lower(t.tag_type_code) IN (lower($5::text), 'tag_generic_' || lower($5::text)) OR lower(t.custom_name) = lower($5::text))
+		)`, exists)
+
+	return r.queryMetrics(ctx, t, cond, userID, startDate, endDate, lag, filter.Tag)
+}
+
+// queryMetrics returns t's records for a user between two days, newest
+// first, narrowed by cond over the table aliased m. $1 to $3 are the user
+// and days; args supply any further parameters cond uses.
+func (r *Repository) queryMetrics(ctx context.Context, t *ingest.MetricType, cond, userID string, startDate, endDate time.Time, args ...interface{}) ([]*interfaces.Metric, error) {
+	query := r.selectMetrics(t) + `
+		WHERE user_id = $1 AND day BETWEEN $2 AND $3 ` + cond + `
+		ORDER BY day DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, append([]interface{}{userID, startDate, endDate}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []*interfaces.Metric
+	for rows.Next() {
+		m, err := scanMetric(t, rows)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+
+	return metrics, rows.Err()
+}
+
+func (r *Repository) GetMetricByOuraID(ctx context.Context, name, userID, ouraID string) (*interfaces.Metric, error) {
+	t, err := metricType(name)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.selectMetrics(t) + `
+		WHERE user_id = $1 AND oura_id = $2
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, ouraID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanMetric(t, rows)
+}
+
+// scanMetric reads the fixed columns and flags into the entity and the
+// type's value columns into Values, leaving NULLs as nil
+func scanMetric(t *ingest.MetricType, rows pgx.Rows) (*interfaces.Metric, error) {
+	m := interfaces.Metric{Values: make(map[string]interface{}, len(t.Columns))}
+	values := make([]interface{}, len(t.Columns))
+	dest := []interface{}{&m.ID, &m.UserID, &m.OuraID, &m.Day, &m.CreatedAt, &m.UpdatedAt, &m.RestMode, &m.LowConfidence}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	for i, col := range t.Columns {
+		m.Values[col] = values[i]
+	}
+	return &m, nil
+}
+
+// Monthly trends
+
+func (r *Repository) GetMonthlyTrend(ctx context.Context, name, userID string, start, end time.Time) ([]*interfaces.MonthlyAggregate, error) {
+	t, err := metricType(name)
+	if err != nil {
+		return nil, err
+	}
+	if t.Trend == "" {
+		return nil, fmt.Errorf("metric type %q has no trend", name)
+	}
+
+	col := pgx.Identifier{t.Trend}.Sanitize()
+	query := fmt.Sprintf(`
+		SELECT date_trunc('month', day)::date, AVG(%[1]s)::float8, MIN(%[1]s)::float8, MAX(%[1]s)::float8, COUNT(%[1]s)::int,
+			BOOL_OR(%[3]s), BOOL_OR(%[4]s)
+		FROM %[2]s m
+		WHERE user_id = $1 AND day BETWEEN $2 AND $3 AND %[1]s IS NOT NULL
+		GROUP BY 1
+		ORDER BY 1
+	`, col, pgx.Identifier{t.Table}.Sanitize(), restModeFlag("m.user_id", "m.day"), r.lowConfidenceFlag("m.user_id", "m.day"))
+
+	rows, err := r.db.Query(ctx, query, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var months []*interfaces.MonthlyAggregate
+	for rows.Next() {
+		var m interfaces.MonthlyAggregate
+		if err := rows.Scan(&m.Month, &m.Average, &m.Min, &m.Max, &m.Samples, &m.RestMode, &m.LowConfidence); err != nil {
+			return nil, err
+		}
+		months = append(months, &m)
+	}
+
+	return months, rows.Err()
+}
+
+// Heart rate time series
+
+func (r *Repository) GetHeartRate(ctx context.Context, userID string, start, end time.Time) ([]*interfaces.HeartRateSample, error) {
+	// The bounds on timestamp let Postgres skip partitions outside the range
+	// Flags are looked up once per UTC day, the days samples are grouped
+	// into when they are collected
+	query := fmt.Sprintf(`
+		WITH samples AS (
+			SELECT timestamp, bpm, COALESCE(source, '') AS source, (timestamp AT TIME ZONE 'UTC')::date AS day
+			FROM heartrate
+			WHERE user_id = $1 AND timestamp >= $2 AND timestamp < $3
+		), flags AS (
+			SELECT day, %s AS rest_mode, %s AS low_confidence
+			FROM (SELECT DISTINCT day FROM samples) d
+		)
+		SELECT s.timestamp, s.bpm, s.source, f.rest_mode, f.low_confidence
+		FROM samples s JOIN flags f USING (day)
+		ORDER BY s.timestamp
+	`, restModeFlag("$1", "d.day"), r.lowConfidenceFlag("$1", "d.day"))
+
+	rows, err := r.db.Query(ctx, query, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []*interfaces.HeartRateSample
+	for rows.Next() {
+		var s interfaces.HeartRateSample
+		if err := rows.Scan(&s.Timestamp, &s.BPM, &s.Source, &s.RestMode, &s.LowConfidence); err != nil {
+			return nil, err
+		}
+		samples = append(samples, &s)
+	}
+
+	return samples, rows.Err()
+}
+
+// Dashboard aggregations
+
+// GetDashboardSummary aggregates a user's metrics over the last `days` days.
+// Unless includeFlagged is set, days in rest mode or with low wear are left
+// out and counted in ExcludedDays. TotalDays counts the distinct days with at
+// least one recorded metric; days Oura could not measure SpO2 or stress on
+// are left out of their averages. Stress and recovery are averaged in hours.
+// The weakest readiness contributor is the one with the lowest average score.
+func (r *Repository) GetDashboardSummary(ctx context.Context, userID string, days int, includeFlagged bool) (*interfaces.DashboardSummary, error) {
+	query := `
+		WITH flagged AS (
+			SELECT d::date AS day
+			FROM rest_mode_periods r, generate_series(
+				GREATEST(r.day, CURRENT_DATE - $2::int + 1)::timestamp,
+				LEAST(COALESCE(r.end_day, CURRENT_DATE), CURRENT_DATE)::timestamp,
+				interval '1 day'
+			) d
+			WHERE r.user_id = $1 AND NOT $3::bool
+			UNION
+			SELECT day FROM activity_metrics
+			WHERE user_id = $1 AND day > CURRENT_DATE - $2::int AND non_wear_time > $4::int AND NOT $3::bool
+		), sleep AS (
+			SELECT day, score, duration FROM sleep_metrics
+			WHERE user_id = $1 AND day > CURRENT_DATE - $2::int AND day NOT IN (SELECT day FROM flagged)
+		), activity AS (
+			SELECT day, score, steps FROM activity_metrics
+			WHERE user_id = $1 AND day > CURRENT_DATE - $2::int AND day NOT IN (SELECT day FROM flagged)
+		), readiness AS (
+			SELECT day, score, activity_balance, body_temperature, hrv_balance, previous_day_activity,
+				previous_night, recovery_index, resting_heart_rate, sleep_balance
+			FROM readiness_metrics
+			WHERE user_id = $1 AND day > CURRENT_DATE - $2::int AND day NOT IN (SELECT day FROM flagged)
+		), weakest AS (
+			SELECT name, score FROM (
+				SELECT
+					unnest(ARRAY['activity_balance', 'body_temperature', 'hrv_balance', 'previous_day_activity',
+						'previous_night', 'recovery_index', 'resting_heart_rate', 'sleep_balance']),
+					unnest(ARRAY[AVG(activity_balance), AVG(body_temperature), AVG(hrv_balance), AVG(previous_day_activity),
+						AVG(previous_night), AVG(recovery_index), AVG(resting_heart_rate), AVG(sleep_balance)])
+				FROM readiness
+			) c(name, score)
+			WHERE score IS NOT NULL
+			ORDER BY score, name
+			LIMIT 1
+		), spo2 AS (
+			SELECT day, spo2_average, breathing_disturbance_index FROM spo2_metrics
+			WHERE user_id = $1 AND day > CURRENT_DATE - $2::int AND day NOT IN (SELECT day FROM flagged)
+		), stress AS (
+			SELECT day, stress_high, recovery_high FROM stress_metrics
+			WHERE user_id = $1 AND day > CURRENT_DATE - $2::int AND day NOT IN (SELECT day FROM flagged)
+		), resilience AS (
+			SELECT day, sleep_recovery, daytime_recovery, stress FROM resilience_metrics
+			WHERE user_id = $1 AND day > CURRENT_DATE - $2::int AND day NOT IN (SELECT day FROM flagged)
+		)
+		SELECT
+			(SELECT COUNT(*) FROM (
+				SELECT day FROM sleep UNION SELECT day FROM activity UNION SELECT day FROM readiness
+				UNION SELECT day FROM spo2 UNION SELECT day FROM stress UNION SELECT day FROM resilience
+			) d),
+			COALESCE((SELECT AVG(score) FROM sleep), 0)::float8,
+			COALESCE((SELECT AVG(score) FROM activity), 0)::float8,
+			COALESCE((SELECT AVG(score) FROM readiness), 0)::float8,
+			COALESCE((SELECT SUM(steps) FROM activity), 0)::int,
+			COALESCE((SELECT AVG(duration) FROM sleep), 0)::float8 / 3600,
+			COALESCE((SELECT AVG(spo2_average) FROM spo2), 0)::float8,
+			COALESCE((SELECT AVG(breathing_disturbance_index) FROM spo2), 0)::float8,
+			COALESCE((SELECT AVG(stress_high) FROM stress), 0)::float8 / 3600,
+			COALESCE((SELECT AVG(recovery_high) FROM stress), 0)::float8 / 3600,
+			COALESCE((SELECT AVG(sleep_recovery) FROM resilience), 0)::float8,
+			COALESCE((SELECT AVG(daytime_recovery) FROM resilience), 0)::float8,
+			COALESCE((SELECT AVG(stress) FROM resilience), 0)::float8,
+			COALESCE((SELECT name FROM weakest), ''),
+			COALESCE((SELECT score FROM weakest), 0)::float8,
+			(SELECT COUNT(*) FROM flagged)
+	`
+
+	var summary interfaces.DashboardSummary
+	err := r.db.QueryRow(ctx, query, userID, days, includeFlagged, r.lowWearSeconds).Scan(
+		&summary.TotalDays,
+		&summary.AvgSleepScore,
+		&summary.AvgActivityScore,
+		&summary.AvgReadinessScore,
+		&summary.TotalSteps,
+		&summary.AvgSleepDuration,
+		&summary.AvgSpO2,
+		&summary.AvgBreathingDisturbanceIndex,
+		&summary.AvgStressHigh,
+		&summary.AvgRecoveryHigh,
+		&summary.AvgResilienceSleepRecovery,
+		&summary.AvgResilienceDaytimeRecovery,
+		&summary.AvgResilienceStress,
+		&summary.WeakestReadinessContributor,
+		&summary.WeakestReadinessContributorScore,
+		&summary.ExcludedDays,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
@@ -0,0 +1,366 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/api-service/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+	integration "github.com/asian-code/myapp-kubernetes/services/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsRepository_GetHeartRate_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	// Setup test container
+	pgContainer, err := integration.SetupPostgresContainer(ctx)
+	require.NoError(t, err, "Failed to start PostgreSQL container")
+	defer pgContainer.Close(ctx)
+
+	// Get database connection
+	pool, err := pgContainer.GetPool(ctx)
+	require.NoError(t, err, "Failed to connect to database")
+	defer pool.Close()
+
+	// Run migrations
+	err = pgContainer.RunMigrations(ctx, pool)
+	require.NoError(t, err, "Failed to run migrations")
+
+	// Create repository
+	repo := repository.New(pool, nil)
+
+	userID, err := repo.CreateUser(ctx, "hruser", "hr@example.com", "hashed_password")
+	require.NoError(t, err)
+
+	// Samples either side of a month boundary land in different partitions
+	samples := []time.Time{
+		time.Date(2024, 1, 31, 23, 55, 0, 0, time.UTC),
+		time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 1, 0, 5, 0, 0, time.UTC),
+	}
+	for i, ts := range samples {
+		_, err := pool.Exec(ctx, `SELECT ensure_monthly_partition('heartrate', $1)`, ts)
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, `INSERT INTO heartrate (user_id, timestamp, bpm, source) VALUES ($1, $2, $3, 'sleep')`, userID, ts, 55+i)
+		require.NoError(t, err)
+	}
+
+	var partitions int
+	err = pool.QueryRow(ctx, `SELECT count(*) FROM pg_inherits WHERE inhparent = 'heartrate'::regclass`).Scan(&partitions)
+	require.NoError(t, err)
+	assert.Equal(t, 2, partitions)
+
+	t.Run("GetHeartRate returns samples in range oldest first", func(t *testing.T) {
+		got, err := repo.GetHeartRate(ctx, userID, samples[0], samples[2])
+		assert.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.True(t, got[0].Timestamp.Equal(samples[0]))
+		assert.Equal(t, 55, got[0].BPM)
+		assert.Equal(t, "sleep", got[0].Source)
+		assert.True(t, got[1].Timestamp.Equal(samples[1]))
+	})
+}
+
+func TestMetricsRepository_GetDashboardSummary_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	pgContainer, err := integration.SetupPostgresContainer(ctx)
+	require.NoError(t, err, "Failed to start PostgreSQL container")
+	defer pgContainer.Close(ctx)
+
+	pool, err := pgContainer.GetPool(ctx)
+	require.NoError(t, err, "Failed to connect to database")
+	defer pool.Close()
+
+	err = pgContainer.RunMigrations(ctx, pool)
+	require.NoError(t, err, "Failed to run migrations")
+
+	repo := repository.New(pool, nil)
+
+	userID, err := repo.CreateUser(ctx, "dashuser", "dash@example.com", "hashed_password")
+	require.NoError(t, err)
+
+	// A night without an SpO2 reading must not drag the average to zero
+	_, err = pool.Exec(ctx, `
+		INSERT INTO spo2_metrics (user_id, oura_id, day, spo2_average, breathing_disturbance_index) VALUES
+			($1, 'o1', CURRENT_DATE, 97, 2),
+			($1, 'o2', CURRENT_DATE - 1, 95, 4),
+			($1, 'o3', CURRENT_DATE - 2, NULL, NULL)`, userID)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO stress_metrics (user_id, oura_id, day, stress_high, recovery_high, day_summary) VALUES
+			($1, 's1', CURRENT_DATE, 3600, 7200, 'normal'),
+			($1, 's2', CURRENT_DATE - 1, 7200, 3600, 'stressful')`, userID)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO resilience_metrics (user_id, oura_id, day, level, sleep_recovery, daytime_recovery, stress) VALUES
+			($1, 'r1', CURRENT_DATE, 'solid', 80, 40, 60),
+			($1, 'r2', CURRENT_DATE - 10, 'limited', 20, 10, 30)`, userID)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO readiness_metrics (user_id, oura_id, day, score, activity_balance, hrv_balance, resting_heart_rate, sleep_balance) VALUES
+			($1, 'd1', CURRENT_DATE, 70, 90, 40, 85, NULL),
+			($1, 'd2', CURRENT_DATE - 1, 80, 90, 60, 35, NULL)`, userID)
+	require.NoError(t, err)
+
+	summary, err := repo.GetDashboardSummary(ctx, userID, 7, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, summary.TotalDays)
+	assert.InDelta(t, 96.0, summary.AvgSpO2, 0.001)
+	assert.InDelta(t, 3.0, summary.AvgBreathingDisturbanceIndex, 0.001)
+	assert.InDelta(t, 1.5, summary.AvgStressHigh, 0.001)
+	assert.InDelta(t, 1.5, summary.AvgRecoveryHigh, 0.001)
+	// Resilience from ten days ago is outside the window
+	assert.InDelta(t, 80.0, summary.AvgResilienceSleepRecovery, 0.001)
+	assert.InDelta(t, 40.0, summary.AvgResilienceDaytimeRecovery, 0.001)
+	assert.InDelta(t, 60.0, summary.AvgResilienceStress, 0.001)
+	// hrv_balance averages 50, resting_heart_rate 60; sleep_balance is unscored
+	assert.Equal(t, "hrv_balance", summary.WeakestReadinessContributor)
+	assert.InDelta(t, 50.0, summary.WeakestReadinessContributorScore, 0.001)
+}
+
+func TestMetricsRepository_GetMonthlyTrend_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	pgContainer, err := integration.SetupPostgresContainer(ctx)
+	require.NoError(t, err, "Failed to start PostgreSQL container")
+	defer pgContainer.Close(ctx)
+
+	pool, err := pgContainer.GetPool(ctx)
+	require.NoError(t, err, "Failed to connect to database")
+	defer pool.Close()
+
+	err = pgContainer.RunMigrations(ctx, pool)
+	require.NoError(t, err, "Failed to run migrations")
+
+	repo := repository.New(pool, nil)
+
+	userID, err := repo.CreateUser(ctx, "trenduser", "trend@example.com", "hashed_password")
+	require.NoError(t, err)
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO vo2_max_metrics (user_id, oura_id, day, vo2_max) VALUES
+			($1, 'v1', '2024-01-05', 40),
+			($1, 'v2', '2024-01-20', 42),
+			($1, 'v3', '2024-02-10', NULL),
+			($1, 'v4', '2024-03-01', 44),
+			($1, 'v5', '2024-05-01', 50)`, userID)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)
+	months, err := repo.GetMonthlyTrend(ctx, "vo2_max", userID, start, end)
+	require.NoError(t, err)
+
+	// February has no reading and May is outside the range
+	require.Len(t, months, 2)
+	assert.True(t, months[0].Month.Equal(start))
+	assert.InDelta(t, 41.0, months[0].Average, 0.001)
+	assert.InDelta(t, 40.0, months[0].Min, 0.001)
+	assert.InDelta(t, 42.0, months[0].Max, 0.001)
+	assert.Equal(t, 2, months[0].Samples)
+	assert.True(t, months[1].Month.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+
+	_, err = repo.GetMonthlyTrend(ctx, "sleep", userID, start, end)
+	assert.Error(t, err)
+}
+
+func TestMetricsRepository_GetMetricsByTag_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	pgContainer, err := integration.SetupPostgresContainer(ctx)
+	require.NoError(t, err, "Failed to start PostgreSQL container")
+	defer pgContainer.Close(ctx)
+
+	pool, err := pgContainer.GetPool(ctx)
+	require.NoError(t, err, "Failed to connect to database")
+	defer pool.Close()
+
+	err = pgContainer.RunMigrations(ctx, pool)
+	require.NoError(t, err, "Failed to run migrations")
+
+	repo := repository.New(pool, nil)
+
+	userID, err := repo.CreateUser(ctx, "taguser", "tag@example.com", "hashed_password")
+	require.NoError(t, err)
+
+	for i := 10; i <= 14; i++ {
+		_, err := pool.Exec(ctx, `INSERT INTO sleep_metrics (user_id, oura_id, day, score, duration) VALUES ($1, $2, $3, 80, 28800)`,
+			userID, fmt.Sprintf("sleep-%d", i), time.Date(2024, 1, i, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, `INSERT INTO activity_metrics (user_id, oura_id, day, score) VALUES ($1, $2, $3, 80)`,
+			userID, fmt.Sprintf("activity-%d", i), time.Date(2024, 1, i, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+	}
+	// Alcohol on the evening of the 10th; a custom tag from the 12th to the 13th
+	_, err = pool.Exec(ctx, `
+		INSERT INTO tags (user_id, oura_id, day, tag_type_code, custom_name, start_time, end_day) VALUES
+			($1, 't1', '2024-01-10', 'tag_generic_alcohol', NULL, '2024-01-10T21:00:00Z', NULL),
+			($1, 't2', '2024-01-12', 'custom', 'Jet Lag', '2024-01-12T08:00:00Z', '2024-01-13')`, userID)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+	days := func(metrics []*interfaces.Metric) []string {
+		var out []string
+		for _, m := range metrics {
+			out = append(out, m.Day.Format("2006-01-02"))
+		}
+		return out
+	}
+
+	t.Run("sleep follows the night after a tag", func(t *testing.T) {
+		got, err := repo.GetMetricsByTag(ctx, "sleep", userID, start, end, interfaces.TagFilter{Tag: "alcohol"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2024-01-11"}, days(got))
+	})
+
+	t.Run("without excludes the affected nights", func(t *testing.T) {
+		got, err := repo.GetMetricsByTag(ctx, "sleep", userID, start, end, interfaces.TagFilter{Tag: "tag_generic_alcohol", Without: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2024-01-14", "2024-01-13", "2024-01-12", "2024-01-10"}, days(got))
+	})
+
+	t.Run("activity follows the tagged days themselves", func(t *testing.T) {
+		got, err := repo.GetMetricsByTag(ctx, "activity", userID, start, end, interfaces.TagFilter{Tag: "jet lag"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2024-01-13", "2024-01-12"}, days(got))
+	})
+
+	t.Run("types without tag filtering are rejected", func(t *testing.T) {
+		_, err := repo.GetMetricsByTag(ctx, "workout", userID, start, end, interfaces.TagFilter{Tag: "alcohol"})
+		assert.Error(t, err)
+	})
+}
+
+func TestMetricsRepository_FlaggedDays_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	pgContainer, err := integration.SetupPostgresContainer(ctx)
+	require.NoError(t, err, "Failed to start PostgreSQL container")
+	defer pgContainer.Close(ctx)
+
+	pool, err := pgContainer.GetPool(ctx)
+	require.NoError(t, err, "Failed to connect to database")
+	defer pool.Close()
+
+	err = pgContainer.RunMigrations(ctx, pool)
+	require.NoError(t, err, "Failed to run migrations")
+
+	repo := repository.New(pool, nil)
+
+	userID, err := repo.CreateUser(ctx, "flaguser", "flag@example.com", "hashed_password")
+	require.NoError(t, err)
+
+	// Yesterday and the day before in rest mode; the ring off for 10 hours today
+	_, err = pool.Exec(ctx, `
+		INSERT INTO sleep_metrics (user_id, oura_id, day, score) VALUES
+			($1, 's1', CURRENT_DATE, 90),
+			($1, 's2', CURRENT_DATE - 1, 50),
+			($1, 's3', CURRENT_DATE - 2, 50),
+			($1, 's4', CURRENT_DATE - 3, 70)`, userID)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO activity_metrics (user_id, oura_id, day, score, non_wear_time) VALUES
+			($1, 'a1', CURRENT_DATE, 40, 36000),
+			($1, 'a2', CURRENT_DATE - 3, 80, 600)`, userID)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO rest_mode_periods (user_id, oura_id, day, start_time, end_day) VALUES
+			($1, 'r1', CURRENT_DATE - 2, CURRENT_DATE - 2, CURRENT_DATE - 1)`, userID)
+	require.NoError(t, err)
+
+	t.Run("dashboard excludes flagged days by default", func(t *testing.T) {
+		summary, err := repo.GetDashboardSummary(ctx, userID, 7, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.TotalDays)
+		assert.InDelta(t, 70.0, summary.AvgSleepScore, 0.001)
+		assert.InDelta(t, 80.0, summary.AvgActivityScore, 0.001)
+		assert.Equal(t, 3, summary.ExcludedDays)
+	})
+
+	t.Run("dashboard includes flagged days on request", func(t *testing.T) {
+		summary, err := repo.GetDashboardSummary(ctx, userID, 7, true)
+		require.NoError(t, err)
+		assert.Equal(t, 4, summary.TotalDays)
+		assert.InDelta(t, 65.0, summary.AvgSleepScore, 0.001)
+		assert.Equal(t, 0, summary.ExcludedDays)
+	})
+
+	t.Run("records carry their flags", func(t *testing.T) {
+		got, err := repo.GetMetrics(ctx, "sleep", userID, time.Now().AddDate(0, 0, -7), time.Now())
+		require.NoError(t, err)
+		require.Len(t, got, 4)
+		flags := make(map[string][2]bool)
+		for _, m := range got {
+			flags[m.OuraID] = [2]bool{m.RestMode, m.LowConfidence}
+		}
+		assert.Equal(t, [2]bool{false, true}, flags["s1"])
+		assert.Equal(t, [2]bool{true, false}, flags["s2"])
+		assert.Equal(t, [2]bool{true, false}, flags["s3"])
+		assert.Equal(t, [2]bool{false, false}, flags["s4"])
+	})
+
+	t.Run("low wear threshold is configurable", func(t *testing.T) {
+		lenient := repository.New(pool, nil).WithLowWearThreshold(12 * time.Hour)
+		got, err := lenient.GetMetrics(ctx, "sleep", userID, time.Now().AddDate(0, 0, -1), time.Now())
+		require.NoError(t, err)
+		for _, m := range got {
+			assert.False(t, m.LowConfidence, "record %s", m.OuraID)
+		}
+	})
+
+	t.Run("heart rate samples carry their day's flags", func(t *testing.T) {
+		for _, day := range []string{"CURRENT_DATE - 1", "CURRENT_DATE - 3", "CURRENT_DATE"} {
+			ts := "(" + day + ") + time '12:00'"
+			_, err := pool.Exec(ctx, `SELECT ensure_monthly_partition('heartrate', `+ts+`)`)
+			require.NoError(t, err)
+			_, err = pool.Exec(ctx, `INSERT INTO heartrate (user_id, timestamp, bpm) VALUES ($1, `+ts+`, 60)`, userID)
+			require.NoError(t, err)
+		}
+
+		got, err := repo.GetHeartRate(ctx, userID, time.Now().AddDate(0, 0, -7), time.Now().AddDate(0, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, got, 3)
+		assert.Equal(t, [2]bool{false, false}, [2]bool{got[0].RestMode, got[0].LowConfidence})
+		assert.Equal(t, [2]bool{true, false}, [2]bool{got[1].RestMode, got[1].LowConfidence})
+		assert.Equal(t, [2]bool{false, true}, [2]bool{got[2].RestMode, got[2].LowConfidence})
+	})
+
+	t.Run("trend months carry their readings' flags", func(t *testing.T) {
+		_, err := pool.Exec(ctx, `INSERT INTO vo2_max_metrics (user_id, oura_id, day, vo2_max) VALUES ($1, 'v1', CURRENT_DATE - 2, 40)`, userID)
+		require.NoError(t, err)
+
+		months, err := repo.GetMonthlyTrend(ctx, "vo2_max", userID, time.Now().AddDate(0, 0, -7), time.Now())
+		require.NoError(t, err)
+		require.Len(t, months, 1)
+		assert.True(t, months[0].RestMode)
+		assert.False(t, months[0].LowConfidence)
+	})
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+	"github.com/jackc/pgx/v5"
+)
+
+const userColumns = `id, username, email, password_hash, created_at, updated_at, last_login, is_active`
+
+func (r *Repository) CreateUser(ctx context.Context, username, email, passwordHash string) (string, error) {
+	query := `
+		INSERT INTO users (username, email, password_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+
+	var userID string
+	err := r.db.QueryRow(ctx, query, username, email, passwordHash).Scan(&userID)
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+func (r *Repository) GetUserByID(ctx context.Context, userID string) (*interfaces.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return r.getUser(ctx, query, userID)
+}
+
+func (r *Repository) GetUserByUsername(ctx context.Context, username string) (*interfaces.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
+	return r.getUser(ctx, query, username)
+}
+
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*interfaces.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+	return r.getUser(ctx, query, email)
+}
+
+// getUser runs a single-user lookup, returning nil when no row matches
+func (r *Repository) getUser(ctx context.Context, query string, arg interface{}) (*interfaces.User, error) {
+	var user interfaces.User
+	var isActive *bool
+	err := r.db.QueryRow(ctx, query, arg).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.LastLogin,
+		&isActive,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// is_active defaults to true; treat a missing value the same way
+	user.IsActive = isActive == nil || *isActive
+
+	return &user, nil
+}
+
+func (r *Repository) UpdateLastLogin(ctx context.Context, userID string) error {
+	query := `
+		UPDATE users
+		SET last_login = $1, updated_at = $1
+		WHERE id = $2
+	`
+
+	_, err := r.db.Exec(ctx, query, time.Now(), userID)
+	return err
+}
+
+func (r *Repository) UpdateUser(ctx context.Context, user *interfaces.User) error {
+	query := `
+		UPDATE users
+		SET email = $1, password_hash = $2, is_active = $3, updated_at = $4
+		WHERE id = $5
+	`
+
+	result, err := r.db.Exec(ctx, query, user.Email, user.PasswordHash, user.IsActive, time.Now(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteUser(ctx context.Context, userID string) error {
+	query := `DELETE FROM users WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, userID)
+	return err
+}
@@ -106,8 +106,7 @@ func TestUserRepository_CreateAndGet_Integration(t *testing.T) {
 
 		// Update last login
 		time.Sleep(100 * time.Millisecond) // Ensure time difference
-		loginTime := time.Now()
-		err = repo.UpdateLastLogin(ctx, userID, loginTime)
+		err = repo.UpdateLastLogin(ctx, userID)
 		assert.NoError(t, err)
 
 		// Verify update
@@ -127,15 +126,13 @@ func TestUserRepository_CreateAndGet_Integration(t *testing.T) {
 		assert.NoError(t, err)
 
 		// Get user
-		_, err = repo.GetUserByID(ctx, userID)
+		user, err := repo.GetUserByID(ctx, userID)
 		assert.NoError(t, err)
 
 		// Update user
-		updates := map[string]interface{}{
-			"email":     "updated@example.com",
-			"is_active": false,
-		}
-		err = repo.UpdateUser(ctx, userID, updates)
+		user.Email = "updated@example.com"
+		user.IsActive = false
+		err = repo.UpdateUser(ctx, user)
 		assert.NoError(t, err)
 
 		// Verify update
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/config"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/deadletter"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/reprocess"
+	log "github.com/sirupsen/logrus"
+)
+
+const usage = `Usage: data-processor [command]
+
+With no command, runs the ingest HTTP server and queue workers.
+
+Commands:
+  dead-letters list [--source S] [--type T] [--limit N] [--offset N]
+  dead-letters show <id>
+  dead-letters edit <id> [--file PATH]   (payload from PATH, or stdin if omitted or "-")
+  dead-letters replay <id>
+  reprocess --user ID --from YYYY-MM-DD [--to YYYY-MM-DD] [--type T,...] [--dry-run]
+`
+
+// runCommand runs a CLI subcommand and returns the process exit code.
+func runCommand(args []string, cfg *config.Config, log *log.Entry) int {
+	switch args[0] {
+	case "dead-letters", "reprocess":
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	ctx := context.Background()
+	db, err := connect(ctx, cfg)
+	if err != nil {
+		log.WithError(err).Error("Failed to connect to database")
+		return 1
+	}
+	defer db.Close()
+
+	repo := repository.New(db, log)
+	if args[0] == "reprocess" {
+		err = runReprocess(ctx, reprocess.New(repo), args[1:], os.Stdout)
+	} else {
+		err = runDeadLetters(ctx, deadletter.New(repo), args[1:], os.Stdin, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func runDeadLetters(ctx context.Context, svc *deadletter.Service, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand\n\n%s", usage)
+	}
+
+	fs := flag.NewFlagSet("dead-letters "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "list":
+		var filter repository.DeadLetterFilter
+		fs.StringVar(&filter.Source, "source", "", "only dead letters rejected by ingest, batch or queue")
+		fs.StringVar(&filter.DataType, "type", "", "only dead letters of this metric type")
+		fs.IntVar(&filter.Limit, "limit", deadletter.DefaultLimit, "maximum number to list")
+		fs.IntVar(&filter.Offset, "offset", 0, "number to skip")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		letters, err := svc.List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		return printDeadLetters(stdout, letters)
+
+	case "show":
+		id, err := parseID(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		dl, err := svc.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		return printJSON(stdout, dl)
+
+	case "edit":
+		file := fs.String("file", "-", "file holding the new payload, or - for stdin")
+		id, err := parseID(fs, args[1:])
+		if err != nil {
+			return err
+		}
+
+		var payload []byte
+		if *file == "-" {
+			payload, err = io.ReadAll(stdin)
+		} else {
+			payload, err = os.ReadFile(*file)
+		}
+		if err != nil {
+			return err
+		}
+
+		dl, err := svc.Edit(ctx, id, payload)
+		if err != nil {
+			return err
+		}
+		return printJSON(stdout, dl)
+
+	case "replay":
+		id, err := parseID(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		jobID, err := svc.Replay(ctx, id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "dead letter %d queued as job %d\n", id, jobID)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown subcommand %q\n\n%s", args[0], usage)
+	}
+}
+
+// parseID parses flags and a single dead-letter ID argument, which may come
+// before or after the flags.
+func parseID(fs *flag.FlagSet, args []string) (int64, error) {
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	rest := fs.Args()
+	if len(rest) == 0 {
+		return 0, fmt.Errorf("missing dead letter id")
+	}
+	id, err := strconv.ParseInt(rest[0], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid dead letter id %q", rest[0])
+	}
+	if err := fs.Parse(rest[1:]); err != nil {
+		return 0, err
+	}
+	if fs.NArg() > 0 {
+		return 0, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return id, nil
+}
+
+func printDeadLetters(w io.Writer, letters []*deadletter.DeadLetter) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSOURCE\tTYPE\tUSER\tATTEMPTS\tLAST FAILED\tERROR")
+	for _, dl := range letters {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			dl.ID, dl.Source, orDash(dl.Type), orDash(dl.UserID), dl.Attempts,
+			dl.LastFailedAt.Format(time.RFC3339), truncate(dl.Error, 80))
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
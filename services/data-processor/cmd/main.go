@@ -9,31 +9,35 @@ import (
 	"time"
 
 	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/config"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/deadletter"
 	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/handler"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/processor"
 	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/worker"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/middleware"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/signing"
 	"github.com/asian-code/myapp-kubernetes/services/shared/database"
 	"github.com/asian-code/myapp-kubernetes/services/shared/logger"
 	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func main() {
 	log := logger.Init("data-processor")
 	cfg := config.Load()
 
+	if len(os.Args) > 1 {
+		// Subcommands print their results to stdout, so keep logs on stderr
+		log.Logger.SetOutput(os.Stderr)
+		os.Exit(runCommand(os.Args[1:], cfg, log))
+	}
+
 	log.Info("Starting data-processor service")
 
 	// Connect to database
 	ctx := context.Background()
-	db, err := database.NewPool(ctx, database.Config{
-		Host:     cfg.DBHost,
-		Port:     cfg.DBPort,
-		User:     cfg.DBUser,
-		Password: cfg.DBPassword,
-		Database: cfg.DBName,
-		MaxConns: cfg.DBMaxConns,
-		SSLMode:  cfg.DBSSLMode,
-	})
+	db, err := connect(ctx, cfg)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to connect to database")
 	}
@@ -48,15 +52,48 @@ func main() {
 	m := metrics.New("data-processor")
 
 	// Create handler
-	h := handler.New(repo, log, m)
+	proc := processor.New(m)
+	h := handler.New(repo, deadletter.New(repo), log, m)
+
+	// Start ingest queue workers
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	pool := worker.New(repo, proc, cfg.Queue(), log, m)
+	workersDone := make(chan struct{})
+	go func() {
+		pool.Run(workerCtx)
+		close(workersDone)
+	}()
 
 	// Setup router
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/ingest", h.Ingest).Methods("POST")
-	router.HandleFunc("/api/v1/metrics/{type}", h.GetMetrics).Methods("GET")
 	router.HandleFunc("/health", h.Health).Methods("GET")
 	router.HandleFunc("/metrics", h.PrometheusMetrics).Methods("GET")
 
+	// Service API, callable only with a signed request
+	signingKeys, err := signing.ParseKeys(cfg.SigningKeys)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid SIGNING_KEYS")
+	}
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(middleware.VerifySignature(signing.NewVerifier(signingKeys, repo), handler.MaxBatchBytes, log))
+
+	ttl := cfg.IdempotencyTTL()
+	api.HandleFunc("/ingest", h.Idempotent("/ingest", ttl, handler.MaxRecordBytes, h.Ingest)).Methods("POST")
+	api.HandleFunc("/ingest/batch", h.Idempotent("/ingest/batch", ttl, handler.MaxBatchBytes, h.IngestBatch)).Methods("POST")
+	api.HandleFunc("/metrics/{type}", h.GetMetrics).Methods("GET")
+
+	// Admin routes, enabled by ADMIN_TOKEN
+	if cfg.AdminToken != "" {
+		admin := router.PathPrefix("/admin").Subrouter()
+		admin.Use(middleware.BearerToken(cfg.AdminToken, log))
+		admin.HandleFunc("/dead-letters", h.ListDeadLetters).Methods("GET")
+		admin.HandleFunc("/dead-letters/{id}", h.GetDeadLetter).Methods("GET")
+		admin.HandleFunc("/dead-letters/{id}", h.EditDeadLetter).Methods("PUT")
+		admin.HandleFunc("/dead-letters/{id}/replay", h.ReplayDeadLetter).Methods("POST")
+	} else {
+		log.Warn("ADMIN_TOKEN is not set; admin API disabled")
+	}
+
 	// Setup HTTP server
 	srv := &http.Server{
 		Addr:         ":8080",
@@ -89,5 +126,25 @@ func main() {
 		log.WithError(err).Fatal("Server forced to shutdown")
 	}
 
+	// Let workers finish their in-flight jobs
+	stopWorkers()
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		log.Warn("Timed out waiting for ingest workers")
+	}
+
 	log.Info("Server exited")
 }
+
+func connect(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	return database.NewPool(ctx, database.Config{
+		Host:     cfg.DBHost,
+		Port:     cfg.DBPort,
+		User:     cfg.DBUser,
+		Password: cfg.DBPassword,
+		Database: cfg.DBName,
+		MaxConns: cfg.DBMaxConns,
+		SSLMode:  cfg.DBSSLMode,
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/reprocess"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+)
+
+const dateLayout = "2006-01-02"
+
+func runReprocess(ctx context.Context, r *reprocess.Reprocessor, args []string, stdout io.Writer) error {
+	var opts reprocess.Options
+	var from, to, types string
+	fs := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	fs.StringVar(&opts.UserID, "user", "", "user whose metrics to rebuild")
+	fs.StringVar(&from, "from", "", "first day to rebuild (YYYY-MM-DD)")
+	fs.StringVar(&to, "to", time.Now().UTC().Format(dateLayout), "last day to rebuild (YYYY-MM-DD)")
+	fs.StringVar(&types, "type", "", "comma separated data types to rebuild (default all)")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "print what would change without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if opts.UserID == "" {
+		return fmt.Errorf("--user is required")
+	}
+	var err error
+	if opts.From, err = time.Parse(dateLayout, from); err != nil {
+		return fmt.Errorf("invalid --from %q", from)
+	}
+	if opts.To, err = time.Parse(dateLayout, to); err != nil {
+		return fmt.Errorf("invalid --to %q", to)
+	}
+	if opts.To.Before(opts.From) {
+		return fmt.Errorf("--to %s is before --from %s", to, from)
+	}
+	if opts.Types, err = parseTypes(types); err != nil {
+		return err
+	}
+
+	summary, err := r.Run(ctx, opts, func(res *reprocess.Result) {
+		printResult(stdout, res)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%d documents: %d created, %d updated, %d unchanged, %d failed",
+		summary.Documents, summary.Created, summary.Updated, summary.Unchanged, summary.Failed)
+	if opts.DryRun {
+		fmt.Fprint(stdout, " (dry run, nothing written)")
+	}
+	fmt.Fprintln(stdout)
+
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d documents failed", summary.Failed)
+	}
+	return nil
+}
+
+func parseTypes(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var supported []string
+	for _, t := range ingest.Records() {
+		supported = append(supported, t.Name)
+	}
+
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if mt, ok := ingest.Lookup(t); !ok || mt.Series {
+			return nil, fmt.Errorf("unsupported data type %q (supported: %s)", t, strings.Join(supported, ", "))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// printResult prints one line per document that would change or failed, in
+// a diff-like format: + created, ~ updated, ! failed.
+func printResult(w io.Writer, res *reprocess.Result) {
+	prefix := fmt.Sprintf("%s %s %s", res.Type, res.Day.Format(dateLayout), res.UpstreamID)
+	switch res.Status {
+	case reprocess.StatusCreated:
+		fmt.Fprintf(w, "+ %s\n", prefix)
+	case reprocess.StatusUpdated:
+		changes := make([]string, len(res.Changes))
+		for i, c := range res.Changes {
+			changes[i] = fmt.Sprintf("%s %s -> %s", c.Field, c.Old, c.New)
+		}
+		fmt.Fprintf(w, "~ %s: %s\n", prefix, strings.Join(changes, ", "))
+	case reprocess.StatusFailed:
+		fmt.Fprintf(w, "! %s: %v\n", prefix, res.Err)
+	}
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/worker"
 	"github.com/asian-code/myapp-kubernetes/services/pkg/validation"
 )
 
@@ -16,12 +18,34 @@ type Config struct {
 	DBSSLMode  string `validate:"required,oneof=disable require verify-ca verify-full"`
 	DBMaxConns int    `validate:"required,min=1,max=100"`
 	LogLevel   string `validate:"required,oneof=debug info warn error"`
+
+	// SigningKeys is "id:secret[,id:secret]"; /api/v1 requests must be
+	// signed with one of them
+	SigningKeys string `validate:"required"`
+
+	// AdminToken enables the /admin API when set
+	AdminToken string
+
+	// IdempotencyTTLSeconds is how long ingest responses are kept for
+	// requests sent with an idempotency key
+	IdempotencyTTLSeconds int `validate:"required,min=60"`
+
+	// Ingest queue workers
+	QueueWorkers        int `validate:"required,min=1,max=64"`
+	QueueMaxAttempts    int `validate:"required,min=1"`
+	QueuePollIntervalMs int `validate:"required,min=10"`
+	QueueBackoffBaseMs  int `validate:"required,min=1"`
 }
 
 // Load loads and validates configuration from environment variables
 func Load() *Config {
 	dbPort, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
 	dbMaxConns, _ := strconv.Atoi(getEnv("DB_MAX_CONNS", "10"))
+	idempotencyTTL, _ := strconv.Atoi(getEnv("IDEMPOTENCY_TTL_SECONDS", "86400"))
+	queueWorkers, _ := strconv.Atoi(getEnv("QUEUE_WORKERS", "4"))
+	queueMaxAttempts, _ := strconv.Atoi(getEnv("QUEUE_MAX_ATTEMPTS", "5"))
+	queuePollIntervalMs, _ := strconv.Atoi(getEnv("QUEUE_POLL_INTERVAL_MS", "1000"))
+	queueBackoffBaseMs, _ := strconv.Atoi(getEnv("QUEUE_BACKOFF_BASE_MS", "1000"))
 
 	cfg := &Config{
 		DBHost:     getEnv("DB_HOST", "localhost"),
@@ -32,6 +56,16 @@ func Load() *Config {
 		DBSSLMode:  getEnv("DB_SSLMODE", "require"),
 		DBMaxConns: dbMaxConns,
 		LogLevel:   getEnv("LOG_LEVEL", "info"),
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+
+		SigningKeys: os.Getenv("SIGNING_KEYS"),
+
+		IdempotencyTTLSeconds: idempotencyTTL,
+
+		QueueWorkers:        queueWorkers,
+		QueueMaxAttempts:    queueMaxAttempts,
+		QueuePollIntervalMs: queuePollIntervalMs,
+		QueueBackoffBaseMs:  queueBackoffBaseMs,
 	}
 
 	// Validate configuration and panic if invalid
@@ -40,6 +74,21 @@ func Load() *Config {
 	return cfg
 }
 
+// IdempotencyTTL returns how long idempotent responses are kept.
+func (c *Config) IdempotencyTTL() time.Duration {
+	return time.Duration(c.IdempotencyTTLSeconds) * time.Second
+}
+
+// Queue returns the worker pool settings.
+func (c *Config) Queue() worker.Config {
+	return worker.Config{
+		Workers:      c.QueueWorkers,
+		MaxAttempts:  c.QueueMaxAttempts,
+		PollInterval: time.Duration(c.QueuePollIntervalMs) * time.Millisecond,
+		BackoffBase:  time.Duration(c.QueueBackoffBaseMs) * time.Millisecond,
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
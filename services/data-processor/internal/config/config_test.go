@@ -3,12 +3,17 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
+const testSigningKeys = "k1:0123456789abcdef0123456789abcdef"
+
 func TestLoad_Success(t *testing.T) {
 	// Set required environment variables
 	os.Setenv("DB_PASSWORD", "test_password_1234567890123456")
+	os.Setenv("SIGNING_KEYS", testSigningKeys)
 	defer os.Unsetenv("DB_PASSWORD")
+	defer os.Unsetenv("SIGNING_KEYS")
 
 	cfg := Load()
 
@@ -48,6 +53,7 @@ func TestLoad_CustomValues(t *testing.T) {
 	os.Setenv("DB_PORT", "3306")
 	os.Setenv("DB_USER", "custom_user")
 	os.Setenv("DB_PASSWORD", "custom_password_1234567890123456")
+	os.Setenv("SIGNING_KEYS", testSigningKeys)
 	os.Setenv("DB_NAME", "custom_db")
 	os.Setenv("DB_SSLMODE", "disable")
 	os.Setenv("LOG_LEVEL", "debug")
@@ -78,3 +84,25 @@ func TestLoad_CustomValues(t *testing.T) {
 		t.Errorf("expected DBSSLMode to be disable, got %s", cfg.DBSSLMode)
 	}
 }
+
+func TestLoad_QueueDefaults(t *testing.T) {
+	os.Setenv("DB_PASSWORD", "test_password_1234567890123456")
+	os.Setenv("SIGNING_KEYS", testSigningKeys)
+	defer os.Unsetenv("DB_PASSWORD")
+	defer os.Unsetenv("SIGNING_KEYS")
+
+	q := Load().Queue()
+
+	if q.Workers != 4 {
+		t.Errorf("expected 4 workers, got %d", q.Workers)
+	}
+	if q.MaxAttempts != 5 {
+		t.Errorf("expected 5 max attempts, got %d", q.MaxAttempts)
+	}
+	if q.PollInterval != time.Second {
+		t.Errorf("expected 1s poll interval, got %v", q.PollInterval)
+	}
+	if q.BackoffBase != time.Second {
+		t.Errorf("expected 1s backoff base, got %v", q.BackoffBase)
+	}
+}
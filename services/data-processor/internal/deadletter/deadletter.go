@@ -0,0 +1,224 @@
+// Package deadletter keeps records that could not be stored so they can be
+// inspected, corrected and replayed through the ingest queue.
+package deadletter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/validation"
+)
+
+// Where a dead letter was rejected.
+const (
+	SourceIngest = "ingest"
+	SourceBatch  = "batch"
+	SourceQueue  = "queue"
+)
+
+const (
+	// DefaultLimit is the page size used when a list request sets none
+	DefaultLimit = 50
+	// MaxLimit caps the page size of a list request
+	MaxLimit = 500
+)
+
+// Record stores a record that could not be stored using repo, which may be
+// bound to a transaction. env may be nil if raw could not be decoded, in which
+// case the type and user are taken from raw when it has them.
+func Record(ctx context.Context, repo *repository.Repository, source string, raw []byte, env *ingest.Envelope, attempts int, cause error) error {
+	dl := &repository.DeadLetter{
+		Source:   source,
+		Payload:  raw,
+		Error:    reason(cause),
+		Attempts: attempts,
+	}
+	if env != nil {
+		dl.DataType = env.Type
+		dl.UserID = env.UserID
+	} else {
+		dl.DataType, dl.UserID = hints(raw)
+	}
+	_, err := repo.SaveDeadLetter(ctx, dl)
+	return err
+}
+
+// hints leniently reads the type and user of a record that failed to decode
+// as an envelope, so it can still be filtered by type. Either is empty if
+// missing or malformed.
+func hints(raw []byte) (dataType, userID string) {
+	var hint struct {
+		Type   string `json:"type" validate:"max=50"`
+		UserID string `json:"user_id" validate:"omitempty,uuid"`
+	}
+	if err := json.Unmarshal(raw, &hint); err != nil {
+		return "", ""
+	}
+	if err := validation.Validate(&hint); err != nil {
+		return "", ""
+	}
+	return hint.Type, hint.UserID
+}
+
+// DeadLetter is the API and CLI view of a dead letter. Payload is embedded as
+// JSON when it is valid JSON and as a string otherwise.
+type DeadLetter struct {
+	ID            int64       `json:"id"`
+	Source        string      `json:"source"`
+	Type          string      `json:"type,omitempty"`
+	UserID        string      `json:"user_id,omitempty"`
+	Payload       interface{} `json:"payload"`
+	Error         string      `json:"error"`
+	Attempts      int         `json:"attempts"`
+	FirstFailedAt time.Time   `json:"first_failed_at"`
+	LastFailedAt  time.Time   `json:"last_failed_at"`
+}
+
+func view(dl *repository.DeadLetter) *DeadLetter {
+	var payload interface{} = string(dl.Payload)
+	if json.Valid(dl.Payload) {
+		payload = json.RawMessage(dl.Payload)
+	}
+	return &DeadLetter{
+		ID:            dl.ID,
+		Source:        dl.Source,
+		Type:          dl.DataType,
+		UserID:        dl.UserID,
+		Payload:       payload,
+		Error:         dl.Error,
+		Attempts:      dl.Attempts,
+		FirstFailedAt: dl.FirstFailedAt,
+		LastFailedAt:  dl.LastFailedAt,
+	}
+}
+
+// Service lists, edits and replays dead letters.
+type Service struct {
+	repo *repository.Repository
+}
+
+func New(repo *repository.Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Record stores a record that could not be stored. See Record.
+func (s *Service) Record(ctx context.Context, source string, raw []byte, env *ingest.Envelope, attempts int, cause error) error {
+	return Record(ctx, s.repo, source, raw, env, attempts, cause)
+}
+
+// List returns dead letters, most recently failed first.
+func (s *Service) List(ctx context.Context, filter repository.DeadLetterFilter) ([]*DeadLetter, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	if filter.Limit > MaxLimit {
+		filter.Limit = MaxLimit
+	}
+
+	letters, err := s.repo.ListDeadLetters(ctx, filter)
+	if err != nil {
+		return nil, apperrors.Database(err, "Failed to list dead letters")
+	}
+
+	views := make([]*DeadLetter, 0, len(letters))
+	for _, dl := range letters {
+		views = append(views, view(dl))
+	}
+	return views, nil
+}
+
+// Get returns one dead letter.
+func (s *Service) Get(ctx context.Context, id int64) (*DeadLetter, error) {
+	dl, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return view(dl), nil
+}
+
+// Edit replaces a dead letter's payload, for example to correct a field
+// before replaying it.
+func (s *Service) Edit(ctx context.Context, id int64, payload []byte) (*DeadLetter, error) {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return nil, apperrors.BadRequest("payload is required")
+	}
+
+	found, err := s.repo.UpdateDeadLetterPayload(ctx, id, payload)
+	if repository.IsUniqueViolation(err) {
+		return nil, apperrors.Conflict("another dead letter already has this payload")
+	}
+	if err != nil {
+		return nil, apperrors.Database(err, "Failed to update dead letter")
+	}
+	if !found {
+		return nil, notFound(id)
+	}
+	return s.Get(ctx, id)
+}
+
+// Replay validates a dead letter's payload as an ingest envelope and moves it
+// to the ingest queue, returning the queue job ID. A payload that is still
+// invalid stays dead-lettered with its attempt count incremented.
+func (s *Service) Replay(ctx context.Context, id int64) (int64, error) {
+	dl, err := s.get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	env, err := ingest.Decode(bytes.NewReader(dl.Payload))
+	if err != nil {
+		if recErr := s.repo.RecordDeadLetterFailure(ctx, id, reason(err)); recErr != nil {
+			return 0, apperrors.Database(recErr, "Failed to update dead letter")
+		}
+		return 0, err
+	}
+
+	var jobID int64
+	err = s.repo.InTx(ctx, func(tx *repository.Repository) error {
+		var err error
+		if jobID, err = tx.Enqueue(ctx, env); err != nil {
+			return err
+		}
+		return tx.DeleteDeadLetter(ctx, id)
+	})
+	if err != nil {
+		return 0, apperrors.Database(err, "Failed to replay dead letter")
+	}
+	return jobID, nil
+}
+
+func (s *Service) get(ctx context.Context, id int64) (*repository.DeadLetter, error) {
+	dl, err := s.repo.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, apperrors.Database(err, "Failed to get dead letter")
+	}
+	if dl == nil {
+		return nil, notFound(id)
+	}
+	return dl, nil
+}
+
+func notFound(id int64) error {
+	return apperrors.NotFound(fmt.Sprintf("dead letter %d not found", id))
+}
+
+// reason describes err for the dead letter's error column, keeping the
+// details a client error carries.
+func reason(err error) string {
+	appErr := apperrors.GetAppError(err)
+	if appErr == nil {
+		return err.Error()
+	}
+	if len(appErr.Details) == 0 {
+		return appErr.Message
+	}
+	details, _ := json.Marshal(appErr.Details)
+	return fmt.Sprintf("%s %s", appErr.Message, details)
+}
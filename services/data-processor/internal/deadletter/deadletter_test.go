@@ -0,0 +1,49 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+)
+
+func TestHints(t *testing.T) {
+	tests := []struct {
+		raw        string
+		wantType   string
+		wantUserID string
+	}{
+		{`{"type":"sleep","user_id":"6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b","schema_version":9}`, "sleep", "6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b"},
+		{`{"type":"sleep","user_id":"not-a-uuid"}`, "", ""},
+		{`{"type":"sleep"}`, "sleep", ""},
+		{`garbage`, "", ""},
+	}
+
+	for _, tt := range tests {
+		dataType, userID := hints([]byte(tt.raw))
+		if dataType != tt.wantType || userID != tt.wantUserID {
+			t.Errorf("hints(%s) = %q, %q; want %q, %q", tt.raw, dataType, userID, tt.wantType, tt.wantUserID)
+		}
+	}
+}
+
+func TestViewPayload(t *testing.T) {
+	valid, _ := json.Marshal(view(&repository.DeadLetter{Payload: []byte(`{"a":1}`)}))
+	if want := `"payload":{"a":1}`; !strings.Contains(string(valid), want) {
+		t.Errorf("Expected JSON payload to be embedded, got %s", valid)
+	}
+
+	invalid, _ := json.Marshal(view(&repository.DeadLetter{Payload: []byte(`{not json`)}))
+	if want := `"payload":"{not json"`; !strings.Contains(string(invalid), want) {
+		t.Errorf("Expected invalid payload as a string, got %s", invalid)
+	}
+}
+
+func TestReasonKeepsDetails(t *testing.T) {
+	err := apperrors.ValidationFailed("unknown metric type").WithDetails("type", "steps")
+	if got, want := reason(err), `unknown metric type {"type":"steps"}`; got != want {
+		t.Errorf("reason() = %q, want %q", got, want)
+	}
+}
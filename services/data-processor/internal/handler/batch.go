@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/deadletter"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/processor"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+)
+
+const (
+	// maxBatchRecords caps the number of records in one batch request
+	maxBatchRecords = 10000
+	// MaxBatchBytes caps the size of a batch request body
+	MaxBatchBytes = 64 << 20
+	// batchChunkSize is the number of records queued per transaction
+	batchChunkSize = 500
+)
+
+// Per-record batch outcomes.
+const (
+	StatusQueued    = "queued"
+	StatusDuplicate = "duplicate"
+	StatusInvalid   = "invalid"
+)
+
+// RecordResult is the outcome for one record of a batch, in request order.
+// ID is the queue job of a queued record.
+type RecordResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     int64  `json:"id,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// BatchResponse summarises a batch ingest.
+type BatchResponse struct {
+	Queued    int            `json:"queued"`
+	Duplicate int            `json:"duplicate"`
+	Invalid   int            `json:"invalid"`
+	Results   []RecordResult `json:"results"`
+}
+
+// batchRecord is one raw record read from a batch body. err is set if the
+// record could not be read as JSON.
+type batchRecord struct {
+	raw []byte
+	err error
+}
+
+// IngestBatch validates a JSON array or an NDJSON stream (Content-Type
+// application/x-ndjson) of ingest envelopes of any type and queues the valid
+// ones for the worker pool, as Ingest does, responding 202 Accepted. Every
+// record gets a result: queued with its job ID, duplicate (identical to what
+// is already stored, so not queued) or invalid with a reason.
+func (h *Handler) IngestBatch(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status := http.StatusAccepted
+	defer func() {
+		h.metrics.RecordHTTPRequest(r.Method, "/ingest/batch", status, time.Since(start))
+	}()
+
+	logger := h.logger.WithField("request_id", r.Context().Value("request-id"))
+	fail := func(err error) {
+		status = http.StatusInternalServerError
+		if appErr := apperrors.GetAppError(err); appErr != nil {
+			status = appErr.StatusCode
+		}
+		apperrors.WriteError(w, logger, err)
+	}
+
+	body := http.MaxBytesReader(w, r.Body, MaxBatchBytes)
+	var records []batchRecord
+	var err error
+	if isNDJSON(r.Header.Get("Content-Type")) {
+		records, err = readNDJSON(body)
+	} else {
+		records, err = readJSONArray(body)
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	resp, err := h.queueRecords(r.Context(), records)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	logger.WithField("queued", resp.Queued).
+		WithField("duplicate", resp.Duplicate).
+		WithField("invalid", resp.Invalid).
+		Info("Queued batch")
+
+	apperrors.WriteSuccess(w, resp, status)
+}
+
+// queueRecords validates every record, then queues the valid ones that are
+// not already stored batchChunkSize per transaction. Invalid records are
+// kept in the dead-letter store.
+func (h *Handler) queueRecords(ctx context.Context, records []batchRecord) (*BatchResponse, error) {
+	results := make([]RecordResult, len(records))
+	envs := make([]*ingest.Envelope, len(records))
+
+	resp := &BatchResponse{Results: results}
+	for i, rec := range records {
+		results[i].Index = i
+		err := rec.err
+		if err == nil {
+			envs[i], err = ingest.Decode(bytes.NewReader(rec.raw))
+		}
+		if err != nil {
+			results[i].Status = StatusInvalid
+			results[i].Reason = reason(err)
+			resp.Invalid++
+			h.deadLetter(ctx, deadletter.SourceBatch, rec.raw, nil, err)
+			continue
+		}
+		results[i].Type = envs[i].Type
+	}
+
+	var pending []int
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := h.repo.InTx(ctx, func(tx *repository.Repository) error {
+			for _, i := range pending {
+				stored, err := processor.Stored(ctx, tx, envs[i])
+				if err != nil {
+					return err
+				}
+				if stored {
+					results[i].Status = StatusDuplicate
+					continue
+				}
+				id, err := tx.Enqueue(ctx, envs[i])
+				if err != nil {
+					return err
+				}
+				results[i].Status = StatusQueued
+				results[i].ID = id
+			}
+			return nil
+		})
+		if err == nil {
+			for _, i := range pending {
+				if results[i].Status == StatusDuplicate {
+					resp.Duplicate++
+				} else {
+					resp.Queued++
+				}
+			}
+		}
+		pending = pending[:0]
+		return err
+	}
+
+	for i, env := range envs {
+		if env == nil {
+			continue
+		}
+		pending = append(pending, i)
+		if len(pending) == batchChunkSize {
+			if err := flush(); err != nil {
+				return nil, batchError(err, resp.Queued)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, batchError(err, resp.Queued)
+	}
+	return resp, nil
+}
+
+// batchError reports a failure that aborted a batch part way through, noting
+// how many valid records were already queued in earlier chunks.
+func batchError(err error, queued int) error {
+	return apperrors.Database(err, "Failed to queue batch").WithDetails("queued_records", queued)
+}
+
+func readJSONArray(r io.Reader) ([]batchRecord, error) {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, apperrors.BadRequest(fmt.Sprintf("invalid batch: %v", err))
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, apperrors.BadRequest("invalid batch: expected a JSON array of envelopes")
+	}
+
+	var records []batchRecord
+	for dec.More() {
+		if len(records) == maxBatchRecords {
+			return nil, tooManyRecords()
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, apperrors.BadRequest(fmt.Sprintf("invalid batch: record %d: %v", len(records), err))
+		}
+		records = append(records, batchRecord{raw: raw})
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, apperrors.BadRequest(fmt.Sprintf("invalid batch: %v", err))
+	}
+	if len(records) == 0 {
+		return nil, apperrors.BadRequest("invalid batch: no records")
+	}
+	return records, nil
+}
+
+// readNDJSON reads one envelope per line, skipping blank lines. A line that is
+// not valid JSON becomes an invalid record rather than failing the batch.
+func readNDJSON(r io.Reader) ([]batchRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var records []batchRecord
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(records) == maxBatchRecords {
+			return nil, tooManyRecords()
+		}
+
+		rec := batchRecord{raw: append([]byte(nil), line...)}
+		if !json.Valid(line) {
+			rec.err = fmt.Errorf("malformed JSON")
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, apperrors.BadRequest(fmt.Sprintf("invalid batch: %v", err))
+	}
+	if len(records) == 0 {
+		return nil, apperrors.BadRequest("invalid batch: no records")
+	}
+	return records, nil
+}
+
+func isNDJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return true
+	}
+	return false
+}
+
+func tooManyRecords() error {
+	return apperrors.BadRequest(fmt.Sprintf("invalid batch: more than %d records", maxBatchRecords))
+}
+
+func reason(err error) string {
+	if appErr := apperrors.GetAppError(err); appErr != nil {
+		return appErr.Message
+	}
+	return err.Error()
+}
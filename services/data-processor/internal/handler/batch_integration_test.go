@@ -0,0 +1,101 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/deadletter"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/handler"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/processor"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	integration "github.com/asian-code/myapp-kubernetes/services/pkg/testing"
+	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestIngestBatchReportsStoredRecordsAsDuplicates_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	pgContainer, err := integration.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("Failed to start PostgreSQL container: %v", err)
+	}
+	defer pgContainer.Close(ctx)
+
+	pool, err := pgContainer.GetPool(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	if err := pgContainer.RunMigrations(ctx, pool); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	var userID string
+	err = pool.QueryRow(ctx, `INSERT INTO users (username, email, password_hash) VALUES ('a', 'a@example.com', 'x') RETURNING id::text`).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	envelope := func(raw string) *ingest.Envelope {
+		payload, err := ingest.FromOura(ingest.TypeCardiovascularAge, json.RawMessage(raw))
+		if err != nil {
+			t.Fatalf("Failed to parse document: %v", err)
+		}
+		env, err := ingest.New(ingest.TypeCardiovascularAge, userID, ingest.SourceOura, payload)
+		if err != nil {
+			t.Fatalf("Failed to build envelope: %v", err)
+		}
+		return env.WithRaw(json.RawMessage(raw), time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC))
+	}
+	stored := envelope(`{"day": "2024-01-10", "vascular_age": 40}`)
+	revised := envelope(`{"day": "2024-01-11", "vascular_age": 41}`)
+
+	m := metrics.New("data-processor-test")
+	repo := repository.New(pool, nil)
+	err = repo.InTx(ctx, func(tx *repository.Repository) error {
+		_, err := processor.New(m).Save(ctx, tx, stored)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	body, err := json.Marshal([]*ingest.Envelope{stored, revised})
+	if err != nil {
+		t.Fatalf("Failed to encode batch: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/api/v1/ingest/batch", strings.NewReader(string(body)))
+	rec := httptest.NewRecorder()
+	handler.New(repo, deadletter.New(repo), log.NewEntry(log.New()), m).IngestBatch(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp handler.BatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Duplicate != 1 || resp.Queued != 1 || resp.Results[0].Status != handler.StatusDuplicate || resp.Results[1].Status != handler.StatusQueued {
+		t.Errorf("Expected the stored record to be a duplicate and the new one queued, got %+v", resp)
+	}
+
+	depth, err := repo.QueueDepth(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read queue depth: %v", err)
+	}
+	if depth != 1 {
+		t.Errorf("Expected only the new record to be queued, got %d jobs", depth)
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/gorilla/mux"
+)
+
+// ListDeadLetters lists dead letters, most recently failed first. It accepts
+// source, type, limit and offset query parameters.
+func (h *Handler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := repository.DeadLetterFilter{
+		Source:   q.Get("source"),
+		DataType: q.Get("type"),
+	}
+
+	var err error
+	if filter.Limit, err = intParam(q.Get("limit")); err != nil {
+		h.writeError(w, r, apperrors.BadRequest("limit must be a non-negative integer"))
+		return
+	}
+	if filter.Offset, err = intParam(q.Get("offset")); err != nil {
+		h.writeError(w, r, apperrors.BadRequest("offset must be a non-negative integer"))
+		return
+	}
+
+	letters, err := h.deadLetters.List(r.Context(), filter)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	apperrors.WriteSuccess(w, letters, http.StatusOK)
+}
+
+// GetDeadLetter returns one dead letter with its payload.
+func (h *Handler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := deadLetterID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	dl, err := h.deadLetters.Get(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	apperrors.WriteSuccess(w, dl, http.StatusOK)
+}
+
+// EditDeadLetter replaces a dead letter's payload with the request body.
+func (h *Handler) EditDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := deadLetterID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRecordBytes))
+	if err != nil {
+		h.writeError(w, r, apperrors.BadRequest(fmt.Sprintf("invalid request body: %v", err)))
+		return
+	}
+
+	dl, err := h.deadLetters.Edit(r.Context(), id, payload)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	apperrors.WriteSuccess(w, dl, http.StatusOK)
+}
+
+// ReplayDeadLetter moves a dead letter back onto the ingest queue, responding
+// 202 Accepted with the queue job ID.
+func (h *Handler) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := deadLetterID(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	jobID, err := h.deadLetters.Replay(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.logger.WithField("dead_letter_id", id).WithField("job_id", jobID).Info("Replayed dead letter")
+	apperrors.WriteSuccess(w, map[string]interface{}{"status": "queued", "id": jobID}, http.StatusAccepted)
+}
+
+func deadLetterID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, apperrors.BadRequest("invalid dead letter id")
+	}
+	return id, nil
+}
+
+func intParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid integer %q", value)
+	}
+	return n, nil
+}
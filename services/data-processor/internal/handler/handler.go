@@ -1,154 +1,122 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/deadletter"
 	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
 	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
-type Handler struct {
-	repo    *repository.Repository
-	logger  *log.Entry
-	metrics *metrics.Metrics
-}
+// MaxRecordBytes caps the size of a single-record ingest body
+const MaxRecordBytes = 1 << 20
 
-func New(repo *repository.Repository, logger *log.Entry, m *metrics.Metrics) *Handler {
-	return &Handler{
-		repo:    repo,
-		logger:  logger,
-		metrics: m,
-	}
+// deadLetters is implemented by *deadletter.Service.
+type deadLetters interface {
+	Record(ctx context.Context, source string, raw []byte, env *ingest.Envelope, attempts int, cause error) error
+	List(ctx context.Context, filter repository.DeadLetterFilter) ([]*deadletter.DeadLetter, error)
+	Get(ctx context.Context, id int64) (*deadletter.DeadLetter, error)
+	Edit(ctx context.Context, id int64, payload []byte) (*deadletter.DeadLetter, error)
+	Replay(ctx context.Context, id int64) (int64, error)
 }
 
-type IngestRequest struct {
-	Type string          `json:"type"` // "sleep", "activity", "readiness"
-	Data json.RawMessage `json:"data"`
+// idempotencyStore is implemented by *repository.Repository.
+type idempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, endpoint, key, fingerprint string, ttl time.Duration) (*repository.IdempotencyRecord, error)
+	SaveIdempotencyResponse(ctx context.Context, endpoint, key string, statusCode int, contentType string, response []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, endpoint, key string) error
 }
 
-type SleepData struct {
-	ID       string `json:"id"`
-	Day      string `json:"day"`
-	Score    int    `json:"score"`
-	Duration int    `json:"duration"`
-}
-
-type ActivityData struct {
-	ID                string `json:"id"`
-	Day               string `json:"day"`
-	Score             int    `json:"score"`
-	ActiveCalories    int    `json:"active_calories"`
-	Steps             int    `json:"steps"`
-	MediumActivityMin int    `json:"medium_activity_minutes"`
-	HighActivityMin   int    `json:"high_activity_minutes"`
+type Handler struct {
+	repo        *repository.Repository
+	deadLetters deadLetters
+	idempotency idempotencyStore
+	logger      *log.Entry
+	metrics     *metrics.Metrics
 }
 
-type ReadinessData struct {
-	ID    string `json:"id"`
-	Day   string `json:"day"`
-	Score int    `json:"score"`
+func New(repo *repository.Repository, dl *deadletter.Service, logger *log.Entry, m *metrics.Metrics) *Handler {
+	return &Handler{
+		repo:        repo,
+		deadLetters: dl,
+		idempotency: repo,
+		logger:      logger,
+		metrics:     m,
+	}
 }
 
+// Ingest validates an envelope and queues it for the worker pool, responding
+// 202 Accepted with the queue job ID. Storage happens asynchronously. An
+// invalid envelope is rejected and kept in the dead-letter store.
 func (h *Handler) Ingest(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
+	status := http.StatusAccepted
 	defer func() {
-		h.metrics.HTTPRequestDuration.WithLabelValues(r.Method, "/ingest").Observe(time.Since(start).Seconds())
-		h.metrics.HTTPRequestsTotal.WithLabelValues(r.Method, "/ingest", "200").Inc()
+		h.metrics.RecordHTTPRequest(r.Method, "/ingest", status, time.Since(start))
 	}()
 
-	var req IngestRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to decode request")
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
-	}
-
-	ctx := r.Context()
-
-	switch req.Type {
-	case "sleep":
-		var data SleepData
-		if err := json.Unmarshal(req.Data, &data); err != nil {
-			http.Error(w, "Invalid sleep data", http.StatusBadRequest)
-			return
-		}
-
-		day, _ := time.Parse("2006-01-02", data.Day)
-		metric := &repository.SleepMetric{
-			OuraID:   data.ID,
-			Day:      day,
-			Score:    data.Score,
-			Duration: data.Duration,
-		}
-
-		if err := h.repo.SaveSleepMetric(ctx, metric); err != nil {
-			h.logger.WithError(err).Error("Failed to save sleep metric")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-
-	case "activity":
-		var data ActivityData
-		if err := json.Unmarshal(req.Data, &data); err != nil {
-			http.Error(w, "Invalid activity data", http.StatusBadRequest)
-			return
-		}
-
-		day, _ := time.Parse("2006-01-02", data.Day)
-		metric := &repository.ActivityMetric{
-			OuraID:            data.ID,
-			Day:               day,
-			Score:             data.Score,
-			ActiveCalories:    data.ActiveCalories,
-			Steps:             data.Steps,
-			MediumActivityMin: data.MediumActivityMin,
-			HighActivityMin:   data.HighActivityMin,
+	var id int64
+	var env *ingest.Envelope
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRecordBytes))
+	if err != nil {
+		err = apperrors.BadRequest(fmt.Sprintf("invalid request body: %v", err))
+	} else if env, err = ingest.Decode(bytes.NewReader(raw)); err != nil {
+		h.deadLetter(r.Context(), deadletter.SourceIngest, raw, nil, err)
+	} else {
+		id, err = h.repo.Enqueue(r.Context(), env)
+		if err != nil {
+			err = apperrors.Database(err, "Failed to queue record")
 		}
-
-		if err := h.repo.SaveActivityMetric(ctx, metric); err != nil {
-			h.logger.WithError(err).Error("Failed to save activity metric")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+	}
+	if err != nil {
+		status = http.StatusInternalServerError
+		if appErr := apperrors.GetAppError(err); appErr != nil {
+			status = appErr.StatusCode
 		}
-
-	case "readiness":
-		var data ReadinessData
-		if err := json.Unmarshal(req.Data, &data); err != nil {
-			http.Error(w, "Invalid readiness data", http.StatusBadRequest)
-			return
+		logger := h.logger.WithField("request_id", r.Context().Value("request-id"))
+		if env != nil {
+			logger = logger.WithField("type", env.Type).WithField("user_id", env.UserID)
 		}
+		apperrors.WriteError(w, logger, err)
+		return
+	}
 
-		day, _ := time.Parse("2006-01-02", data.Day)
-		metric := &repository.ReadinessMetric{
-			OuraID: data.ID,
-			Day:    day,
-			Score:  data.Score,
-		}
+	apperrors.WriteSuccess(w, map[string]interface{}{"status": "queued", "id": id}, http.StatusAccepted)
+}
 
-		if err := h.repo.SaveReadinessMetric(ctx, metric); err != nil {
-			h.logger.WithError(err).Error("Failed to save readiness metric")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apperrors.WriteError(w, h.logger.WithField("request_id", r.Context().Value("request-id")), err)
+}
 
-	default:
-		http.Error(w, "Unknown metric type", http.StatusBadRequest)
-		return
+// deadLetter keeps a rejected record. Failing to do so is logged rather than
+// returned so the client still sees why its record was rejected.
+func (h *Handler) deadLetter(ctx context.Context, source string, raw []byte, env *ingest.Envelope, cause error) {
+	if err := h.deadLetters.Record(ctx, source, raw, env, 1, cause); err != nil {
+		h.logger.WithError(err).WithField("source", source).Error("Failed to dead-letter rejected record")
 	}
-
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
 func (h *Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	metricType := vars["type"]
 
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
 	// Parse date range from query params (default: last 7 days)
 	endDate := time.Now()
 	startDate := endDate.AddDate(0, 0, -7)
@@ -164,39 +132,19 @@ func (h *Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	ctx := r.Context()
-
-	switch metricType {
-	case "sleep":
-		metrics, err := h.repo.GetSleepMetrics(ctx, startDate, endDate)
-		if err != nil {
-			h.logger.WithError(err).Error("Failed to get sleep metrics")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		json.NewEncoder(w).Encode(metrics)
-
-	case "activity":
-		metrics, err := h.repo.GetActivityMetrics(ctx, startDate, endDate)
-		if err != nil {
-			h.logger.WithError(err).Error("Failed to get activity metrics")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		json.NewEncoder(w).Encode(metrics)
-
-	case "readiness":
-		metrics, err := h.repo.GetReadinessMetrics(ctx, startDate, endDate)
-		if err != nil {
-			h.logger.WithError(err).Error("Failed to get readiness metrics")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		json.NewEncoder(w).Encode(metrics)
-
-	default:
+	t, ok := ingest.Lookup(metricType)
+	if !ok || t.Series {
 		http.Error(w, "Unknown metric type", http.StatusBadRequest)
+		return
+	}
+
+	metrics, err := h.repo.GetMetrics(r.Context(), t, userID, startDate, endDate)
+	if err != nil {
+		h.logger.WithError(err).Errorf("Failed to get %s metrics", metricType)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
+	json.NewEncoder(w).Encode(metrics)
 }
 
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/deadletter"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// fakeDeadLetters records dead letters in memory.
+type fakeDeadLetters struct {
+	recorded []string
+	letters  map[int64]*deadletter.DeadLetter
+}
+
+func (f *fakeDeadLetters) Record(ctx context.Context, source string, raw []byte, env *ingest.Envelope, attempts int, cause error) error {
+	f.recorded = append(f.recorded, source+": "+string(raw))
+	return nil
+}
+
+func (f *fakeDeadLetters) List(ctx context.Context, filter repository.DeadLetterFilter) ([]*deadletter.DeadLetter, error) {
+	var letters []*deadletter.DeadLetter
+	for _, dl := range f.letters {
+		letters = append(letters, dl)
+	}
+	return letters, nil
+}
+
+func (f *fakeDeadLetters) Get(ctx context.Context, id int64) (*deadletter.DeadLetter, error) {
+	dl, ok := f.letters[id]
+	if !ok {
+		return nil, apperrors.NotFound("dead letter not found")
+	}
+	return dl, nil
+}
+
+func (f *fakeDeadLetters) Edit(ctx context.Context, id int64, payload []byte) (*deadletter.DeadLetter, error) {
+	dl, err := f.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dl.Payload = json.RawMessage(payload)
+	return dl, nil
+}
+
+func (f *fakeDeadLetters) Replay(ctx context.Context, id int64) (int64, error) {
+	if _, err := f.Get(ctx, id); err != nil {
+		return 0, err
+	}
+	delete(f.letters, id)
+	return 100 + id, nil
+}
+
+func newTestHandler() *Handler {
+	h, _ := newTestHandlerWithDeadLetters()
+	return h
+}
+
+func newTestHandlerWithDeadLetters() (*Handler, *fakeDeadLetters) {
+	l := log.New()
+	l.SetOutput(new(strings.Builder))
+	m := metrics.New("data-processor-test")
+	dl := &fakeDeadLetters{letters: map[int64]*deadletter.DeadLetter{
+		7: {ID: 7, Source: deadletter.SourceQueue, Payload: json.RawMessage(`{"type":"sleep"}`), Error: "boom", Attempts: 5},
+	}}
+	h := New(nil, nil, log.NewEntry(l), m)
+	h.deadLetters = dl
+	return h, dl
+}
+
+func TestIngestRejectsInvalidEnvelopes(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   apperrors.ErrorCode
+	}{
+		{
+			name:       "bare payload",
+			body:       `{"id":"s1","day":"2024-01-01","score":80}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperrors.ErrCodeBadRequest,
+		},
+		{
+			name:       "unsupported schema version",
+			body:       `{"type":"sleep","schema_version":9,"user_id":"6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b","source":"oura","payload":{}}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperrors.ErrCodeUnsupportedVersion,
+		},
+		{
+			name:       "invalid payload",
+			body:       `{"type":"sleep","schema_version":1,"user_id":"6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b","source":"oura","payload":{"id":"s1"}}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperrors.ErrCodeValidationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, dl := newTestHandlerWithDeadLetters()
+			rec := httptest.NewRecorder()
+			h.Ingest(rec, httptest.NewRequest("POST", "/api/v1/ingest", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+
+			var resp apperrors.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Error.Code != tt.wantCode {
+				t.Errorf("Expected code %s, got %s (%s)", tt.wantCode, resp.Error.Code, resp.Error.Message)
+			}
+			if len(dl.recorded) != 1 || dl.recorded[0] != "ingest: "+tt.body {
+				t.Errorf("Expected the rejected body to be dead-lettered, got %q", dl.recorded)
+			}
+		})
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	body := "{\"a\":1}\n\n  {\"b\":2}  \n{not json\n"
+
+	records, err := readNDJSON(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records (blank lines skipped), got %d", len(records))
+	}
+	if records[1].err != nil || string(records[1].raw) != `{"b":2}` {
+		t.Errorf("Unexpected second record: %q %v", records[1].raw, records[1].err)
+	}
+	if records[2].err == nil {
+		t.Error("Expected malformed line to be marked invalid")
+	}
+}
+
+func TestReadJSONArray(t *testing.T) {
+	records, err := readJSONArray(strings.NewReader(`[{"a":1}, {"b":2}]`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Expected 2 records, got %d", len(records))
+	}
+
+	for _, body := range []string{`{"a":1}`, `[]`, `[{"a":1}`, ``} {
+		if _, err := readJSONArray(strings.NewReader(body)); err == nil {
+			t.Errorf("Expected error for body %q", body)
+		}
+	}
+}
+
+func TestIngestBatchReportsInvalidRecords(t *testing.T) {
+	body := strings.Join([]string{
+		`{"type":"sleep","schema_version":2,"user_id":"6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b","source":"oura","payload":{}}`,
+		`{"type":"steps","schema_version":1,"user_id":"6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b","source":"oura","payload":{}}`,
+		`garbage`,
+	}, "\n")
+
+	req := httptest.NewRequest("POST", "/api/v1/ingest/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	h, dl := newTestHandlerWithDeadLetters()
+	h.IngestBatch(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp BatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Invalid != 3 || resp.Queued != 0 || len(resp.Results) != 3 {
+		t.Fatalf("Expected 3 invalid results, got %+v", resp)
+	}
+	for i, res := range resp.Results {
+		if res.Index != i || res.Status != StatusInvalid || res.Reason == "" {
+			t.Errorf("Unexpected result %d: %+v", i, res)
+		}
+	}
+	if !strings.Contains(resp.Results[0].Reason, "schema_version 2") {
+		t.Errorf("Expected precise version reason, got %q", resp.Results[0].Reason)
+	}
+	if len(dl.recorded) != 3 || dl.recorded[2] != "batch: garbage" {
+		t.Errorf("Expected every invalid record to be dead-lettered, got %q", dl.recorded)
+	}
+}
+
+func TestDeadLetterAdmin(t *testing.T) {
+	h, dl := newTestHandlerWithDeadLetters()
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/dead-letters", h.ListDeadLetters).Methods("GET")
+	router.HandleFunc("/admin/dead-letters/{id}", h.GetDeadLetter).Methods("GET")
+	router.HandleFunc("/admin/dead-letters/{id}", h.EditDeadLetter).Methods("PUT")
+	router.HandleFunc("/admin/dead-letters/{id}/replay", h.ReplayDeadLetter).Methods("POST")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"list", "GET", "/admin/dead-letters?limit=10", "", http.StatusOK},
+		{"list with bad limit", "GET", "/admin/dead-letters?limit=-1", "", http.StatusBadRequest},
+		{"show", "GET", "/admin/dead-letters/7", "", http.StatusOK},
+		{"show invalid id", "GET", "/admin/dead-letters/abc", "", http.StatusBadRequest},
+		{"show missing", "GET", "/admin/dead-letters/8", "", http.StatusNotFound},
+		{"edit", "PUT", "/admin/dead-letters/7", `{"type":"sleep","schema_version":1}`, http.StatusOK},
+		{"replay", "POST", "/admin/dead-letters/7/replay", "", http.StatusAccepted},
+		{"replay again", "POST", "/admin/dead-letters/7/replay", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	if len(dl.letters) != 0 {
+		t.Errorf("Expected replayed dead letter to be removed, got %v", dl.letters)
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+)
+
+const (
+	// IdempotencyKeyHeader carries a client-chosen key identifying a request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from a stored result
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength matches the idempotency_keys.key column
+	maxIdempotencyKeyLength = 255
+)
+
+// Idempotent makes next safe to retry. A request carrying an idempotency key,
+// in the Idempotency-Key header or the envelope's idempotency_key field, has
+// its response stored for ttl; a retry with the same key and body gets that
+// response back without next running again. Reusing a key with a different
+// body, or while the first request is still running, is a 409 Conflict.
+// Server errors are not stored, so those requests can be retried for real.
+func (h *Handler) Idempotent(endpoint string, ttl time.Duration, maxBytes int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			h.writeError(w, r, apperrors.BadRequest(fmt.Sprintf("invalid request body: %v", err)))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key, err := idempotencyKey(r.Header.Get(IdempotencyKeyHeader), body)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		fingerprint := requestFingerprint(r.Method, endpoint, body)
+		rec, err := h.idempotency.ReserveIdempotencyKey(ctx, endpoint, key, fingerprint, ttl)
+		if err != nil {
+			h.writeError(w, r, apperrors.Database(err, "Failed to check idempotency key"))
+			return
+		}
+		if rec != nil {
+			h.replay(w, r, key, fingerprint, rec)
+			return
+		}
+
+		// The request may be cancelled once the response is written, but the
+		// key must still be settled.
+		ctx = context.WithoutCancel(ctx)
+		rw := &capturingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed {
+				h.releaseKey(ctx, endpoint, key)
+			}
+		}()
+
+		next(rw, r)
+
+		if rw.statusCode >= http.StatusInternalServerError {
+			return
+		}
+		completed = true
+		if err := h.idempotency.SaveIdempotencyResponse(ctx, endpoint, key, rw.statusCode, w.Header().Get("Content-Type"), rw.body.Bytes()); err != nil {
+			h.logger.WithError(err).WithField("idempotency_key", key).Error("Failed to store idempotent response")
+			h.releaseKey(ctx, endpoint, key)
+		}
+	}
+}
+
+func (h *Handler) replay(w http.ResponseWriter, r *http.Request, key, fingerprint string, rec *repository.IdempotencyRecord) {
+	switch {
+	case rec.Fingerprint != fingerprint:
+		h.writeError(w, r, apperrors.Conflict("Idempotency-Key was already used with a different request").
+			WithDetails("idempotency_key", key))
+	case rec.StatusCode == 0:
+		h.writeError(w, r, apperrors.Conflict("A request with this Idempotency-Key is still being processed").
+			WithDetails("idempotency_key", key))
+	default:
+		if rec.ContentType != "" {
+			w.Header().Set("Content-Type", rec.ContentType)
+		}
+		w.Header().Set(IdempotentReplayedHeader, "true")
+		w.WriteHeader(rec.StatusCode)
+		w.Write(rec.Response)
+	}
+}
+
+func (h *Handler) releaseKey(ctx context.Context, endpoint, key string) {
+	if err := h.idempotency.ReleaseIdempotencyKey(ctx, endpoint, key); err != nil {
+		h.logger.WithError(err).WithField("idempotency_key", key).Error("Failed to release idempotency key")
+	}
+}
+
+// idempotencyKey returns the request's idempotency key from the header or,
+// for a single envelope, its idempotency_key field. Both may be given only if
+// they agree.
+func idempotencyKey(header string, body []byte) (string, error) {
+	var env struct {
+		IdempotencyKey string `json:"idempotency_key"`
+	}
+	// Not every body is an envelope object (a batch is an array), so a
+	// body that does not decode simply has no key in it.
+	_ = json.Unmarshal(body, &env)
+
+	key := header
+	switch {
+	case key == "":
+		key = env.IdempotencyKey
+	case env.IdempotencyKey != "" && env.IdempotencyKey != key:
+		return "", apperrors.BadRequest("Idempotency-Key header and envelope idempotency_key differ")
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return "", apperrors.BadRequest(fmt.Sprintf("idempotency key is longer than %d characters", maxIdempotencyKeyLength))
+	}
+	return key, nil
+}
+
+// requestFingerprint identifies a request by method, endpoint and body.
+func requestFingerprint(method, endpoint string, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", method, endpoint)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// capturingWriter passes a response through while keeping a copy of it.
+type capturingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *capturingWriter) WriteHeader(code int) {
+	w.statusCode = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+)
+
+// fakeIdempotencyStore keeps idempotency records in memory.
+type fakeIdempotencyStore struct {
+	records map[string]*repository.IdempotencyRecord
+}
+
+func (f *fakeIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, endpoint, key, fingerprint string, ttl time.Duration) (*repository.IdempotencyRecord, error) {
+	if rec, ok := f.records[endpoint+" "+key]; ok {
+		return rec, nil
+	}
+	f.records[endpoint+" "+key] = &repository.IdempotencyRecord{Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (f *fakeIdempotencyStore) SaveIdempotencyResponse(ctx context.Context, endpoint, key string, statusCode int, contentType string, response []byte) error {
+	rec := f.records[endpoint+" "+key]
+	rec.StatusCode, rec.ContentType, rec.Response = statusCode, contentType, response
+	return nil
+}
+
+func (f *fakeIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, endpoint, key string) error {
+	delete(f.records, endpoint+" "+key)
+	return nil
+}
+
+func TestIdempotent(t *testing.T) {
+	h := newTestHandler()
+	h.idempotency = &fakeIdempotencyStore{records: map[string]*repository.IdempotencyRecord{}}
+
+	calls := 0
+	status := http.StatusAccepted
+	next := h.Idempotent("/ingest", time.Hour, MaxRecordBytes, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		apperrors.WriteSuccess(w, map[string]int{"call": calls}, status)
+	})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/ingest", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		next(rec, req)
+		return rec
+	}
+
+	t.Run("retry returns the original response", func(t *testing.T) {
+		first := send("k1", `{"a":1}`)
+		retry := send("k1", `{"a":1}`)
+
+		if calls != 1 {
+			t.Errorf("Expected the handler to run once, ran %d times", calls)
+		}
+		if retry.Code != http.StatusAccepted || retry.Body.String() != first.Body.String() {
+			t.Errorf("Expected replay of %d %s, got %d %s", first.Code, first.Body, retry.Code, retry.Body)
+		}
+		if retry.Header().Get(IdempotentReplayedHeader) != "true" {
+			t.Error("Expected replayed response to be marked")
+		}
+	})
+
+	t.Run("different body is a conflict", func(t *testing.T) {
+		rec := send("k1", `{"a":2}`)
+
+		var resp apperrors.ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		if rec.Code != http.StatusConflict || resp.Error.Code != apperrors.ErrCodeConflict {
+			t.Errorf("Expected 409 CONFLICT, got %d %s", rec.Code, resp.Error.Code)
+		}
+	})
+
+	t.Run("envelope field is a key", func(t *testing.T) {
+		before := calls
+		send("", `{"idempotency_key":"k2"}`)
+		send("", `{"idempotency_key":"k2"}`)
+		if calls != before+1 {
+			t.Errorf("Expected the handler to run once, ran %d times", calls-before)
+		}
+	})
+
+	t.Run("header and envelope field must agree", func(t *testing.T) {
+		if rec := send("k3", `{"idempotency_key":"other"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("no key runs every time", func(t *testing.T) {
+		before := calls
+		send("", `{"a":1}`)
+		send("", `{"a":1}`)
+		if calls != before+2 {
+			t.Errorf("Expected the handler to run twice, ran %d times", calls-before)
+		}
+	})
+
+	t.Run("server errors are not stored", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		before := calls
+		send("k4", `{"a":1}`)
+		status = http.StatusAccepted
+		rec := send("k4", `{"a":1}`)
+
+		if calls != before+2 || rec.Code != http.StatusAccepted {
+			t.Errorf("Expected the retry to run again and succeed, ran %d times, got %d", calls-before, rec.Code)
+		}
+	})
+}
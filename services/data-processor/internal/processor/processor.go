@@ -0,0 +1,138 @@
+// Package processor turns validated ingest envelopes into stored metrics.
+package processor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
+)
+
+type Processor struct {
+	metrics *metrics.Metrics
+}
+
+func New(m *metrics.Metrics) *Processor {
+	return &Processor{metrics: m}
+}
+
+// Record is the row a validated envelope is stored as. For a series type
+// Metric only identifies the document and Samples holds the rows.
+type Record struct {
+	Type    *ingest.MetricType
+	Metric  *repository.Metric
+	Samples [][]interface{}
+}
+
+// Derive decodes a validated envelope's payload and converts it into the row
+// it is stored as. Errors are *errors.AppError values.
+func Derive(env *ingest.Envelope) (*Record, error) {
+	payload, err := env.DecodePayload()
+	if err != nil {
+		return nil, err
+	}
+	t, _ := ingest.Lookup(env.Type)
+
+	id, day := payload.Document()
+	metric := &repository.Metric{UserID: env.UserID, OuraID: id}
+	metric.Day, _ = time.Parse("2006-01-02", day)
+
+	if series, ok := payload.(ingest.SeriesPayload); ok {
+		return &Record{Type: t, Metric: metric, Samples: series.Rows()}, nil
+	}
+	metric.Values = t.Row(payload)
+	return &Record{Type: t, Metric: metric}, nil
+}
+
+// Save stores a validated envelope's payload in the table for its type using
+// repo, which must be bound to a transaction. It returns false if an
+// identical record was already stored. Errors are *errors.AppError values;
+// client errors (4xx) mean the record itself is bad and retrying will not
+// help.
+func (p *Processor) Save(ctx context.Context, repo *repository.Repository, env *ingest.Envelope) (bool, error) {
+	rec, err := Derive(env)
+	if err != nil {
+		return false, err
+	}
+
+	// Serialise with a concurrent reprocess of the same document, which
+	// would otherwise overwrite this write with an older version.
+	err = repo.LockDocument(ctx, env.Type, env.UserID, rec.Metric.OuraID)
+
+	var stored bool
+	if err == nil && rec.Type.Series {
+		stored, err = repo.SaveSamples(ctx, rec.Type, env.UserID, rec.Samples)
+	} else if err == nil {
+		stored, err = repo.SaveMetric(ctx, rec.Type, rec.Metric)
+	}
+	if err == nil && env.Raw != nil {
+		err = p.archive(ctx, repo, env, rec)
+	}
+
+	if repository.IsForeignKeyViolation(err) {
+		return false, apperrors.ValidationFailed("user_id does not refer to an existing user").WithDetails("user_id", env.UserID)
+	}
+	if err != nil {
+		return false, apperrors.Database(err, fmt.Sprintf("Failed to save %s metric", env.Type))
+	}
+
+	p.metrics.ProcessedRecordsTotal.Inc()
+	p.metrics.LastProcessedTimestamp.Set(float64(time.Now().Unix()))
+	return stored, nil
+}
+
+// Stored reports whether saving a validated envelope would change nothing:
+// its metric is stored with identical values and its raw document, if it
+// carries one, is already the latest archived version. Series are never
+// reported as stored. Errors are *errors.AppError values.
+func Stored(ctx context.Context, repo *repository.Repository, env *ingest.Envelope) (bool, error) {
+	rec, err := Derive(env)
+	if err != nil || rec.Type.Series {
+		return false, err
+	}
+
+	stored, err := repo.MetricStored(ctx, rec.Type, rec.Metric)
+	if err == nil && stored && env.Raw != nil {
+		stored, err = repo.IsLatestRawDocument(ctx, rawDocument(env, rec))
+	}
+	if err != nil {
+		return false, apperrors.Database(err, fmt.Sprintf("Failed to look up %s metric", env.Type))
+	}
+	return stored, nil
+}
+
+// archive keeps the upstream document the envelope was derived from, so its
+// metrics can be re-derived later without fetching it again.
+func (p *Processor) archive(ctx context.Context, repo *repository.Repository, env *ingest.Envelope, rec *Record) error {
+	_, err := repo.SaveRawDocument(ctx, rawDocument(env, rec))
+	return err
+}
+
+// rawDocument is the archived form of the upstream document an envelope was
+// derived from.
+func rawDocument(env *ingest.Envelope, rec *Record) *repository.RawDocument {
+	fetchedAt := time.Now().UTC()
+	if env.FetchedAt != nil {
+		fetchedAt = env.FetchedAt.UTC()
+	}
+	return &repository.RawDocument{
+		Provider:   env.Source,
+		DataType:   env.Type,
+		UpstreamID: rec.Metric.OuraID,
+		UserID:     env.UserID,
+		Day:        rec.Metric.Day,
+		FetchedAt:  fetchedAt,
+		Document:   env.Raw,
+	}
+}
+
+// IsPermanent reports whether err means the record itself is bad, so
+// retrying it will not help.
+func IsPermanent(err error) bool {
+	appErr := apperrors.GetAppError(err)
+	return appErr != nil && appErr.StatusCode < 500
+}
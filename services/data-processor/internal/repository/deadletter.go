@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DeadLetter is a record that could not be stored. Payload is the raw record
+// as received and may not be valid JSON; DataType and UserID are empty when
+// the record was too malformed to tell.
+type DeadLetter struct {
+	ID            int64
+	Source        string
+	DataType      string
+	UserID        string
+	Payload       []byte
+	Error         string
+	Attempts      int
+	FirstFailedAt time.Time
+	LastFailedAt  time.Time
+}
+
+// DeadLetterFilter narrows ListDeadLetters. Empty fields match everything.
+type DeadLetterFilter struct {
+	Source   string
+	DataType string
+	Limit    int
+	Offset   int
+}
+
+const deadLetterColumns = `id, source, COALESCE(data_type, ''), COALESCE(user_id::text, ''),
+	payload, error, attempts, first_failed_at, last_failed_at`
+
+func scanDeadLetter(row pgx.Row) (*DeadLetter, error) {
+	var dl DeadLetter
+	err := row.Scan(&dl.ID, &dl.Source, &dl.DataType, &dl.UserID,
+		&dl.Payload, &dl.Error, &dl.Attempts, &dl.FirstFailedAt, &dl.LastFailedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &dl, nil
+}
+
+func payloadHash(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveDeadLetter stores a failed record and returns its ID. A record whose
+// payload is already dead-lettered is not duplicated: its attempts are added
+// to the existing row and the latest error replaces the old one.
+func (r *Repository) SaveDeadLetter(ctx context.Context, dl *DeadLetter) (int64, error) {
+	query := `
+		INSERT INTO dead_letters (source, data_type, user_id, payload, payload_hash, error, attempts)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, '')::uuid, $4, $5, $6, $7)
+		ON CONFLICT (payload_hash) DO UPDATE SET
+			source = EXCLUDED.source,
+			error = EXCLUDED.error,
+			attempts = dead_letters.attempts + EXCLUDED.attempts,
+			last_failed_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id
+	`
+	var id int64
+	err := r.q.QueryRow(ctx, query,
+		dl.Source, dl.DataType, dl.UserID, dl.Payload, payloadHash(dl.Payload), dl.Error, dl.Attempts,
+	).Scan(&id)
+	return id, err
+}
+
+// ListDeadLetters returns dead letters, most recently failed first.
+func (r *Repository) ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]*DeadLetter, error) {
+	query := `
+		SELECT ` + deadLetterColumns + `
+		FROM dead_letters
+		WHERE ($1 = '' OR source = $1) AND ($2 = '' OR data_type = $2)
+		ORDER BY last_failed_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := r.q.Query(ctx, query, filter.Source, filter.DataType, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []*DeadLetter
+	for rows.Next() {
+		dl, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, dl)
+	}
+	return letters, rows.Err()
+}
+
+// GetDeadLetter returns a dead letter by ID, or nil if there is none.
+func (r *Repository) GetDeadLetter(ctx context.Context, id int64) (*DeadLetter, error) {
+	query := `SELECT ` + deadLetterColumns + ` FROM dead_letters WHERE id = $1`
+	dl, err := scanDeadLetter(r.q.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return dl, err
+}
+
+// UpdateDeadLetterPayload replaces a dead letter's payload. It returns false
+// if the dead letter does not exist.
+func (r *Repository) UpdateDeadLetterPayload(ctx context.Context, id int64, payload []byte) (bool, error) {
+	query := `
+		UPDATE dead_letters
+		SET payload = $2, payload_hash = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	tag, err := r.q.Exec(ctx, query, id, payload, payloadHash(payload))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RecordDeadLetterFailure counts a failed replay of a dead letter.
+func (r *Repository) RecordDeadLetterFailure(ctx context.Context, id int64, lastError string) error {
+	query := `
+		UPDATE dead_letters
+		SET error = $2, attempts = attempts + 1,
+			last_failed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	_, err := r.q.Exec(ctx, query, id, lastError)
+	return err
+}
+
+// DeleteDeadLetter removes a dead letter.
+func (r *Repository) DeleteDeadLetter(ctx context.Context, id int64) error {
+	_, err := r.q.Exec(ctx, `DELETE FROM dead_letters WHERE id = $1`, id)
+	return err
+}
+
+// CountDeadLetters returns the dead-letter backlog.
+func (r *Repository) CountDeadLetters(ctx context.Context) (int, error) {
+	var n int
+	err := r.q.QueryRow(ctx, `SELECT COUNT(*) FROM dead_letters`).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// IdempotencyRecord is the stored outcome of a request sent with an
+// idempotency key. StatusCode is 0 while the first request is in flight.
+type IdempotencyRecord struct {
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Response    []byte
+}
+
+// ReserveIdempotencyKey claims key for a request with the given fingerprint
+// until ttl has passed. If the key is already held by an unexpired request it
+// returns that request's record instead and reserves nothing.
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, endpoint, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error) {
+	query := `
+		INSERT INTO idempotency_keys (endpoint, key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))
+		ON CONFLICT (endpoint, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = NULL,
+			content_type = NULL,
+			response = NULL,
+			created_at = CURRENT_TIMESTAMP,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+	`
+	tag, err := r.q.Exec(ctx, query, endpoint, key, fingerprint, ttl.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil, nil
+	}
+
+	var rec IdempotencyRecord
+	var status *int
+	var contentType *string
+	query = `
+		SELECT fingerprint, status_code, content_type, response
+		FROM idempotency_keys
+		WHERE endpoint = $1 AND key = $2
+	`
+	err = r.q.QueryRow(ctx, query, endpoint, key).Scan(&rec.Fingerprint, &status, &contentType, &rec.Response)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Released between the two statements; report it as in flight
+		// so the client retries.
+		return &IdempotencyRecord{Fingerprint: fingerprint}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != nil {
+		rec.StatusCode = *status
+	}
+	if contentType != nil {
+		rec.ContentType = *contentType
+	}
+	return &rec, nil
+}
+
+// SaveIdempotencyResponse stores the response to a reserved key.
+func (r *Repository) SaveIdempotencyResponse(ctx context.Context, endpoint, key string, statusCode int, contentType string, response []byte) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response = $5
+		WHERE endpoint = $1 AND key = $2
+	`
+	_, err := r.q.Exec(ctx, query, endpoint, key, statusCode, contentType, response)
+	return err
+}
+
+// ReleaseIdempotencyKey drops a reserved key, so the request can be retried.
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, endpoint, key string) error {
+	_, err := r.q.Exec(ctx, `DELETE FROM idempotency_keys WHERE endpoint = $1 AND key = $2`, endpoint, key)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys removes expired keys and returns how many.
+func (r *Repository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	tag, err := r.q.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
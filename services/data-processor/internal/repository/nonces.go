@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// StoreNonce records a signed request's nonce until expiresAt. It returns
+// false if the nonce is already recorded and unexpired, meaning the request
+// is a replay. It implements signing.NonceCache across every replica.
+func (r *Repository) StoreNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO request_nonces (nonce, expires_at)
+		VALUES ($1, CURRENT_TIMESTAMP + make_interval(secs => $2))
+		ON CONFLICT (nonce) DO UPDATE SET expires_at = EXCLUDED.expires_at
+		WHERE request_nonces.expires_at <= CURRENT_TIMESTAMP
+	`
+	// Stored relative to the database clock, like the other expiry columns
+	tag, err := r.q.Exec(ctx, query, nonce, time.Until(expiresAt).Seconds())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteExpiredNonces removes nonces whose requests can no longer verify.
+func (r *Repository) DeleteExpiredNonces(ctx context.Context) (int64, error) {
+	tag, err := r.q.Exec(ctx, `DELETE FROM request_nonces WHERE expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
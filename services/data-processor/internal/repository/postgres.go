@@ -2,168 +2,250 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	log "github.com/sirupsen/logrus"
 )
 
+// querier is satisfied by both the pool and a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
 type Repository struct {
 	db     *pgxpool.Pool
+	q      querier
+	tx     pgx.Tx
 	logger *log.Entry
 }
 
 func New(db *pgxpool.Pool, logger *log.Entry) *Repository {
 	return &Repository{
 		db:     db,
+		q:      db,
 		logger: logger,
 	}
 }
 
-type SleepMetric struct {
-	OuraID   string
-	Day      time.Time
-	Score    int
-	Duration int
+// InTx runs fn with a repository bound to a new transaction, committing if fn
+// returns nil and rolling back otherwise. Called on a repository that is
+// already in a transaction it uses a savepoint, so one failed statement can
+// be undone without aborting the outer transaction.
+func (r *Repository) InTx(ctx context.Context, fn func(tx *Repository) error) error {
+	var tx pgx.Tx
+	var err error
+	if r.tx != nil {
+		tx, err = r.tx.Begin(ctx)
+	} else {
+		tx, err = r.db.Begin(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(&Repository{db: r.db, q: tx, tx: tx, logger: r.logger}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-type ActivityMetric struct {
-	OuraID            string
-	Day               time.Time
-	Score             int
-	ActiveCalories    int
-	Steps             int
-	MediumActivityMin int
-	HighActivityMin   int
+// upserted interprets the result of an upsert that RETURNs a row only when
+// something was written: it reports false for an unchanged duplicate.
+func upserted(err error) (bool, error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-type ReadinessMetric struct {
+// IsForeignKeyViolation reports whether err was caused by a reference to a
+// row that does not exist, such as an unknown user_id.
+func IsForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+// IsUniqueViolation reports whether err was caused by a duplicate key.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// Metric is one stored row of any registered metric type.
+type Metric struct {
+	UserID string
 	OuraID string
 	Day    time.Time
-	Score  int
+	// Values holds the type's value columns by name.
+	Values map[string]interface{}
 }
 
-func (r *Repository) SaveSleepMetric(ctx context.Context, metric *SleepMetric) error {
-	query := `
-		INSERT INTO sleep_metrics (oura_id, day, score, duration)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (oura_id) 
-		DO UPDATE SET score = $3, duration = $4, updated_at = CURRENT_TIMESTAMP
-	`
-	_, err := r.db.Exec(ctx, query, metric.OuraID, metric.Day, metric.Score, metric.Duration)
-	return err
+// MarshalJSON flattens Values into the object alongside the document fields.
+func (m *Metric) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(m.Values)+3)
+	for col, v := range m.Values {
+		fields[col] = v
+	}
+	fields["user_id"] = m.UserID
+	fields["oura_id"] = m.OuraID
+	fields["day"] = m.Day.Format("2006-01-02")
+	return json.Marshal(fields)
 }
 
-func (r *Repository) SaveActivityMetric(ctx context.Context, metric *ActivityMetric) error {
-	query := `
-		INSERT INTO activity_metrics (
-			oura_id, day, score, active_calories, steps, 
-			medium_activity_minutes, high_activity_minutes
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (oura_id)
-		DO UPDATE SET 
-			score = $3, 
-			active_calories = $4, 
-			steps = $5, 
-			medium_activity_minutes = $6, 
-			high_activity_minutes = $7,
-			updated_at = CURRENT_TIMESTAMP
-	`
-	_, err := r.db.Exec(ctx, query,
-		metric.OuraID, metric.Day, metric.Score, metric.ActiveCalories,
-		metric.Steps, metric.MediumActivityMin, metric.HighActivityMin,
-	)
-	return err
+// metricColumns returns the quoted columns of t's table in the order they
+// are written and read back.
+func metricColumns(t *ingest.MetricType) []string {
+	cols := []string{"user_id", "oura_id", "day"}
+	for _, col := range t.Columns {
+		cols = append(cols, pgx.Identifier{col}.Sanitize())
+	}
+	return cols
 }
 
-func (r *Repository) SaveReadinessMetric(ctx context.Context, metric *ReadinessMetric) error {
-	query := `
-		INSERT INTO readiness_metrics (oura_id, day, score)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (oura_id)
-		DO UPDATE SET score = $3, updated_at = CURRENT_TIMESTAMP
-	`
-	_, err := r.db.Exec(ctx, query, metric.OuraID, metric.Day, metric.Score)
-	return err
-}
+// SaveMetric upserts a metric into t's table. It returns false if an
+// identical record was already stored.
+func (r *Repository) SaveMetric(ctx context.Context, t *ingest.MetricType, metric *Metric) (bool, error) {
+	table := pgx.Identifier{t.Table}.Sanitize()
+	cols := metricColumns(t)
 
-func (r *Repository) GetSleepMetrics(ctx context.Context, startDate, endDate time.Time) ([]*SleepMetric, error) {
-	query := `
-		SELECT oura_id, day, score, duration
-		FROM sleep_metrics
-		WHERE day BETWEEN $1 AND $2
-		ORDER BY day DESC
-	`
+	args := []interface{}{metric.UserID, metric.OuraID, metric.Day}
+	for _, col := range t.Columns {
+		args = append(args, metric.Values[col])
+	}
 
-	rows, err := r.db.Query(ctx, query, startDate, endDate)
-	if err != nil {
-		return nil, err
+	placeholders := make([]string, len(cols))
+	var sets, stored, excluded []string
+	for i, col := range cols {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		if i < 2 {
+			continue
+		}
+		sets = append(sets, col+" = EXCLUDED."+col)
+		stored = append(stored, table+"."+col)
+		excluded = append(excluded, "EXCLUDED."+col)
 	}
-	defer rows.Close()
 
-	var metrics []*SleepMetric
-	for rows.Next() {
-		var m SleepMetric
-		if err := rows.Scan(&m.OuraID, &m.Day, &m.Score, &m.Duration); err != nil {
-			return nil, err
+	query := fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		VALUES (%s)
+		ON CONFLICT (user_id, oura_id)
+		DO UPDATE SET %s, updated_at = CURRENT_TIMESTAMP
+		WHERE (%s) IS DISTINCT FROM (%s)
+		RETURNING id
+	`, table, strings.Join(cols, ", "), strings.Join(placeholders, ", "),
+		strings.Join(sets, ", "), strings.Join(stored, ", "), strings.Join(excluded, ", "))
+
+	var id int
+	err := r.q.QueryRow(ctx, query, args...).Scan(&id)
+	return upserted(err)
+}
+
+// MetricStored reports whether metric is already stored in t's table with
+// identical values, so saving it would change nothing.
+func (r *Repository) MetricStored(ctx context.Context, t *ingest.MetricType, metric *Metric) (bool, error) {
+	conds := []string{"user_id = $1", "oura_id = $2"}
+	args := []interface{}{metric.UserID, metric.OuraID}
+	for i, col := range metricColumns(t)[2:] {
+		conds = append(conds, fmt.Sprintf("%s IS NOT DISTINCT FROM $%d", col, i+3))
+		if i == 0 {
+			args = append(args, metric.Day)
+		} else {
+			args = append(args, metric.Values[t.Columns[i-1]])
 		}
-		metrics = append(metrics, &m)
 	}
 
-	return metrics, rows.Err()
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE %s)`,
+		pgx.Identifier{t.Table}.Sanitize(), strings.Join(conds, " AND "))
+	var stored bool
+	err := r.q.QueryRow(ctx, query, args...).Scan(&stored)
+	return stored, err
 }
 
-func (r *Repository) GetActivityMetrics(ctx context.Context, startDate, endDate time.Time) ([]*ActivityMetric, error) {
-	query := `
-		SELECT oura_id, day, score, active_calories, steps, 
-		       medium_activity_minutes, high_activity_minutes
-		FROM activity_metrics
-		WHERE day BETWEEN $1 AND $2
+// GetMetrics returns a user's metrics of type t between two days, newest
+// first.
+func (r *Repository) GetMetrics(ctx context.Context, t *ingest.MetricType, userID string, startDate, endDate time.Time) ([]*Metric, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE user_id = $1 AND day BETWEEN $2 AND $3
 		ORDER BY day DESC
-	`
+	`, selectMetricColumns(t), pgx.Identifier{t.Table}.Sanitize())
+	return r.queryMetrics(ctx, t, query, userID, startDate, endDate)
+}
 
-	rows, err := r.db.Query(ctx, query, startDate, endDate)
-	if err != nil {
+// GetMetric returns the metric of type t for an Oura document, or nil if
+// none is stored.
+func (r *Repository) GetMetric(ctx context.Context, t *ingest.MetricType, userID, ouraID string) (*Metric, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE user_id = $1 AND oura_id = $2
+	`, selectMetricColumns(t), pgx.Identifier{t.Table}.Sanitize())
+	metrics, err := r.queryMetrics(ctx, t, query, userID, ouraID)
+	if err != nil || len(metrics) == 0 {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var metrics []*ActivityMetric
-	for rows.Next() {
-		var m ActivityMetric
-		if err := rows.Scan(&m.OuraID, &m.Day, &m.Score, &m.ActiveCalories,
-			&m.Steps, &m.MediumActivityMin, &m.HighActivityMin); err != nil {
-			return nil, err
-		}
-		metrics = append(metrics, &m)
-	}
-
-	return metrics, rows.Err()
+	return metrics[0], nil
 }
 
-func (r *Repository) GetReadinessMetrics(ctx context.Context, startDate, endDate time.Time) ([]*ReadinessMetric, error) {
-	query := `
-		SELECT oura_id, day, score
-		FROM readiness_metrics
-		WHERE day BETWEEN $1 AND $2
-		ORDER BY day DESC
-	`
+func selectMetricColumns(t *ingest.MetricType) string {
+	cols := metricColumns(t)
+	cols[0] = "user_id::text"
+	return strings.Join(cols, ", ")
+}
 
-	rows, err := r.db.Query(ctx, query, startDate, endDate)
+func (r *Repository) queryMetrics(ctx context.Context, t *ingest.MetricType, query string, args ...interface{}) ([]*Metric, error) {
+	rows, err := r.q.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var metrics []*ReadinessMetric
+	var metrics []*Metric
 	for rows.Next() {
-		var m ReadinessMetric
-		if err := rows.Scan(&m.OuraID, &m.Day, &m.Score); err != nil {
+		values, err := rows.Values()
+		if err != nil {
 			return nil, err
 		}
-		metrics = append(metrics, &m)
+		m := &Metric{Values: make(map[string]interface{}, len(t.Columns))}
+		var ok bool
+		if m.UserID, ok = values[0].(string); !ok {
+			return nil, fmt.Errorf("%s: unexpected user_id %T", t.Table, values[0])
+		}
+		if m.OuraID, ok = values[1].(string); !ok {
+			return nil, fmt.Errorf("%s: unexpected oura_id %T", t.Table, values[1])
+		}
+		if m.Day, ok = values[2].(time.Time); !ok {
+			return nil, fmt.Errorf("%s: unexpected day %T", t.Table, values[2])
+		}
+		for i, col := range t.Columns {
+			m.Values[col] = values[i+3]
+		}
+		metrics = append(metrics, m)
 	}
 
 	return metrics, rows.Err()
 }
+
+// LockDocument takes a transaction-scoped lock on one upstream document's
+// metric, so a live write and a reprocess of the same document cannot
+// interleave. It must be called inside a transaction.
+func (r *Repository) LockDocument(ctx context.Context, dataType, userID, upstreamID string) error {
+	_, err := r.q.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`,
+		dataType+"/"+userID+"/"+upstreamID)
+	return err
+}
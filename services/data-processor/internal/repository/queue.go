@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/jackc/pgx/v5"
+)
+
+// Job is a queued ingest envelope. Raw is the envelope as stored.
+type Job struct {
+	ID       int64
+	Envelope *ingest.Envelope
+	Raw      []byte
+	Attempts int
+}
+
+// Enqueue adds a validated envelope to the ingest queue and returns its ID.
+func (r *Repository) Enqueue(ctx context.Context, env *ingest.Envelope) (int64, error) {
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	query := `INSERT INTO ingest_queue (envelope) VALUES ($1) RETURNING id`
+	err = r.q.QueryRow(ctx, query, raw).Scan(&id)
+	return id, err
+}
+
+// ClaimJob locks the oldest available job, skipping jobs other
+// workers hold. It must be called inside InTx; the job stays locked until the
+// transaction ends, so a crashed worker's job becomes available again. It
+// returns nil if no job is available.
+func (r *Repository) ClaimJob(ctx context.Context) (*Job, error) {
+	query := `
+		SELECT id, envelope, attempts
+		FROM ingest_queue
+		WHERE available_at <= CURRENT_TIMESTAMP
+		ORDER BY available_at, id
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	`
+	var job Job
+	err := r.q.QueryRow(ctx, query).Scan(&job.ID, &job.Raw, &job.Attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(job.Raw, &job.Envelope); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CompleteJob removes a processed or dead-lettered job from the queue.
+func (r *Repository) CompleteJob(ctx context.Context, id int64) error {
+	_, err := r.q.Exec(ctx, `DELETE FROM ingest_queue WHERE id = $1`, id)
+	return err
+}
+
+// RetryJob records a failed attempt and makes the job available again after
+// delay.
+func (r *Repository) RetryJob(ctx context.Context, id int64, attempts int, lastError string, delay time.Duration) error {
+	query := `
+		UPDATE ingest_queue
+		SET attempts = $2, last_error = $3,
+			available_at = CURRENT_TIMESTAMP + make_interval(secs => $4),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	_, err := r.q.Exec(ctx, query, id, attempts, lastError, delay.Seconds())
+	return err
+}
+
+// QueueDepth returns the number of queued jobs.
+func (r *Repository) QueueDepth(ctx context.Context) (int, error) {
+	var depth int
+	err := r.q.QueryRow(ctx, `SELECT COUNT(*) FROM ingest_queue`).Scan(&depth)
+	return depth, err
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RawDocument is an upstream document as the provider returned it.
+type RawDocument struct {
+	Provider   string
+	DataType   string
+	UpstreamID string
+	UserID     string
+	Day        time.Time
+	FetchedAt  time.Time
+	Document   json.RawMessage
+}
+
+// contentHash hashes a document's compacted JSON, so formatting differences
+// do not count as a change.
+func contentHash(doc json.RawMessage) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, doc); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SaveRawDocument archives an upstream document. It returns false if the
+// same content is already archived for that user's document, in which case
+// that version is marked as seen at FetchedAt instead, so a document that
+// changed and then changed back is current again.
+func (r *Repository) SaveRawDocument(ctx context.Context, doc *RawDocument) (bool, error) {
+	hash, err := contentHash(doc.Document)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO raw_documents (provider, data_type, upstream_id, fetched_at, last_seen_at, user_id, day, content_hash, document)
+		VALUES ($1, $2, $3, $4, $4, $5, $6, $7, $8)
+		ON CONFLICT ON CONSTRAINT raw_documents_content_key DO UPDATE
+		SET last_seen_at = GREATEST(raw_documents.last_seen_at, EXCLUDED.last_seen_at)
+		RETURNING xmax = 0
+	`
+	var inserted bool
+	err = r.q.QueryRow(ctx, query,
+		doc.Provider, doc.DataType, doc.UpstreamID, doc.FetchedAt, doc.UserID, doc.Day, hash, []byte(doc.Document),
+	).Scan(&inserted)
+	if err != nil {
+		return false, err
+	}
+	return inserted, nil
+}
+
+// IsLatestRawDocument reports whether doc's content is already the latest
+// archived version of that user's document.
+func (r *Repository) IsLatestRawDocument(ctx context.Context, doc *RawDocument) (bool, error) {
+	hash, err := contentHash(doc.Document)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		SELECT content_hash = $5
+		FROM raw_documents
+		WHERE user_id = $1 AND provider = $2 AND data_type = $3 AND upstream_id = $4
+		ORDER BY last_seen_at DESC, fetched_at DESC
+		LIMIT 1
+	`
+	var latest bool
+	err = r.q.QueryRow(ctx, query, doc.UserID, doc.Provider, doc.DataType, doc.UpstreamID, hash).Scan(&latest)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return latest, err
+}
+
+// RawDocumentKey identifies a user's upstream document across its versions.
+type RawDocumentKey struct {
+	UserID     string
+	Provider   string
+	DataType   string
+	UpstreamID string
+}
+
+// RawDocumentFilter selects one user's archived documents with any version
+// dated within [From, To]. An empty Types matches every data type.
+type RawDocumentFilter struct {
+	UserID string
+	From   time.Time
+	To     time.Time
+	Types  []string
+}
+
+// ListRawDocumentKeys returns up to limit documents matching filter, ordered
+// by key and starting after the given key, so callers can page through a
+// large archive without holding a cursor open.
+func (r *Repository) ListRawDocumentKeys(ctx context.Context, filter RawDocumentFilter, after RawDocumentKey, limit int) ([]RawDocumentKey, error) {
+	query := `
+		SELECT DISTINCT user_id, provider, data_type, upstream_id
+		FROM raw_documents
+		WHERE user_id = $1 AND day BETWEEN $2 AND $3
+			AND (cardinality($4::text[]) = 0 OR data_type = ANY($4))
+			AND (provider, data_type, upstream_id) > ($5, $6, $7)
+		ORDER BY provider, data_type, upstream_id
+		LIMIT $8
+	`
+	types := filter.Types
+	if types == nil {
+		types = []string{}
+	}
+	rows, err := r.q.Query(ctx, query, filter.UserID, filter.From, filter.To, types,
+		after.Provider, after.DataType, after.UpstreamID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []RawDocumentKey
+	for rows.Next() {
+		var k RawDocumentKey
+		if err := rows.Scan(&k.UserID, &k.Provider, &k.DataType, &k.UpstreamID); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// GetLatestRawDocument returns the most recently seen version of a user's
+// document, or nil if none is archived.
+func (r *Repository) GetLatestRawDocument(ctx context.Context, key RawDocumentKey) (*RawDocument, error) {
+	query := `
+		SELECT provider, data_type, upstream_id, user_id, day, fetched_at, document
+		FROM raw_documents
+		WHERE user_id = $1 AND provider = $2 AND data_type = $3 AND upstream_id = $4
+		ORDER BY last_seen_at DESC, fetched_at DESC
+		LIMIT 1
+	`
+	var doc RawDocument
+	err := r.q.QueryRow(ctx, query, key.UserID, key.Provider, key.DataType, key.UpstreamID).Scan(
+		&doc.Provider, &doc.DataType, &doc.UpstreamID, &doc.UserID, &doc.Day, &doc.FetchedAt, &doc.Document,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/jackc/pgx/v5"
+)
+
+// sampleBatchSize bounds the rows written per INSERT, keeping it well under
+// Postgres's limit of 65535 bind parameters.
+const sampleBatchSize = 1000
+
+// SaveSamples upserts the samples of series type t for a user into t's
+// partitioned table, creating the monthly partitions they fall in. Each
+// sample is a row of t's Columns, the first being its timestamp; of several
+// samples with the same timestamp the last wins. It returns false if every
+// sample was already stored unchanged.
+func (r *Repository) SaveSamples(ctx context.Context, t *ingest.MetricType, userID string, samples [][]interface{}) (bool, error) {
+	samples = dedupeSamples(samples)
+
+	months := make(map[string]bool)
+	for _, s := range samples {
+		ts, ok := s[0].(time.Time)
+		if !ok {
+			return false, fmt.Errorf("%s: sample timestamp is %T, not time.Time", t.Name, s[0])
+		}
+		month := ts.UTC().Format("2006-01")
+		if months[month] {
+			continue
+		}
+		months[month] = true
+		if _, err := r.q.Exec(ctx, `SELECT ensure_monthly_partition($1, $2)`, t.Table, ts); err != nil {
+			return false, fmt.Errorf("create %s partition for %s: %w", t.Table, month, err)
+		}
+	}
+
+	var stored bool
+	for start := 0; start < len(samples); start += sampleBatchSize {
+		end := start + sampleBatchSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		n, err := r.saveSampleBatch(ctx, t, userID, samples[start:end])
+		if err != nil {
+			return false, err
+		}
+		stored = stored || n > 0
+	}
+	return stored, nil
+}
+
+func (r *Repository) saveSampleBatch(ctx context.Context, t *ingest.MetricType, userID string, samples [][]interface{}) (int64, error) {
+	table := pgx.Identifier{t.Table}.Sanitize()
+	cols := []string{"user_id"}
+	for _, col := range t.Columns {
+		cols = append(cols, pgx.Identifier{col}.Sanitize())
+	}
+
+	var sets, stored, excluded []string
+	for _, col := range cols[2:] {
+		sets = append(sets, col+" = EXCLUDED."+col)
+		stored = append(stored, table+"."+col)
+		excluded = append(excluded, "EXCLUDED."+col)
+	}
+
+	rows := make([]string, len(samples))
+	args := make([]interface{}, 0, len(samples)*len(cols))
+	for i, s := range samples {
+		placeholders := make([]string, len(cols))
+		args = append(args, userID)
+		for j := range cols {
+			placeholders[j] = fmt.Sprintf("$%d", i*len(cols)+j+1)
+			if j > 0 {
+				args = append(args, s[j-1])
+			}
+		}
+		rows[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		VALUES %s
+		ON CONFLICT (user_id, %s)
+		DO UPDATE SET %s
+		WHERE (%s) IS DISTINCT FROM (%s)
+	`, table, strings.Join(cols, ", "), strings.Join(rows, ", "), cols[1],
+		strings.Join(sets, ", "), strings.Join(stored, ", "), strings.Join(excluded, ", "))
+
+	tag, err := r.q.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// dedupeSamples drops all but the last sample for each timestamp, as one
+// INSERT cannot upsert the same row twice.
+func dedupeSamples(samples [][]interface{}) [][]interface{} {
+	last := make(map[string]int, len(samples))
+	for i, s := range samples {
+		last[fmt.Sprint(s[0])] = i
+	}
+	if len(last) == len(samples) {
+		return samples
+	}
+
+	deduped := make([][]interface{}, 0, len(last))
+	for i, s := range samples {
+		if last[fmt.Sprint(s[0])] == i {
+			deduped = append(deduped, s)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,211 @@
+// Package reprocess re-derives stored metrics from archived raw documents
+// using the current parsing and validation code.
+package reprocess
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/processor"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+)
+
+// pageSize is how many documents are listed from the archive at a time.
+const pageSize = 500
+
+// Outcomes of reprocessing one document.
+const (
+	StatusCreated   = "created"
+	StatusUpdated   = "updated"
+	StatusUnchanged = "unchanged"
+	StatusFailed    = "failed"
+)
+
+// Options selects what to reprocess.
+type Options struct {
+	UserID string
+	From   time.Time
+	To     time.Time
+	// Types limits reprocessing to these data types; empty means all.
+	Types []string
+	// DryRun reports what would change without writing anything.
+	DryRun bool
+}
+
+// Change is one field whose stored value differs from the re-derived one.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Result is the outcome for one document.
+type Result struct {
+	Type       string
+	UpstreamID string
+	Day        time.Time
+	Status     string
+	Changes    []Change
+	// Err is why the document failed, e.g. it no longer validates.
+	Err error
+}
+
+// Summary counts results by status.
+type Summary struct {
+	Documents int
+	Created   int
+	Updated   int
+	Unchanged int
+	Failed    int
+}
+
+func (s *Summary) add(res *Result) {
+	s.Documents++
+	switch res.Status {
+	case StatusCreated:
+		s.Created++
+	case StatusUpdated:
+		s.Updated++
+	case StatusUnchanged:
+		s.Unchanged++
+	case StatusFailed:
+		s.Failed++
+	}
+}
+
+type Reprocessor struct {
+	repo *repository.Repository
+}
+
+func New(repo *repository.Repository) *Reprocessor {
+	return &Reprocessor{repo: repo}
+}
+
+// Run re-derives the metric for the latest version of every archived document
+// matching opts and passes each result to report. Documents that fail to
+// parse or validate are reported and skipped; a database error stops the run.
+//
+// Each document is handled in its own transaction holding the same lock live
+// ingest takes, and its latest version is read under that lock, so a
+// concurrent ingest of a newer version is never overwritten.
+func (r *Reprocessor) Run(ctx context.Context, opts Options, report func(*Result)) (Summary, error) {
+	var summary Summary
+	filter := repository.RawDocumentFilter{UserID: opts.UserID, From: opts.From, To: opts.To, Types: opts.Types}
+
+	var after repository.RawDocumentKey
+	for {
+		keys, err := r.repo.ListRawDocumentKeys(ctx, filter, after, pageSize)
+		if err != nil {
+			return summary, fmt.Errorf("list raw documents: %w", err)
+		}
+
+		for _, key := range keys {
+			res, err := r.reprocess(ctx, opts, key)
+			if err != nil {
+				return summary, fmt.Errorf("%s %s: %w", key.DataType, key.UpstreamID, err)
+			}
+			summary.add(res)
+			report(res)
+		}
+
+		if len(keys) < pageSize {
+			return summary, nil
+		}
+		after = keys[len(keys)-1]
+	}
+}
+
+func (r *Reprocessor) reprocess(ctx context.Context, opts Options, key repository.RawDocumentKey) (*Result, error) {
+	res := &Result{Type: key.DataType, UpstreamID: key.UpstreamID}
+
+	err := r.repo.InTx(ctx, func(tx *repository.Repository) error {
+		if err := tx.LockDocument(ctx, key.DataType, key.UserID, key.UpstreamID); err != nil {
+			return err
+		}
+		doc, err := tx.GetLatestRawDocument(ctx, key)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			return fmt.Errorf("raw document disappeared")
+		}
+		res.Day = doc.Day
+
+		rec, err := Derive(doc)
+		if err == nil && rec.Type.Series {
+			err = fmt.Errorf("%s is a series and cannot be reprocessed", rec.Type.Name)
+		}
+		if err != nil {
+			res.Status = StatusFailed
+			res.Err = err
+			return nil
+		}
+		res.Day = rec.Metric.Day
+
+		current, err := tx.GetMetric(ctx, rec.Type, rec.Metric.UserID, rec.Metric.OuraID)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			res.Status = StatusCreated
+		} else if res.Changes = Diff(rec.Type, current, rec.Metric); len(res.Changes) > 0 {
+			res.Status = StatusUpdated
+		} else {
+			res.Status = StatusUnchanged
+		}
+
+		if opts.DryRun || res.Status == StatusUnchanged {
+			return nil
+		}
+		_, err = tx.SaveMetric(ctx, rec.Type, rec.Metric)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Derive parses an archived document and validates it exactly as live
+// ingest would, returning the row it should be stored as.
+func Derive(doc *repository.RawDocument) (*processor.Record, error) {
+	if doc.Provider != ingest.SourceOura {
+		return nil, fmt.Errorf("no parser for provider %q", doc.Provider)
+	}
+	payload, err := ingest.FromOura(doc.DataType, doc.Document)
+	if err != nil {
+		return nil, err
+	}
+	env, err := ingest.New(doc.DataType, doc.UserID, doc.Provider, payload)
+	if err != nil {
+		return nil, err
+	}
+	return processor.Derive(env)
+}
+
+// Diff compares two metrics of type t by day and value columns. Timestamps
+// compare as instants, whatever zone they were read back in.
+func Diff(t *ingest.MetricType, old, new *repository.Metric) []Change {
+	var changes []Change
+	if o, n := old.Day.Format("2006-01-02"), new.Day.Format("2006-01-02"); o != n {
+		changes = append(changes, Change{Field: "day", Old: o, New: n})
+	}
+	for _, col := range t.Columns {
+		if o, n := format(old.Values[col]), format(new.Values[col]); o != n {
+			changes = append(changes, Change{Field: col, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
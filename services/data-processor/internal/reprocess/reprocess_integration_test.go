@@ -0,0 +1,205 @@
+package reprocess_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/processor"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/reprocess"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	integration "github.com/asian-code/myapp-kubernetes/services/pkg/testing"
+	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// setupDatabase starts a migrated PostgreSQL container, stopped when the
+// test ends.
+func setupDatabase(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	ctx := context.Background()
+
+	pgContainer, err := integration.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("Failed to start PostgreSQL container: %v", err)
+	}
+	t.Cleanup(func() { pgContainer.Close(ctx) })
+
+	pool, err := pgContainer.GetPool(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := pgContainer.RunMigrations(ctx, pool); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	return pool
+}
+
+func createUser(t *testing.T, pool *pgxpool.Pool, name string) string {
+	t.Helper()
+	var id string
+	err := pool.QueryRow(context.Background(), `INSERT INTO users (username, email, password_hash) VALUES ($1, $2, 'x') RETURNING id::text`, name, name+"@example.com").Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to create user %s: %v", name, err)
+	}
+	return id
+}
+
+// ingestCardiovascularAge stores raw for userID as live ingest would, fetched
+// at fetchedAt.
+func ingestCardiovascularAge(t *testing.T, repo *repository.Repository, proc *processor.Processor, userID string, raw json.RawMessage, fetchedAt time.Time) {
+	t.Helper()
+	ctx := context.Background()
+
+	payload, err := ingest.FromOura(ingest.TypeCardiovascularAge, raw)
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	env, err := ingest.New(ingest.TypeCardiovascularAge, userID, ingest.SourceOura, payload)
+	if err != nil {
+		t.Fatalf("Failed to build envelope: %v", err)
+	}
+	env = env.WithRaw(raw, fetchedAt)
+	err = repo.InTx(ctx, func(tx *repository.Repository) error {
+		_, err := proc.Save(ctx, tx, env)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to save document for %s: %v", userID, err)
+	}
+}
+
+func TestReprocessKeepsUsersApart_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	ctx := context.Background()
+	pool := setupDatabase(t)
+	userA, userB := createUser(t, pool, "a"), createUser(t, pool, "b")
+
+	repo := repository.New(pool, nil)
+	proc := processor.New(metrics.New("data-processor-test"))
+
+	// Cardiovascular age has no upstream ID, so both users' documents for a
+	// day share one, and with the same vascular age the same content
+	raw := json.RawMessage(`{"day": "2024-01-10", "vascular_age": 40}`)
+	for _, userID := range []string{userA, userB} {
+		ingestCardiovascularAge(t, repo, proc, userID, raw, time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC))
+	}
+
+	var archived int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM raw_documents`).Scan(&archived); err != nil {
+		t.Fatalf("Failed to count raw documents: %v", err)
+	}
+	if archived != 2 {
+		t.Fatalf("Expected a raw document archived for each user, got %d", archived)
+	}
+
+	// Drift user A's stored row so reprocessing has something to fix
+	if _, err := pool.Exec(ctx, `UPDATE cardiovascular_age_metrics SET vascular_age = 99 WHERE user_id = $1`, userA); err != nil {
+		t.Fatalf("Failed to update metric: %v", err)
+	}
+
+	var results []*reprocess.Result
+	opts := reprocess.Options{
+		UserID: userA,
+		From:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		Types:  []string{ingest.TypeCardiovascularAge},
+	}
+	summary, err := reprocess.New(repo).Run(ctx, opts, func(res *reprocess.Result) { results = append(results, res) })
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary.Documents != 1 || summary.Updated != 1 {
+		t.Fatalf("Expected user A's one document to be updated, got %+v", summary)
+	}
+	if want := []reprocess.Change{{Field: "vascular_age", Old: "99", New: "40"}}; len(results[0].Changes) != 1 || results[0].Changes[0] != want[0] {
+		t.Errorf("Expected changes %+v, got %+v", want, results[0].Changes)
+	}
+
+	ages := make(map[string]int)
+	rows, err := pool.Query(ctx, `SELECT user_id::text, vascular_age FROM cardiovascular_age_metrics`)
+	if err != nil {
+		t.Fatalf("Failed to read metrics: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		var age int
+		if err := rows.Scan(&userID, &age); err != nil {
+			t.Fatalf("Failed to scan metric: %v", err)
+		}
+		ages[userID] = age
+	}
+	if ages[userA] != 40 || ages[userB] != 40 || len(ages) != 2 {
+		t.Errorf("Expected both users at 40 with one row each, got %v", ages)
+	}
+
+	opts.UserID = userB
+	summary, err = reprocess.New(repo).Run(ctx, opts, func(*reprocess.Result) {})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary.Documents != 1 || summary.Unchanged != 1 {
+		t.Errorf("Expected user B's one document to be unchanged, got %+v", summary)
+	}
+}
+
+func TestReprocessUsesVersionSeenLast_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	ctx := context.Background()
+	pool := setupDatabase(t)
+	userID := createUser(t, pool, "a")
+
+	repo := repository.New(pool, nil)
+	proc := processor.New(metrics.New("data-processor-test"))
+
+	// Oura revises the document and then revises it back
+	a := json.RawMessage(`{"day": "2024-01-10", "vascular_age": 40}`)
+	b := json.RawMessage(`{"day": "2024-01-10", "vascular_age": 42}`)
+	ingestCardiovascularAge(t, repo, proc, userID, a, time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC))
+	ingestCardiovascularAge(t, repo, proc, userID, b, time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC))
+	ingestCardiovascularAge(t, repo, proc, userID, a, time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC))
+
+	var archived int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM raw_documents`).Scan(&archived); err != nil {
+		t.Fatalf("Failed to count raw documents: %v", err)
+	}
+	if archived != 2 {
+		t.Fatalf("Expected the two distinct versions archived, got %d", archived)
+	}
+
+	doc, err := repo.GetLatestRawDocument(ctx, repository.RawDocumentKey{
+		UserID: userID, Provider: ingest.SourceOura, DataType: ingest.TypeCardiovascularAge, UpstreamID: "2024-01-10",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if doc == nil || !strings.Contains(string(doc.Document), `"vascular_age": 40`) {
+		t.Fatalf("Expected the version fetched last, got %v", doc)
+	}
+
+	opts := reprocess.Options{
+		UserID: userID,
+		From:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		Types:  []string{ingest.TypeCardiovascularAge},
+	}
+	summary, err := reprocess.New(repo).Run(ctx, opts, func(*reprocess.Result) {})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary.Documents != 1 || summary.Unchanged != 1 {
+		t.Errorf("Expected the current metric to be left as it is, got %+v", summary)
+	}
+}
@@ -0,0 +1,72 @@
+package reprocess
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+)
+
+const testUserID = "6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b"
+
+func TestDerive(t *testing.T) {
+	doc := &repository.RawDocument{
+		Provider:   ingest.SourceOura,
+		DataType:   ingest.TypeSleep,
+		UpstreamID: "s1",
+		UserID:     testUserID,
+		Document:   json.RawMessage(`{"id": "s1", "day": "2024-01-10", "score": 82, "duration": 28800, "contributors": {}}`),
+	}
+
+	rec, err := Derive(doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := &repository.Metric{
+		UserID: testUserID,
+		OuraID: "s1",
+		Day:    time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+		Values: map[string]interface{}{"score": 82, "duration": 28800},
+	}
+	if !reflect.DeepEqual(rec.Metric, want) {
+		t.Errorf("Expected %+v, got %+v", want, rec.Metric)
+	}
+
+	doc.Document = json.RawMessage(`{"id": "s1", "day": "2024-01-10", "score": 140}`)
+	if _, err := Derive(doc); err == nil {
+		t.Error("Expected error for a document that no longer validates")
+	}
+
+	doc.Provider = "fitbit"
+	if _, err := Derive(doc); err == nil {
+		t.Error("Expected error for a provider without a parser")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	activity, _ := ingest.Lookup(ingest.TypeActivity)
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	// Stored values come back from Postgres as int32, derived ones as int.
+	old := &repository.Metric{UserID: testUserID, OuraID: "a1", Day: day, Values: map[string]interface{}{
+		"score": int32(80), "active_calories": nil, "steps": int32(9000), "medium_activity_minutes": int32(30), "high_activity_minutes": int32(0),
+	}}
+	new := &repository.Metric{UserID: testUserID, OuraID: "a1", Day: day, Values: map[string]interface{}{
+		"score": 82, "active_calories": 450, "steps": 9000, "medium_activity_minutes": 35, "high_activity_minutes": 0,
+	}}
+
+	want := []Change{
+		{Field: "score", Old: "80", New: "82"},
+		{Field: "active_calories", Old: "null", New: "450"},
+		{Field: "medium_activity_minutes", Old: "30", New: "35"},
+	}
+	if got := Diff(activity, old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if got := Diff(activity, old, old); len(got) != 0 {
+		t.Errorf("Expected no changes, got %+v", got)
+	}
+}
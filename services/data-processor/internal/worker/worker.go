@@ -0,0 +1,188 @@
+// Package worker drains the ingest queue with a pool of concurrent workers.
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/deadletter"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/processor"
+	"github.com/asian-code/myapp-kubernetes/services/data-processor/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxBackoff caps the delay between attempts of a failing job.
+	maxBackoff = 5 * time.Minute
+	// housekeepingInterval is how often the backlog gauges are refreshed and
+	// expired idempotency keys and request nonces are purged.
+	housekeepingInterval = 15 * time.Second
+)
+
+// Config controls the worker pool.
+type Config struct {
+	Workers      int
+	MaxAttempts  int
+	PollInterval time.Duration
+	BackoffBase  time.Duration
+}
+
+// Pool claims queued envelopes and stores them. Jobs are claimed with
+// SELECT ... FOR UPDATE SKIP LOCKED, so any number of pools, across replicas,
+// can share one queue.
+type Pool struct {
+	repo      *repository.Repository
+	processor *processor.Processor
+	cfg       Config
+	logger    *log.Entry
+	metrics   *metrics.Metrics
+}
+
+func New(repo *repository.Repository, proc *processor.Processor, cfg Config, logger *log.Entry, m *metrics.Metrics) *Pool {
+	return &Pool{
+		repo:      repo,
+		processor: proc,
+		cfg:       cfg,
+		logger:    logger.WithField("component", "worker"),
+		metrics:   m,
+	}
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every worker
+// has finished its current job.
+func (p *Pool) Run(ctx context.Context) {
+	p.logger.WithField("workers", p.cfg.Workers).Info("Starting ingest workers")
+
+	var wg sync.WaitGroup
+	wg.Add(p.cfg.Workers + 1)
+	for i := 0; i < p.cfg.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	go func() {
+		defer wg.Done()
+		p.housekeep(ctx)
+	}()
+	wg.Wait()
+
+	p.logger.Info("Ingest workers stopped")
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		found, err := p.processNext(ctx)
+		if err != nil {
+			p.logger.WithError(err).Error("Failed to process queued record")
+			p.metrics.ProcessingErrors.WithLabelValues("queue").Inc()
+		}
+		if found && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(p.cfg.PollInterval):
+		}
+	}
+}
+
+// processNext claims and processes one job. It returns false if the queue had
+// nothing available. The claim and the stored metric commit together, so a
+// job is never lost or half-applied; a job in flight at shutdown finishes
+// before the worker exits.
+func (p *Pool) processNext(ctx context.Context) (bool, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	var found bool
+	err := p.repo.InTx(ctx, func(tx *repository.Repository) error {
+		job, err := tx.ClaimJob(ctx)
+		if err != nil || job == nil {
+			return err
+		}
+		found = true
+
+		start := time.Now()
+		err = tx.InTx(ctx, func(sp *repository.Repository) error {
+			_, err := p.processor.Save(ctx, sp, job.Envelope)
+			return err
+		})
+		p.metrics.ProcessingDuration.Observe(time.Since(start).Seconds())
+		if err == nil {
+			return tx.CompleteJob(ctx, job.ID)
+		}
+
+		attempts := job.Attempts + 1
+		logger := p.logger.WithError(err).WithFields(log.Fields{
+			"job_id":   job.ID,
+			"type":     job.Envelope.Type,
+			"user_id":  job.Envelope.UserID,
+			"attempts": attempts,
+		})
+
+		if processor.IsPermanent(err) || attempts >= p.cfg.MaxAttempts {
+			logger.Error("Giving up on queued record, moving it to the dead-letter store")
+			p.metrics.ProcessingErrors.WithLabelValues("failed").Inc()
+			if dlErr := deadletter.Record(ctx, tx, deadletter.SourceQueue, job.Raw, job.Envelope, attempts, err); dlErr != nil {
+				return dlErr
+			}
+			return tx.CompleteJob(ctx, job.ID)
+		}
+
+		delay := p.backoff(attempts)
+		logger.WithField("retry_in", delay).Warn("Queued record failed, will retry")
+		p.metrics.ProcessingErrors.WithLabelValues("retry").Inc()
+		return tx.RetryJob(ctx, job.ID, attempts, err.Error(), delay)
+	})
+	return found, err
+}
+
+// backoff returns the delay before the next attempt of a job that has failed
+// attempts times: BackoffBase doubled per failure, capped at maxBackoff.
+func (p *Pool) backoff(attempts int) time.Duration {
+	delay := p.cfg.BackoffBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// housekeep periodically refreshes the queue depth and dead-letter backlog
+// gauges and purges expired idempotency keys and request nonces.
+func (p *Pool) housekeep(ctx context.Context) {
+	ticker := time.NewTicker(housekeepingInterval)
+	defer ticker.Stop()
+
+	for {
+		depth, err := p.repo.QueueDepth(ctx)
+		if err == nil {
+			p.metrics.ProcessingQueueDepth.Set(float64(depth))
+			var backlog int
+			backlog, err = p.repo.CountDeadLetters(ctx)
+			if err == nil {
+				p.metrics.DeadLetterBacklog.Set(float64(backlog))
+			}
+		}
+		if err == nil {
+			_, err = p.repo.DeleteExpiredIdempotencyKeys(ctx)
+		}
+		if err == nil {
+			_, err = p.repo.DeleteExpiredNonces(ctx)
+		}
+		if err != nil && ctx.Err() == nil {
+			p.logger.WithError(err).Warn("Queue housekeeping failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := &Pool{cfg: Config{BackoffBase: time.Second}}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{8, 128 * time.Second},
+		{9, 256 * time.Second},
+		{10, maxBackoff},
+		{50, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := p.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+DROP INDEX IF EXISTS idx_readiness_user_day;
+DROP INDEX IF EXISTS idx_activity_user_day;
+DROP INDEX IF EXISTS idx_sleep_user_day;
+
+CREATE INDEX IF NOT EXISTS idx_sleep_day ON sleep_metrics(day);
+CREATE INDEX IF NOT EXISTS idx_activity_day ON activity_metrics(day);
+CREATE INDEX IF NOT EXISTS idx_readiness_day ON readiness_metrics(day);
+
+ALTER TABLE readiness_metrics DROP CONSTRAINT IF EXISTS readiness_metrics_user_oura_id_key;
+ALTER TABLE activity_metrics DROP CONSTRAINT IF EXISTS activity_metrics_user_oura_id_key;
+ALTER TABLE sleep_metrics DROP CONSTRAINT IF EXISTS sleep_metrics_user_oura_id_key;
+
+ALTER TABLE sleep_metrics ADD CONSTRAINT sleep_metrics_oura_id_key UNIQUE (oura_id);
+ALTER TABLE activity_metrics ADD CONSTRAINT activity_metrics_oura_id_key UNIQUE (oura_id);
+ALTER TABLE readiness_metrics ADD CONSTRAINT readiness_metrics_oura_id_key UNIQUE (oura_id);
+
+ALTER TABLE readiness_metrics DROP COLUMN IF EXISTS user_id;
+ALTER TABLE activity_metrics DROP COLUMN IF EXISTS user_id;
+ALTER TABLE sleep_metrics DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,55 @@
+-- Scope health metrics to the user that owns them.
+-- Rows ingested before multi-user support have no owner and cannot be
+-- attributed automatically. If there are any, the migration fails unless
+-- myhealth.legacy_metrics_owner names the user they belong to (the migrator
+-- sets it from LEGACY_METRICS_OWNER).
+ALTER TABLE sleep_metrics ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE activity_metrics ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE readiness_metrics ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+DO $$
+DECLARE
+    legacy_owner TEXT := NULLIF(current_setting('myhealth.legacy_metrics_owner', true), '');
+    ownerless BIGINT;
+BEGIN
+    SELECT (SELECT COUNT(*) FROM sleep_metrics WHERE user_id IS NULL)
+        + (SELECT COUNT(*) FROM activity_metrics WHERE user_id IS NULL)
+        + (SELECT COUNT(*) FROM readiness_metrics WHERE user_id IS NULL)
+    INTO ownerless;
+
+    IF ownerless = 0 THEN
+        RETURN;
+    END IF;
+    IF legacy_owner IS NULL THEN
+        RAISE EXCEPTION '% health metric rows have no owner; set LEGACY_METRICS_OWNER to the ID of the user they belong to and migrate again', ownerless;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM users WHERE id::text = legacy_owner) THEN
+        RAISE EXCEPTION 'LEGACY_METRICS_OWNER % is not a registered user', legacy_owner;
+    END IF;
+
+    UPDATE sleep_metrics SET user_id = legacy_owner::uuid WHERE user_id IS NULL;
+    UPDATE activity_metrics SET user_id = legacy_owner::uuid WHERE user_id IS NULL;
+    UPDATE readiness_metrics SET user_id = legacy_owner::uuid WHERE user_id IS NULL;
+END $$;
+
+ALTER TABLE sleep_metrics ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE activity_metrics ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE readiness_metrics ALTER COLUMN user_id SET NOT NULL;
+
+-- Oura IDs are only unique within a single user's data
+ALTER TABLE sleep_metrics DROP CONSTRAINT IF EXISTS sleep_metrics_oura_id_key;
+ALTER TABLE activity_metrics DROP CONSTRAINT IF EXISTS activity_metrics_oura_id_key;
+ALTER TABLE readiness_metrics DROP CONSTRAINT IF EXISTS readiness_metrics_oura_id_key;
+
+ALTER TABLE sleep_metrics ADD CONSTRAINT sleep_metrics_user_oura_id_key UNIQUE (user_id, oura_id);
+ALTER TABLE activity_metrics ADD CONSTRAINT activity_metrics_user_oura_id_key UNIQUE (user_id, oura_id);
+ALTER TABLE readiness_metrics ADD CONSTRAINT readiness_metrics_user_oura_id_key UNIQUE (user_id, oura_id);
+
+-- Every read filters by user and date range
+DROP INDEX IF EXISTS idx_sleep_day;
+DROP INDEX IF EXISTS idx_activity_day;
+DROP INDEX IF EXISTS idx_readiness_day;
+
+CREATE INDEX IF NOT EXISTS idx_sleep_user_day ON sleep_metrics(user_id, day DESC);
+CREATE INDEX IF NOT EXISTS idx_activity_user_day ON activity_metrics(user_id, day DESC);
+CREATE INDEX IF NOT EXISTS idx_readiness_user_day ON readiness_metrics(user_id, day DESC);
@@ -0,0 +1,12 @@
+-- Track how far each historical backfill has progressed so an interrupted
+-- run can resume from the last completed chunk
+CREATE TABLE IF NOT EXISTS backfill_progress (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    data_type VARCHAR(50) NOT NULL,
+    range_start DATE NOT NULL,
+    range_end DATE NOT NULL,
+    completed_through DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, data_type, range_start)
+);
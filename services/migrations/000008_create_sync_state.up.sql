@@ -0,0 +1,15 @@
+-- Per-user, per-data-type checkpoints for incremental collection
+CREATE TABLE IF NOT EXISTS sync_state (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL DEFAULT 'oura',
+    data_type VARCHAR(50) NOT NULL,
+    last_synced_day DATE NOT NULL,
+    -- Page cursor of an interrupted page walk over cursor_start through
+    -- cursor_end, resumed by the next sync; NULL once the walk completes
+    last_cursor TEXT,
+    cursor_start DATE,
+    cursor_end DATE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, provider, data_type)
+);
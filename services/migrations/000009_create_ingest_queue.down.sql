@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ingest_queue_pending;
+DROP TABLE IF EXISTS ingest_queue;
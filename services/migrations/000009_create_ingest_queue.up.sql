@@ -0,0 +1,15 @@
+-- Durable work queue for asynchronous ingestion. Workers claim rows with
+-- SELECT ... FOR UPDATE SKIP LOCKED and delete them once processed.
+CREATE TABLE IF NOT EXISTS ingest_queue (
+    id BIGSERIAL PRIMARY KEY,
+    envelope JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    available_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT ingest_queue_status_check CHECK (status IN ('pending', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingest_queue_pending ON ingest_queue(available_at, id) WHERE status = 'pending';
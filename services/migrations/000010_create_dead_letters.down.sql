@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_ingest_queue_available;
+ALTER TABLE ingest_queue ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending';
+ALTER TABLE ingest_queue ADD CONSTRAINT ingest_queue_status_check CHECK (status IN ('pending', 'failed'));
+CREATE INDEX IF NOT EXISTS idx_ingest_queue_pending ON ingest_queue(available_at, id) WHERE status = 'pending';
+
+DROP INDEX IF EXISTS idx_dead_letters_last_failed_at;
+DROP TABLE IF EXISTS dead_letters;
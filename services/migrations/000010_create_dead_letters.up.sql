@@ -0,0 +1,34 @@
+-- Records that could not be stored, kept for inspection, editing and replay.
+-- payload holds the raw bytes as received, which may not be valid JSON.
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    source VARCHAR(20) NOT NULL,
+    data_type VARCHAR(50),
+    user_id UUID,
+    payload BYTEA NOT NULL,
+    payload_hash CHAR(64) NOT NULL,
+    error TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    first_failed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_failed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT dead_letters_payload_hash_key UNIQUE (payload_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_last_failed_at ON dead_letters(last_failed_at DESC);
+
+-- Failed queue jobs now move to dead_letters, so the queue only holds pending work.
+INSERT INTO dead_letters (source, data_type, user_id, payload, payload_hash, error, attempts, first_failed_at, last_failed_at)
+SELECT 'queue', envelope->>'type', (envelope->>'user_id')::uuid,
+       convert_to(envelope::text, 'UTF8'), encode(sha256(convert_to(envelope::text, 'UTF8')), 'hex'),
+       COALESCE(last_error, ''), attempts, created_at, updated_at
+FROM ingest_queue
+WHERE status = 'failed'
+ON CONFLICT (payload_hash) DO NOTHING;
+
+DELETE FROM ingest_queue WHERE status = 'failed';
+
+DROP INDEX IF EXISTS idx_ingest_queue_pending;
+ALTER TABLE ingest_queue DROP CONSTRAINT IF EXISTS ingest_queue_status_check;
+ALTER TABLE ingest_queue DROP COLUMN IF EXISTS status;
+CREATE INDEX IF NOT EXISTS idx_ingest_queue_available ON ingest_queue(available_at, id);
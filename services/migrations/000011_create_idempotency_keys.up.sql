@@ -0,0 +1,16 @@
+-- Responses to ingest requests sent with an idempotency key, so a retried
+-- request gets the original result. status_code is NULL while the first
+-- request is still in flight.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    endpoint VARCHAR(100) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(100),
+    response BYTEA,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (endpoint, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_request_nonces_expires_at;
+DROP TABLE IF EXISTS request_nonces;
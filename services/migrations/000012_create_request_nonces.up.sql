@@ -0,0 +1,8 @@
+-- Nonces of signed service-to-service requests, kept until the request's
+-- signature expires so a captured request cannot be replayed on any replica.
+CREATE TABLE IF NOT EXISTS request_nonces (
+    nonce VARCHAR(200) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_nonces_expires_at ON request_nonces(expires_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_raw_documents_user_type_day;
+DROP TABLE IF EXISTS raw_documents;
@@ -0,0 +1,21 @@
+-- Every upstream document exactly as the provider returned it, so metrics can
+-- be re-derived without fetching again. A document is stored again only when
+-- its content changes; fetching content already stored moves its last_seen_at
+-- forward, so a document that changes back is current again. Upstream IDs are
+-- only unique within one user's data.
+CREATE TABLE IF NOT EXISTS raw_documents (
+    provider VARCHAR(50) NOT NULL,
+    data_type VARCHAR(50) NOT NULL,
+    upstream_id VARCHAR(255) NOT NULL,
+    fetched_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    content_hash CHAR(64) NOT NULL,
+    document JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, provider, data_type, upstream_id, fetched_at),
+    CONSTRAINT raw_documents_content_key UNIQUE (user_id, provider, data_type, upstream_id, content_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_raw_documents_user_type_day ON raw_documents(user_id, data_type, day);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_sleep_periods_user_day;
+DROP TABLE IF EXISTS sleep_periods;
@@ -0,0 +1,25 @@
+-- One row per Oura sleep period (the `sleep` endpoint, not `daily_sleep`).
+-- A day can have several periods, e.g. a night's sleep and a nap.
+CREATE TABLE IF NOT EXISTS sleep_periods (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    type VARCHAR(50),
+    bedtime_start TIMESTAMPTZ NOT NULL,
+    bedtime_end TIMESTAMPTZ NOT NULL,
+    deep_sleep_duration INTEGER,
+    light_sleep_duration INTEGER,
+    rem_sleep_duration INTEGER,
+    awake_time INTEGER,
+    efficiency INTEGER,
+    latency INTEGER,
+    lowest_heart_rate INTEGER,
+    average_hrv INTEGER,
+    sleep_phase_5_min TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sleep_periods_user_day ON sleep_periods(user_id, day DESC);
@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS ensure_monthly_partition(TEXT, TIMESTAMPTZ);
+DROP TABLE IF EXISTS heartrate;
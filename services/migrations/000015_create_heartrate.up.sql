@@ -0,0 +1,27 @@
+-- Heart rate samples, one row per reading. The table grows by hundreds of
+-- rows per user per day, so it is range partitioned by month; partitions are
+-- created on demand by ensure_monthly_partition as samples arrive.
+CREATE TABLE IF NOT EXISTS heartrate (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    timestamp TIMESTAMPTZ NOT NULL,
+    bpm SMALLINT NOT NULL,
+    source VARCHAR(20),
+    PRIMARY KEY (user_id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+-- ensure_monthly_partition creates the partition of parent covering the UTC
+-- month of ts, named <parent>_YYYY_MM, if it does not exist yet. An advisory
+-- lock serialises concurrent callers, which would otherwise race to create it.
+CREATE OR REPLACE FUNCTION ensure_monthly_partition(parent TEXT, ts TIMESTAMPTZ) RETURNS VOID AS $$
+DECLARE
+    month_start TIMESTAMP := date_trunc('month', ts AT TIME ZONE 'UTC');
+    partition_name TEXT := parent || '_' || to_char(ts AT TIME ZONE 'UTC', 'YYYY_MM');
+BEGIN
+    IF to_regclass(partition_name) IS NOT NULL THEN
+        RETURN;
+    END IF;
+    PERFORM pg_advisory_xact_lock(hashtext(partition_name));
+    EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+        partition_name, parent, month_start AT TIME ZONE 'UTC', (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC');
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,6 @@
+ALTER TABLE activity_metrics
+    DROP COLUMN IF EXISTS met_items,
+    DROP COLUMN IF EXISTS met_timestamp,
+    DROP COLUMN IF EXISTS met_interval,
+    DROP COLUMN IF EXISTS class_5_min,
+    DROP COLUMN IF EXISTS day_start;
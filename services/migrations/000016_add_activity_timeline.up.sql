@@ -0,0 +1,9 @@
+-- Intra-day activity from Oura daily_activity: the class_5_min intensity
+-- string, one character per 5 minutes from day_start, and the MET samples,
+-- one per met_interval seconds from met_timestamp.
+ALTER TABLE activity_metrics
+    ADD COLUMN IF NOT EXISTS day_start TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS class_5_min TEXT,
+    ADD COLUMN IF NOT EXISTS met_interval DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS met_timestamp TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS met_items DOUBLE PRECISION[];
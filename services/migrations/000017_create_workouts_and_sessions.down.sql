@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_sessions_user_day;
+DROP TABLE IF EXISTS sessions;
+DROP INDEX IF EXISTS idx_workouts_user_day;
+DROP TABLE IF EXISTS workouts;
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS workouts (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    activity VARCHAR(100) NOT NULL,
+    intensity VARCHAR(20),
+    label VARCHAR(255),
+    source VARCHAR(50),
+    start_datetime TIMESTAMPTZ NOT NULL,
+    end_datetime TIMESTAMPTZ NOT NULL,
+    calories DOUBLE PRECISION,
+    distance DOUBLE PRECISION,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workouts_user_day ON workouts(user_id, day DESC);
+
+-- Heart rate and HRV are summarised from the session's own samples.
+CREATE TABLE IF NOT EXISTS sessions (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    mood VARCHAR(20),
+    start_datetime TIMESTAMPTZ NOT NULL,
+    end_datetime TIMESTAMPTZ NOT NULL,
+    heart_rate_average DOUBLE PRECISION,
+    heart_rate_min DOUBLE PRECISION,
+    heart_rate_max DOUBLE PRECISION,
+    hrv_average DOUBLE PRECISION,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_day ON sessions(user_id, day DESC);
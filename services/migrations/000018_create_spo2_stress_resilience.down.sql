@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_resilience_user_day;
+DROP TABLE IF EXISTS resilience_metrics;
+DROP INDEX IF EXISTS idx_stress_user_day;
+DROP TABLE IF EXISTS stress_metrics;
+DROP INDEX IF EXISTS idx_spo2_user_day;
+DROP TABLE IF EXISTS spo2_metrics;
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS spo2_metrics (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    spo2_average DOUBLE PRECISION,
+    breathing_disturbance_index INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_spo2_user_day ON spo2_metrics(user_id, day DESC);
+
+-- stress_high and recovery_high are in seconds
+CREATE TABLE IF NOT EXISTS stress_metrics (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    stress_high INTEGER,
+    recovery_high INTEGER,
+    day_summary VARCHAR(20),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stress_user_day ON stress_metrics(user_id, day DESC);
+
+CREATE TABLE IF NOT EXISTS resilience_metrics (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    level VARCHAR(20) NOT NULL,
+    sleep_recovery DOUBLE PRECISION,
+    daytime_recovery DOUBLE PRECISION,
+    stress DOUBLE PRECISION,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_resilience_user_day ON resilience_metrics(user_id, day DESC);
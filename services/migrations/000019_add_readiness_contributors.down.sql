@@ -0,0 +1,11 @@
+ALTER TABLE readiness_metrics
+    DROP COLUMN IF EXISTS temperature_trend_deviation,
+    DROP COLUMN IF EXISTS temperature_deviation,
+    DROP COLUMN IF EXISTS sleep_balance,
+    DROP COLUMN IF EXISTS resting_heart_rate,
+    DROP COLUMN IF EXISTS recovery_index,
+    DROP COLUMN IF EXISTS previous_night,
+    DROP COLUMN IF EXISTS previous_day_activity,
+    DROP COLUMN IF EXISTS hrv_balance,
+    DROP COLUMN IF EXISTS body_temperature,
+    DROP COLUMN IF EXISTS activity_balance;
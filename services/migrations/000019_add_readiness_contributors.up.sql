@@ -0,0 +1,13 @@
+-- Readiness contributor scores (1-100) and body temperature deviations from
+-- the user's baseline in degrees Celsius, from Oura daily_readiness.
+ALTER TABLE readiness_metrics
+    ADD COLUMN IF NOT EXISTS activity_balance INTEGER,
+    ADD COLUMN IF NOT EXISTS body_temperature INTEGER,
+    ADD COLUMN IF NOT EXISTS hrv_balance INTEGER,
+    ADD COLUMN IF NOT EXISTS previous_day_activity INTEGER,
+    ADD COLUMN IF NOT EXISTS previous_night INTEGER,
+    ADD COLUMN IF NOT EXISTS recovery_index INTEGER,
+    ADD COLUMN IF NOT EXISTS resting_heart_rate INTEGER,
+    ADD COLUMN IF NOT EXISTS sleep_balance INTEGER,
+    ADD COLUMN IF NOT EXISTS temperature_deviation DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS temperature_trend_deviation DOUBLE PRECISION;
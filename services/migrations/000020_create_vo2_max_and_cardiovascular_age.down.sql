@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_cardiovascular_age_user_day;
+DROP TABLE IF EXISTS cardiovascular_age_metrics;
+DROP INDEX IF EXISTS idx_vo2_max_user_day;
+DROP TABLE IF EXISTS vo2_max_metrics;
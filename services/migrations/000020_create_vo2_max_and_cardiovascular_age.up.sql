@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS vo2_max_metrics (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    vo2_max DOUBLE PRECISION,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_vo2_max_user_day ON vo2_max_metrics(user_id, day DESC);
+
+-- Oura gives cardiovascular age documents no ID, so oura_id is their day
+CREATE TABLE IF NOT EXISTS cardiovascular_age_metrics (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    vascular_age INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cardiovascular_age_user_day ON cardiovascular_age_metrics(user_id, day DESC);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tags_user_day;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,19 @@
+-- Oura enhanced tags. day is the day the tag started; a tag without an
+-- end_day covers only that day.
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    tag_type_code VARCHAR(100),
+    custom_name VARCHAR(255),
+    comment TEXT,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ,
+    end_day DATE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tags_user_day ON tags(user_id, day DESC);
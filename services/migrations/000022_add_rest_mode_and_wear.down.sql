@@ -0,0 +1,3 @@
+ALTER TABLE activity_metrics DROP COLUMN IF EXISTS non_wear_time;
+DROP INDEX IF EXISTS idx_rest_mode_periods_user_day;
+DROP TABLE IF EXISTS rest_mode_periods;
@@ -0,0 +1,19 @@
+-- Oura rest mode periods. day is the day the period started; one without an
+-- end_day is still ongoing.
+CREATE TABLE IF NOT EXISTS rest_mode_periods (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    oura_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    start_time TIMESTAMPTZ,
+    end_time TIMESTAMPTZ,
+    end_day DATE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, oura_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rest_mode_periods_user_day ON rest_mode_periods(user_id, day DESC);
+
+-- Seconds the ring was not worn during the activity day
+ALTER TABLE activity_metrics ADD COLUMN IF NOT EXISTS non_wear_time INTEGER;
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -33,6 +34,12 @@ func main() {
 		dbUser, dbPassword, dbHost, dbPort, dbName, sslMode,
 	)
 
+	// Owner for health metrics stored before they were scoped to a user;
+	// migration 000006 refuses to guess one
+	if owner := os.Getenv("LEGACY_METRICS_OWNER"); owner != "" {
+		databaseURL += "&options=" + url.QueryEscape("-c myhealth.legacy_metrics_owner="+owner)
+	}
+
 	// Get migrations directory
 	migrationsPath := getEnv("MIGRATIONS_PATH", "file://migrations")
 
@@ -4,14 +4,23 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/client"
+	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/collector"
 	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/config"
+	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/signing"
 	"github.com/asian-code/myapp-kubernetes/services/shared/database"
 	"github.com/asian-code/myapp-kubernetes/services/shared/logger"
 	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
+	"github.com/asian-code/myapp-kubernetes/services/shared/tokens"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
 )
 
 func main() {
@@ -48,96 +57,136 @@ func main() {
 	}
 	defer db.Close()
 
-	// Fetch OAuth token from database
-	var accessToken string
-	var expiresAt time.Time
-	query := `SELECT access_token, expires_at FROM oauth_tokens WHERE user_id = $1 AND provider = 'oura'`
-	err = db.QueryRow(ctx, query, cfg.UserID).Scan(&accessToken, &expiresAt)
+	repo := repository.New(db, log)
+	tokenStore := tokens.NewPostgresStore(db)
+	refresher := tokens.NewOuraRefresher(cfg.OuraClientID, cfg.OuraClientSecret)
+
+	signingKeys, err := signing.ParseKeys(cfg.SigningKeys)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to get OAuth token from database. Please authorize the app first.")
+		log.WithError(err).Fatal("Invalid SIGNING_KEYS")
 	}
+	signer := signing.NewSigner(signingKeys[0])
 
-	// Check if token is expired (refresh is handled by api-service)
-	if time.Now().After(expiresAt) {
-		log.Fatal("OAuth token has expired. Please re-authorize the app.")
+	users, err := repo.ListOuraUsers(ctx, cfg.UserID)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to list users with Oura tokens")
+	}
+	if len(users) == 0 {
+		log.Warn("No users have authorized Oura; nothing to collect")
+		return
 	}
 
-	log.Info("OAuth token retrieved successfully")
+	log.WithField("users", len(users)).Info("Collecting for users with Oura tokens")
 
 	// Record collection run start
 	startTime := time.Now()
 	m.CollectionRunsTotal.Inc()
 
-	// Fetch data from Oura
-	ouraClient := client.New(accessToken, log)
+	// Stop cleanly on SIGINT/SIGTERM; checkpoints only cover acknowledged data
+	// so an interrupted run picks up where it left off
+	ctxSignal, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	ctxTimeout, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	var run collector.RunFunc
+	timeout := time.Duration(cfg.UserTimeoutSeconds) * time.Second
 
-	date := time.Now().Format("2006-01-02")
+	if cfg.Backfill() {
+		types, err := collector.ParseTypes(cfg.BackfillTypes)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid backfill types")
+		}
+		from, _ := time.Parse(client.DateLayout, cfg.BackfillFrom)
+		to := time.Now()
+		if cfg.BackfillTo != "" {
+			to, _ = time.Parse(client.DateLayout, cfg.BackfillTo)
+		}
 
-	var dataPointsCollected int
-	var hasErrors bool
+		log.WithField("from", from.Format(client.DateLayout)).
+			WithField("to", to.Format(client.DateLayout)).
+			WithField("types", types).
+			Info("Starting backfill")
 
-	// Fetch sleep data
-	sleepData, err := ouraClient.GetSleepData(ctxTimeout, date)
-	if err != nil {
-		log.WithError(err).Error("Failed to fetch sleep data")
-		m.CollectionErrors.WithLabelValues("sleep", "fetch_failed").Inc()
-		hasErrors = true
-	} else {
-		log.WithField("sleep_score", sleepData.Score).Info("Successfully fetched sleep data")
-		dataPointsCollected++
-		if err := ouraClient.SendToProcessor(ctxTimeout, cfg.ProcessorURL, sleepData); err != nil {
-			log.WithError(err).Error("Failed to send sleep data to processor")
-			m.CollectionErrors.WithLabelValues("sleep", "send_failed").Inc()
-			hasErrors = true
+		// Backfills can run for a long time, so they are not bounded by the
+		// per-user timeout
+		timeout = 0
+		run = func(ctx context.Context, user repository.OuraUser) (int, error) {
+			return newCollector(cfg, user, repo, tokenStore, refresher, signer, log, m).Backfill(ctx, user.UserID, types, from, to, cfg.BackfillChunkDays)
 		}
-	}
-
-	// Fetch activity data
-	activityData, err := ouraClient.GetActivityData(ctxTimeout, date)
-	if err != nil {
-		log.WithError(err).Error("Failed to fetch activity data")
-		m.CollectionErrors.WithLabelValues("activity", "fetch_failed").Inc()
-		hasErrors = true
 	} else {
-		log.WithField("activity_score", activityData.Score).Info("Successfully fetched activity data")
-		dataPointsCollected++
-		if err := ouraClient.SendToProcessor(ctxTimeout, cfg.ProcessorURL, activityData); err != nil {
-			log.WithError(err).Error("Failed to send activity data to processor")
-			m.CollectionErrors.WithLabelValues("activity", "send_failed").Inc()
-			hasErrors = true
+		window := collector.SyncWindow{
+			OverlapDays: cfg.SyncOverlapDays,
+			InitialDays: cfg.SyncInitialDays,
+		}
+		run = func(ctx context.Context, user repository.OuraUser) (int, error) {
+			return newCollector(cfg, user, repo, tokenStore, refresher, signer, log, m).Sync(ctx, user.UserID, collector.AllTypes, time.Now(), window)
 		}
 	}
 
-	// Fetch readiness data
-	readinessData, err := ouraClient.GetReadinessData(ctxTimeout, date)
-	if err != nil {
-		log.WithError(err).Error("Failed to fetch readiness data")
-		m.CollectionErrors.WithLabelValues("readiness", "fetch_failed").Inc()
-		hasErrors = true
-	} else {
-		log.WithField("readiness_score", readinessData.Score).Info("Successfully fetched readiness data")
-		dataPointsCollected++
-		if err := ouraClient.SendToProcessor(ctxTimeout, cfg.ProcessorURL, readinessData); err != nil {
-			log.WithError(err).Error("Failed to send readiness data to processor")
-			m.CollectionErrors.WithLabelValues("readiness", "send_failed").Inc()
-			hasErrors = true
+	var dataPointsCollected, failedUsers int
+	for _, result := range collector.FanOut(ctxSignal, users, cfg.Concurrency, timeout, run) {
+		dataPointsCollected += result.DataPoints
+		userLog := log.WithField("user_id", result.UserID).WithField("data_points", result.DataPoints)
+		if result.Err != nil {
+			failedUsers++
+			userLog.WithError(result.Err).Error("Collection failed for user")
+			continue
 		}
+		userLog.Info("Collection succeeded for user")
 	}
+	hasErrors := failedUsers > 0
 
 	// Record metrics
 	duration := time.Since(startTime).Seconds()
 	m.CollectionDuration.Observe(duration)
-	m.DataPointsCollected.Add(float64(dataPointsCollected))
 
 	if !hasErrors {
 		m.LastSuccessfulRunTime.Set(float64(time.Now().Unix()))
 		log.WithField("duration_seconds", duration).WithField("data_points", dataPointsCollected).Info("oura-collector completed successfully")
 	} else {
-		log.WithField("duration_seconds", duration).WithField("data_points", dataPointsCollected).Warn("oura-collector completed with errors")
+		// Exit non-zero so the CronJob records the run as failed
+		log.WithField("duration_seconds", duration).
+			WithField("data_points", dataPointsCollected).
+			WithField("failed_users", failedUsers).
+			Fatal("oura-collector completed with errors")
+	}
+}
+
+// newCollector builds a collector that fetches with the user's Oura token,
+// refreshing it ahead of expiry.
+func newCollector(cfg *config.Config, user repository.OuraUser, repo *repository.Repository, store tokens.Store, refresher tokens.Refresher, signer *signing.Signer, log *logrus.Entry, m *metrics.Metrics) *collector.Collector {
+	userLog := log.WithField("user_id", user.UserID)
+	skew := time.Duration(cfg.TokenRefreshSkewSeconds) * time.Second
+	source := tokens.NewSource(user.UserID, "oura", store, refresher, skew, userLog)
+	ouraClient := client.NewWithTokenSource(source, userLog).WithSigner(signer)
+
+	send := func(ctx context.Context, userID, dataType string, docs []collector.Document) (int, error) {
+		var rejected int
+		envs := make([]*ingest.Envelope, 0, len(docs))
+		for _, doc := range docs {
+			env, err := ingest.New(dataType, userID, ingest.SourceOura, doc.Data)
+			if err != nil {
+				userLog.WithError(err).WithField("data_type", dataType).WithField("id", doc.ID).Warn("Skipping invalid document")
+				rejected++
+				continue
+			}
+			envs = append(envs, env.WithRaw(doc.Raw, doc.FetchedAt))
+		}
+		if len(envs) == 0 {
+			return rejected, nil
+		}
+
+		result, err := ouraClient.SendBatchToProcessor(ctx, cfg.ProcessorURL, envs)
+		if err != nil {
+			return 0, err
+		}
+		for _, res := range result.Results {
+			if res.Reason != "" {
+				userLog.WithField("data_type", dataType).WithField("reason", res.Reason).Warn("Processor rejected document")
+			}
+		}
+		return rejected + result.Invalid, nil
 	}
+	return collector.New(collector.Fetchers(ouraClient), send, repo, userLog, m)
 }
 
 func parseInt(s string) int {
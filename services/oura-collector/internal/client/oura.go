@@ -4,158 +4,321 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/asian-code/myapp-kubernetes/services/pkg/signing"
 	log "github.com/sirupsen/logrus"
 )
 
 const OuraBaseURL = "https://api.ouraring.com/v2/usercollection"
 
-type SleepData struct {
-	ID       string `json:"id"`
-	Day      string `json:"day"`
-	Score    int    `json:"score"`
-	Duration int    `json:"duration"`
-}
+// DateLayout is the date format used by the Oura API for start_date/end_date.
+const DateLayout = "2006-01-02"
+
+// maxPages guards against a misbehaving API handing out next_tokens forever.
+const maxPages = 1000
+
+// ErrMissingScope is returned when the API refuses a request because the
+// access token was not granted the scope for the data, as with tokens issued
+// before the scope was requested.
+var ErrMissingScope = errors.New("access token lacks the required scope")
 
-type ActivityData struct {
-	ID                string `json:"id"`
-	Day               string `json:"day"`
-	Score             int    `json:"score"`
-	ActiveCalories    int    `json:"active_calories"`
-	Steps             int    `json:"steps"`
-	MediumActivityMin int    `json:"medium_activity_minutes"`
-	HighActivityMin   int    `json:"high_activity_minutes"`
+// Document is a usercollection document's identifiers along with the
+// document exactly as the API returned it, including every field the
+// identifiers leave out.
+type Document struct {
+	ID  string          `json:"id"`
+	Day string          `json:"day"`
+	Raw json.RawMessage `json:"-"`
 }
 
-type ReadinessData struct {
-	ID    string `json:"id"`
-	Day   string `json:"day"`
-	Score int    `json:"score"`
+// TokenSource supplies access tokens for Oura API requests.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+	// Invalidate reports a token the API rejected so it gets refreshed.
+	Invalidate(accessToken string)
 }
 
+// staticToken is a TokenSource for a fixed API key.
+type staticToken string
+
+func (t staticToken) Token(ctx context.Context) (string, error) { return string(t), nil }
+func (t staticToken) Invalidate(string)                         {}
+
 type OuraClient struct {
-	apiKey string
-	client *http.Client
-	logger *log.Entry
+	tokens  TokenSource
+	baseURL string
+	client  *http.Client
+	signer  *signing.Signer
+	logger  *log.Entry
 }
 
+// New creates a client that authenticates with a fixed API key.
 func New(apiKey string, logger *log.Entry) *OuraClient {
+	return NewWithTokenSource(staticToken(apiKey), logger)
+}
+
+// NewWithTokenSource creates a client that takes access tokens from tokens,
+// retrying once with a fresh token if the API rejects one.
+func NewWithTokenSource(tokens TokenSource, logger *log.Entry) *OuraClient {
 	return &OuraClient{
-		apiKey: apiKey,
-		client: &http.Client{Timeout: 10 * time.Second},
-		logger: logger,
+		tokens:  tokens,
+		baseURL: OuraBaseURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		logger:  logger,
 	}
 }
 
-func (c *OuraClient) GetSleepData(ctx context.Context, date string) (*SleepData, error) {
-	url := fmt.Sprintf("%s/daily_sleep?date=%s", OuraBaseURL, date)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// GetDocuments returns the documents of a usercollection endpoint, such as
+// "daily_sleep", between start and end, following pagination until the last
+// page.
+func (c *OuraClient) GetDocuments(ctx context.Context, endpoint string, start, end time.Time) ([]Document, error) {
+	var results []Document
+	err := c.WalkDocuments(ctx, endpoint, start, end, "", func(docs []Document, next string) error {
+		results = append(results, docs...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	c.logger.WithField("endpoint", endpoint).
+		WithField("start_date", start.Format(DateLayout)).
+		WithField("end_date", end.Format(DateLayout)).
+		WithField("documents", len(results)).
+		Debug("Fetched Oura documents")
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("oura API returned %d", resp.StatusCode)
-	}
+	return results, nil
+}
 
-	var data SleepData
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, err
+// WalkDocuments calls fn with each page of documents of a usercollection
+// endpoint between start and end, starting at the page cursor returned
+// earlier for the same query, or at the first page if cursor is empty. fn
+// also receives the cursor of the following page, empty after the last one.
+// The walk stops at the first error fn returns.
+func (c *OuraClient) WalkDocuments(ctx context.Context, endpoint string, start, end time.Time, cursor string, fn func(docs []Document, next string) error) error {
+	if end.Before(start) {
+		return fmt.Errorf("end date %s is before start date %s", end.Format(DateLayout), start.Format(DateLayout))
 	}
 
-	return &data, nil
+	params := url.Values{}
+	params.Set("start_date", start.Format(DateLayout))
+	params.Set("end_date", end.Format(DateLayout))
+
+	return c.pages(ctx, endpoint, params, cursor, func(items []json.RawMessage, next string) error {
+		docs := make([]Document, 0, len(items))
+		for _, raw := range items {
+			var doc Document
+			if err := json.Unmarshal(raw, &doc); err != nil {
+				return fmt.Errorf("decoding document: %w", err)
+			}
+			doc.Raw = raw
+			docs = append(docs, doc)
+		}
+		return fn(docs, next)
+	})
 }
 
-func (c *OuraClient) GetActivityData(ctx context.Context, date string) (*ActivityData, error) {
-	url := fmt.Sprintf("%s/daily_activity?date=%s", OuraBaseURL, date)
+// Sample is one reading of a time series endpoint, such as "heartrate",
+// with its timestamp and the reading exactly as the API returned it.
+type Sample struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Raw       json.RawMessage `json:"-"`
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// GetSamples returns the samples of a time series endpoint taken on the days
+// from start through end (UTC), following pagination until the last page.
+// Unlike the daily endpoints these are queried by datetime.
+func (c *OuraClient) GetSamples(ctx context.Context, endpoint string, start, end time.Time) ([]Sample, error) {
+	var results []Sample
+	err := c.WalkSamples(ctx, endpoint, start, end, "", func(samples []Sample, next string) error {
+		results = append(results, samples...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	c.logger.WithField("endpoint", endpoint).
+		WithField("start_date", start.Format(DateLayout)).
+		WithField("end_date", end.Format(DateLayout)).
+		WithField("samples", len(results)).
+		Debug("Fetched Oura samples")
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("oura API returned %d", resp.StatusCode)
-	}
+	return results, nil
+}
 
-	var data ActivityData
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, err
+// WalkSamples is WalkDocuments for time series endpoints: it calls fn with
+// each page of samples taken on the days from start through end (UTC),
+// starting at cursor.
+func (c *OuraClient) WalkSamples(ctx context.Context, endpoint string, start, end time.Time, cursor string, fn func(samples []Sample, next string) error) error {
+	if end.Before(start) {
+		return fmt.Errorf("end date %s is before start date %s", end.Format(DateLayout), start.Format(DateLayout))
 	}
 
-	return &data, nil
+	params := url.Values{}
+	params.Set("start_datetime", start.UTC().Format(time.RFC3339))
+	params.Set("end_datetime", end.UTC().AddDate(0, 0, 1).Format(time.RFC3339))
+
+	return c.pages(ctx, endpoint, params, cursor, func(items []json.RawMessage, next string) error {
+		samples := make([]Sample, 0, len(items))
+		for _, raw := range items {
+			var s Sample
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return fmt.Errorf("decoding sample: %w", err)
+			}
+			s.Raw = raw
+			samples = append(samples, s)
+		}
+		return fn(samples, next)
+	})
 }
 
-func (c *OuraClient) GetReadinessData(ctx context.Context, date string) (*ReadinessData, error) {
-	url := fmt.Sprintf("%s/daily_readiness?date=%s", OuraBaseURL, date)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
+// pages calls fn with the items of every page of a usercollection endpoint's
+// response and the next_token of the page after it, starting at cursor (or
+// the first page if it is empty) and following pagination until the last
+// page.
+func (c *OuraClient) pages(ctx context.Context, endpoint string, params url.Values, cursor string, fn func(items []json.RawMessage, next string) error) error {
+	if cursor != "" {
+		params.Set("next_token", cursor)
 	}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
+	for pages := 1; ; pages++ {
+		if pages > maxPages {
+			return fmt.Errorf("%s: exceeded %d pages", endpoint, maxPages)
+		}
+
+		var p page
+		if err := c.get(ctx, endpoint, params, &p); err != nil {
+			return fmt.Errorf("%s: %w", endpoint, err)
+		}
+
+		var next string
+		if p.NextToken != nil {
+			next = *p.NextToken
+		}
+		if next != "" && next == params.Get("next_token") {
+			return fmt.Errorf("%s: API repeated next_token %q", endpoint, next)
+		}
+		if err := fn(p.Data, next); err != nil {
+			return fmt.Errorf("%s: %w", endpoint, err)
+		}
+
+		if next == "" {
+			return nil
+		}
+		params.Set("next_token", next)
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("oura API returned %d", resp.StatusCode)
-	}
+// page is the envelope returned by the Oura v2 usercollection endpoints.
+type page struct {
+	Data      []json.RawMessage `json:"data"`
+	NextToken *string           `json:"next_token"`
+}
 
-	var data ReadinessData
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, err
+// get performs an authenticated GET against a usercollection endpoint and
+// decodes the JSON response into out. If the API rejects the access token the
+// token is invalidated and the request retried once with a fresh one.
+func (c *OuraClient) get(ctx context.Context, endpoint string, params url.Values, out interface{}) error {
+	reqURL := fmt.Sprintf("%s/%s?%s", c.baseURL, endpoint, params.Encode())
+
+	for attempt := 1; ; attempt++ {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 1 {
+			resp.Body.Close()
+			c.logger.WithField("endpoint", endpoint).Warn("Oura rejected access token, refreshing and retrying")
+			c.tokens.Invalidate(token)
+			continue
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusForbidden {
+			return fmt.Errorf("oura API returned %d: %w", resp.StatusCode, ErrMissingScope)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("oura API returned %d", resp.StatusCode)
+		}
+
+		return json.NewDecoder(resp.Body).Decode(out)
 	}
+}
+
+// WithSigner makes SendBatchToProcessor sign its requests with signer.
+func (c *OuraClient) WithSigner(signer *signing.Signer) *OuraClient {
+	c.signer = signer
+	return c
+}
 
-	return &data, nil
+// BatchResult is the processor's response to a batch ingest: how many
+// records it queued and, for each rejected one, why.
+type BatchResult struct {
+	Queued  int `json:"queued"`
+	Invalid int `json:"invalid"`
+	Results []struct {
+		Index  int    `json:"index"`
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	} `json:"results"`
 }
 
-func (c *OuraClient) SendToProcessor(ctx context.Context, processorURL string, data interface{}) error {
-	jsonData, err := json.Marshal(data)
+// SendBatchToProcessor posts records to the processor's batch ingest
+// endpoint as one JSON array, signed if the client has a signer. An error
+// means the processor acknowledged none of them.
+func (c *OuraClient) SendBatchToProcessor(ctx context.Context, processorURL string, records interface{}) (*BatchResult, error) {
+	jsonData, err := json.Marshal(records)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", processorURL+"/api/v1/ingest", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", processorURL+"/api/v1/ingest/batch", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if c.signer != nil {
+		if err := c.signer.Sign(req, jsonData); err != nil {
+			return nil, err
+		}
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("processor returned %d", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
+	default:
+		return nil, fmt.Errorf("processor returned %d", resp.StatusCode)
 	}
 
-	return nil
+	var result BatchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding processor response: %w", err)
+	}
+	return &result, nil
 }
@@ -2,8 +2,16 @@ package client
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/asian-code/myapp-kubernetes/services/pkg/signing"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -15,21 +23,305 @@ func TestOuraClientNew(t *testing.T) {
 		t.Error("Expected client to be created")
 	}
 
-	if client.apiKey != "test-api-key" {
-		t.Errorf("Expected apiKey to be 'test-api-key', got %s", client.apiKey)
+	if token, _ := client.tokens.Token(context.Background()); token != "test-api-key" {
+		t.Errorf("Expected apiKey to be 'test-api-key', got %s", token)
 	}
 }
 
-func TestOuraClientGetSleepData(t *testing.T) {
-	logger := log.NewEntry(log.New())
-	client := New("invalid-key", logger)
+func newTestClient(t *testing.T, handler http.HandlerFunc) *OuraClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := New("test-api-key", log.NewEntry(log.New()))
+	client.baseURL = server.URL
+	return client
+}
+
+func TestOuraClientGetDocumentsFollowsNextToken(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+
+		if r.URL.Path != "/daily_sleep" {
+			t.Errorf("Expected path /daily_sleep, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-api-key" {
+			t.Errorf("Expected bearer token, got %q", got)
+		}
+		if r.URL.Query().Get("start_date") != "2024-01-01" || r.URL.Query().Get("end_date") != "2024-01-03" {
+			t.Errorf("Unexpected date range: %s", r.URL.RawQuery)
+		}
+
+		switch r.URL.Query().Get("next_token") {
+		case "":
+			fmt.Fprint(w, `{"data":[{"id":"a","day":"2024-01-01","score":80},{"id":"b","day":"2024-01-02","score":75}],"next_token":"page2"}`)
+		case "page2":
+			fmt.Fprint(w, `{"data":[{"id":"c","day":"2024-01-03","score":90,"contributors":{"rem_sleep":70}}],"next_token":null}`)
+		default:
+			t.Errorf("Unexpected next_token %q", r.URL.Query().Get("next_token"))
+		}
+	})
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	data, err := client.GetDocuments(context.Background(), "daily_sleep", start, end)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(requests))
+	}
+	if len(data) != 3 {
+		t.Fatalf("Expected 3 documents, got %d", len(data))
+	}
+	if data[2].ID != "c" || data[2].Day != "2024-01-03" {
+		t.Errorf("Unexpected last document: %+v", data[2])
+	}
+	if want := `{"id":"c","day":"2024-01-03","score":90,"contributors":{"rem_sleep":70}}`; string(data[2].Raw) != want {
+		t.Errorf("Expected raw document %s, got %s", want, data[2].Raw)
+	}
+}
+
+func TestOuraClientWalkDocumentsResumesAtCursor(t *testing.T) {
+	var tokens []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.URL.Query().Get("next_token"))
 
-	ctx := context.Background()
+		switch r.URL.Query().Get("next_token") {
+		case "page2":
+			fmt.Fprint(w, `{"data":[{"id":"c","day":"2024-01-03","score":90}],"next_token":"page3"}`)
+		case "page3":
+			fmt.Fprint(w, `{"data":[{"id":"d","day":"2024-01-03","score":91}],"next_token":null}`)
+		default:
+			t.Errorf("Unexpected next_token %q", r.URL.Query().Get("next_token"))
+		}
+	})
 
-	// This will fail because we don't have a valid API key
-	// Just testing the error handling
-	_, err := client.GetSleepData(ctx, "2024-01-01")
-	if err == nil {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	var ids, next []string
+	err := client.WalkDocuments(context.Background(), "daily_sleep", start, end, "page2", func(docs []Document, cursor string) error {
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		next = append(next, cursor)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if strings.Join(tokens, ",") != "page2,page3" {
+		t.Errorf("Expected the walk to start at page2, requested %v", tokens)
+	}
+	if strings.Join(ids, ",") != "c,d" {
+		t.Errorf("Expected documents c and d, got %v", ids)
+	}
+	if strings.Join(next, ",") != "page3," {
+		t.Errorf("Expected each page to report the following cursor, got %q", next)
+	}
+}
+
+func TestOuraClientGetSamplesQueriesByDatetime(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/heartrate" {
+			t.Errorf("Expected path /heartrate, got %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("start_datetime") != "2024-01-01T00:00:00Z" || q.Get("end_datetime") != "2024-01-03T00:00:00Z" {
+			t.Errorf("Unexpected datetime range: %s", r.URL.RawQuery)
+		}
+
+		switch q.Get("next_token") {
+		case "":
+			fmt.Fprint(w, `{"data":[{"bpm":58,"source":"sleep","timestamp":"2024-01-01T03:00:00+00:00"}],"next_token":"page2"}`)
+		case "page2":
+			fmt.Fprint(w, `{"data":[{"bpm":71,"source":"awake","timestamp":"2024-01-02T09:05:00+00:00"}],"next_token":null}`)
+		}
+	})
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	samples, err := client.GetSamples(context.Background(), "heartrate", start, end)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(samples))
+	}
+	if want := time.Date(2024, 1, 2, 9, 5, 0, 0, time.UTC); !samples[1].Timestamp.Equal(want) {
+		t.Errorf("Expected timestamp %v, got %v", want, samples[1].Timestamp)
+	}
+	if want := `{"bpm":71,"source":"awake","timestamp":"2024-01-02T09:05:00+00:00"}`; string(samples[1].Raw) != want {
+		t.Errorf("Expected raw sample %s, got %s", want, samples[1].Raw)
+	}
+}
+
+func TestOuraClientGetDocumentsEmpty(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[],"next_token":null}`)
+	})
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, err := client.GetDocuments(context.Background(), "daily_activity", day, day)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(data) != 0 {
+		t.Errorf("Expected no documents, got %d", len(data))
+	}
+}
+
+func TestOuraClientGetDocumentsError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := client.GetDocuments(context.Background(), "daily_readiness", day, day); err == nil {
 		t.Error("Expected error with invalid API key")
 	}
 }
+
+func TestOuraClientRejectsRepeatedNextToken(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[],"next_token":"same"}`)
+	})
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := client.GetDocuments(context.Background(), "daily_sleep", day, day); err == nil {
+		t.Error("Expected error when the API repeats next_token")
+	}
+}
+
+func TestOuraClientRejectsInvertedRange(t *testing.T) {
+	client := New("test-api-key", log.NewEntry(log.New()))
+
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := client.GetDocuments(context.Background(), "daily_sleep", start, end); err == nil {
+		t.Error("Expected error when end is before start")
+	}
+}
+
+// rotatingTokens hands out a new token each time the previous one is
+// invalidated.
+type rotatingTokens struct {
+	current     string
+	invalidated []string
+}
+
+func (r *rotatingTokens) Token(ctx context.Context) (string, error) { return r.current, nil }
+
+func (r *rotatingTokens) Invalidate(token string) {
+	r.invalidated = append(r.invalidated, token)
+	r.current = "fresh-token"
+}
+
+func TestOuraClientRetriesWithFreshTokenOnUnauthorized(t *testing.T) {
+	tokens := &rotatingTokens{current: "expired-token"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"a","day":"2024-01-01","score":80}],"next_token":null}`)
+	}))
+	defer server.Close()
+
+	client := NewWithTokenSource(tokens, log.NewEntry(log.New()))
+	client.baseURL = server.URL
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, err := client.GetDocuments(context.Background(), "daily_sleep", day, day)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(data) != 1 {
+		t.Errorf("Expected 1 document, got %d", len(data))
+	}
+	if len(tokens.invalidated) != 1 || tokens.invalidated[0] != "expired-token" {
+		t.Errorf("Expected expired token to be invalidated once, got %v", tokens.invalidated)
+	}
+}
+
+func TestOuraClientGivesUpAfterSecondUnauthorized(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewWithTokenSource(&rotatingTokens{current: "expired-token"}, log.NewEntry(log.New()))
+	client.baseURL = server.URL
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := client.GetDocuments(context.Background(), "daily_sleep", day, day); err == nil {
+		t.Error("Expected error when the refreshed token is also rejected")
+	}
+	if requests != 2 {
+		t.Errorf("Expected exactly one retry, got %d requests", requests)
+	}
+}
+
+func TestOuraClientReportsMissingScopeOnForbidden(t *testing.T) {
+	var requests int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := client.GetSamples(context.Background(), "heartrate", day, day)
+	if !errors.Is(err, ErrMissingScope) {
+		t.Errorf("Expected ErrMissingScope, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected no retry, got %d requests", requests)
+	}
+}
+
+func TestSendBatchToProcessorSignsRequests(t *testing.T) {
+	key := signing.Key{ID: "k1", Secret: strings.Repeat("s", signing.MinSecretLength)}
+	verifier := signing.NewVerifier([]signing.Key{key}, signing.NewMemoryNonceCache())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/ingest/batch" {
+			t.Errorf("Expected batch endpoint, got %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := verifier.Verify(r, body); err != nil {
+			t.Errorf("Expected a valid signature, got %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"queued":1,"invalid":1,"results":[{"index":0,"status":"queued","id":7},{"index":1,"status":"invalid","reason":"bad"}]}`))
+	}))
+	defer server.Close()
+
+	client := New("test-api-key", log.NewEntry(log.New())).WithSigner(signing.NewSigner(key))
+	result, err := client.SendBatchToProcessor(context.Background(), server.URL, []map[string]string{{"type": "sleep"}, {"type": "steps"}})
+	if err != nil {
+		t.Fatalf("SendBatchToProcessor failed: %v", err)
+	}
+	if result.Queued != 1 || result.Invalid != 1 || result.Results[1].Reason != "bad" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestSendBatchToProcessorFailsOnServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := New("test-api-key", log.NewEntry(log.New()))
+	if _, err := client.SendBatchToProcessor(context.Background(), server.URL, []string{}); err == nil {
+		t.Error("Expected error when the processor fails")
+	}
+}
@@ -0,0 +1,452 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/client"
+	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// Data types the collector knows how to fetch.
+const (
+	TypeSleep     = ingest.TypeSleep
+	TypeActivity  = ingest.TypeActivity
+	TypeReadiness = ingest.TypeReadiness
+)
+
+// AllTypes lists every registered data type that is fetched from Oura.
+var AllTypes = ouraTypes()
+
+func ouraTypes() []string {
+	var types []string
+	for _, t := range ingest.Registered() {
+		if t.OuraEndpoint != "" {
+			types = append(types, t.Name)
+		}
+	}
+	return types
+}
+
+// Provider identifies Oura in sync_state.
+const Provider = "oura"
+
+// Document is a fetched upstream document's ingest payload along with the
+// identifiers the collector needs for checkpointing. Raw is the document as
+// the provider returned it, archived by the processor for reprocessing.
+type Document struct {
+	ID        string
+	Day       string
+	Data      interface{}
+	Raw       json.RawMessage
+	FetchedAt time.Time
+}
+
+// FetchFunc walks the documents of one data type between start and end page
+// by page, starting at a page cursor from an earlier walk of the same window
+// or at the first page if cursor is empty. It calls page with each page's
+// documents and the cursor of the following page, empty after the last one,
+// and stops at the first error page returns.
+type FetchFunc func(ctx context.Context, start, end time.Time, cursor string, page PageFunc) error
+
+// PageFunc receives one page of documents and the cursor of the next page.
+type PageFunc func(docs []Document, next string) error
+
+// SendFunc delivers documents to the data-processor in one request. It
+// returns how many of them were rejected as invalid; an error means none were
+// acknowledged.
+type SendFunc func(ctx context.Context, userID, dataType string, docs []Document) (int, error)
+
+// sendBatchSize is the number of documents sent to the processor per request.
+const sendBatchSize = 500
+
+// Store persists backfill progress and incremental sync checkpoints.
+type Store interface {
+	GetBackfillProgress(ctx context.Context, userID, dataType string, rangeStart time.Time) (time.Time, bool, error)
+	SaveBackfillProgress(ctx context.Context, userID, dataType string, rangeStart, rangeEnd, completedThrough time.Time) error
+	GetSyncState(ctx context.Context, userID, provider, dataType string) (*repository.SyncState, error)
+	SaveSyncState(ctx context.Context, state *repository.SyncState) error
+}
+
+// Fetchers returns a FetchFunc for every registered data type fetched from
+// Oura, converting each document to its ingest payload with ingest.FromOura.
+func Fetchers(c *client.OuraClient) map[string]FetchFunc {
+	fetchers := make(map[string]FetchFunc)
+	for _, t := range ingest.Registered() {
+		switch {
+		case t.OuraEndpoint == "":
+		case t.Series:
+			fetchers[t.Name] = seriesFetcher(c, t)
+		default:
+			fetchers[t.Name] = fetcher(c, t)
+		}
+	}
+	return fetchers
+}
+
+func fetcher(c *client.OuraClient, t *ingest.MetricType) FetchFunc {
+	return func(ctx context.Context, start, end time.Time, cursor string, page PageFunc) error {
+		return c.WalkDocuments(ctx, t.OuraEndpoint, start, end, cursor, func(docs []client.Document, next string) error {
+			fetchedAt := time.Now().UTC()
+			out := make([]Document, len(docs))
+			for i, d := range docs {
+				payload, err := ingest.FromOura(t.Name, d.Raw)
+				if err != nil {
+					return fmt.Errorf("document %s: %w", d.ID, err)
+				}
+				// The payload knows its identifiers even when Oura's document
+				// lacks an id or day field, as with cardiovascular age and tags
+				id, day := payload.Document()
+				out[i] = Document{ID: id, Day: day, Data: payload, Raw: d.Raw, FetchedAt: fetchedAt}
+			}
+			return page(out, next)
+		})
+	}
+}
+
+// seriesFetcher fetches a time series and groups each page's samples into one
+// document per UTC day, {"day": ..., "samples": [...]}, identified by the day.
+// A day split across pages is sent as several documents; the processor
+// upserts samples individually, so together they store the whole day. The
+// samples are not archived, as the grouped document is not one Oura returned.
+func seriesFetcher(c *client.OuraClient, t *ingest.MetricType) FetchFunc {
+	return func(ctx context.Context, start, end time.Time, cursor string, page PageFunc) error {
+		return c.WalkSamples(ctx, t.OuraEndpoint, start, end, cursor, func(samples []client.Sample, next string) error {
+			docs, err := groupSamples(t, samples)
+			if err != nil {
+				return err
+			}
+			return page(docs, next)
+		})
+	}
+}
+
+// groupSamples groups samples into one document per UTC day, ordered by day.
+func groupSamples(t *ingest.MetricType, samples []client.Sample) ([]Document, error) {
+	byDay := make(map[string][]json.RawMessage)
+	var days []string
+	for _, s := range samples {
+		day := s.Timestamp.UTC().Format(client.DateLayout)
+		if _, ok := byDay[day]; !ok {
+			days = append(days, day)
+		}
+		byDay[day] = append(byDay[day], s.Raw)
+	}
+	sort.Strings(days)
+
+	fetchedAt := time.Now().UTC()
+	out := make([]Document, len(days))
+	for i, day := range days {
+		doc, err := json.Marshal(map[string]interface{}{"day": day, "samples": byDay[day]})
+		if err != nil {
+			return nil, err
+		}
+		out[i] = Document{ID: day, Day: day, FetchedAt: fetchedAt}
+		if out[i].Data, err = ingest.FromOura(t.Name, doc); err != nil {
+			return nil, fmt.Errorf("%s samples: %w", day, err)
+		}
+	}
+	return out, nil
+}
+
+// ParseTypes parses a comma separated list of data types. An empty list
+// selects every supported type.
+func ParseTypes(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return AllTypes, nil
+	}
+
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if !isSupported(t) {
+			return nil, fmt.Errorf("unsupported data type %q (supported: %s)", t, strings.Join(AllTypes, ", "))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+func isSupported(dataType string) bool {
+	for _, t := range AllTypes {
+		if t == dataType {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncWindow controls which days an incremental sync re-fetches.
+type SyncWindow struct {
+	// OverlapDays re-fetches this many days before the checkpoint because
+	// Oura revises scores after the fact.
+	OverlapDays int
+	// InitialDays is fetched for data types that have never been synced.
+	InitialDays int
+}
+
+type Collector struct {
+	fetchers map[string]FetchFunc
+	send     SendFunc
+	store    Store
+	logger   *log.Entry
+	metrics  *metrics.Metrics
+}
+
+func New(fetchers map[string]FetchFunc, send SendFunc, store Store, logger *log.Entry, m *metrics.Metrics) *Collector {
+	return &Collector{
+		fetchers: fetchers,
+		send:     send,
+		store:    store,
+		logger:   logger,
+		metrics:  m,
+	}
+}
+
+// Sync fetches each data type from its last checkpoint (minus the overlap
+// window) through today and forwards every document to the processor. A data
+// type's checkpoint only advances once the processor has acknowledged every
+// document in the window; until then the cursor of the next page is saved
+// after each acknowledged page, and the next sync resumes the interrupted walk
+// from it. Failures of one type do not stop the others; the returned error
+// joins all of them. Types the user's token has no scope for are skipped with
+// a warning.
+func (c *Collector) Sync(ctx context.Context, userID string, types []string, now time.Time, window SyncWindow) (int, error) {
+	var collected int
+	var errs []error
+
+	for _, dataType := range types {
+		n, err := c.syncType(ctx, userID, dataType, truncateDay(now), window)
+		collected += n
+		if err != nil && !c.skipped(userID, dataType, err) {
+			errs = append(errs, err)
+		}
+	}
+
+	return collected, errors.Join(errs...)
+}
+
+func (c *Collector) syncType(ctx context.Context, userID, dataType string, today time.Time, window SyncWindow) (int, error) {
+	logger := c.logger.WithField("user_id", userID).WithField("data_type", dataType)
+
+	state, err := c.store.GetSyncState(ctx, userID, Provider, dataType)
+	if err != nil {
+		return 0, fmt.Errorf("%s: load sync state: %w", dataType, err)
+	}
+
+	start, end := today.AddDate(0, 0, -window.InitialDays), today
+	synced := start.AddDate(0, 0, -1)
+	if state != nil {
+		synced = truncateDay(state.LastSyncedDay)
+		start = synced.AddDate(0, 0, -window.OverlapDays)
+	}
+	if start.After(today) {
+		start = today
+	}
+
+	var cursor string
+	if state != nil && state.Cursor != "" {
+		start, end, cursor = truncateDay(state.CursorStart), truncateDay(state.CursorEnd), state.Cursor
+		logger.WithField("start", start.Format(client.DateLayout)).
+			WithField("end", end.Format(client.DateLayout)).
+			Info("Resuming interrupted sync")
+	}
+
+	checkpoint := func(next string) error {
+		return c.store.SaveSyncState(ctx, &repository.SyncState{
+			UserID:        userID,
+			Provider:      Provider,
+			DataType:      dataType,
+			LastSyncedDay: synced,
+			Cursor:        next,
+			CursorStart:   start,
+			CursorEnd:     end,
+		})
+	}
+
+	n, err := c.collectType(ctx, userID, dataType, start, end, cursor, checkpoint)
+	if err != nil && cursor != "" && n == 0 && !errors.Is(err, client.ErrMissingScope) {
+		// Oura may no longer accept a cursor saved by an earlier run; walk
+		// the window again from its first page
+		logger.WithError(err).Warn("Failed to resume sync from the saved cursor, restarting the window")
+		n, err = c.collectType(ctx, userID, dataType, start, end, "", checkpoint)
+	}
+	if err != nil {
+		return n, err
+	}
+
+	synced = end
+	if err := checkpoint(""); err != nil {
+		return n, fmt.Errorf("%s: save sync state: %w", dataType, err)
+	}
+
+	logger.WithField("start", start.Format(client.DateLayout)).
+		WithField("end", end.Format(client.DateLayout)).
+		WithField("documents", n).
+		Info("Synced data type")
+
+	return n, nil
+}
+
+// Backfill walks [from, to] in chunks of chunkDays for each data type,
+// recording progress after every chunk the processor fully accepted. Running
+// a backfill with the same start date again resumes after the last completed
+// chunk, even if the end date has moved.
+func (c *Collector) Backfill(ctx context.Context, userID string, types []string, from, to time.Time, chunkDays int) (int, error) {
+	from, to = truncateDay(from), truncateDay(to)
+	if to.Before(from) {
+		return 0, fmt.Errorf("backfill end %s is before start %s", to.Format(client.DateLayout), from.Format(client.DateLayout))
+	}
+	if chunkDays < 1 {
+		return 0, fmt.Errorf("chunk size must be at least one day, got %d", chunkDays)
+	}
+
+	var collected int
+	var errs []error
+
+	for _, dataType := range types {
+		n, err := c.backfillType(ctx, userID, dataType, from, to, chunkDays)
+		collected += n
+		if err != nil && !c.skipped(userID, dataType, err) {
+			errs = append(errs, err)
+		}
+	}
+
+	return collected, errors.Join(errs...)
+}
+
+func (c *Collector) backfillType(ctx context.Context, userID, dataType string, from, to time.Time, chunkDays int) (int, error) {
+	logger := c.logger.WithField("user_id", userID).WithField("data_type", dataType)
+
+	chunkStart := from
+	completedThrough, ok, err := c.store.GetBackfillProgress(ctx, userID, dataType, from)
+	if err != nil {
+		return 0, fmt.Errorf("%s: load backfill progress: %w", dataType, err)
+	}
+	if ok {
+		chunkStart = truncateDay(completedThrough).AddDate(0, 0, 1)
+		logger.WithField("completed_through", completedThrough.Format(client.DateLayout)).Info("Resuming backfill")
+	}
+
+	var collected int
+	for !chunkStart.After(to) {
+		chunkEnd := chunkStart.AddDate(0, 0, chunkDays-1)
+		if chunkEnd.After(to) {
+			chunkEnd = to
+		}
+
+		n, err := c.collectType(ctx, userID, dataType, chunkStart, chunkEnd, "", nil)
+		collected += n
+		if err != nil {
+			return collected, err
+		}
+
+		if err := c.store.SaveBackfillProgress(ctx, userID, dataType, from, to, chunkEnd); err != nil {
+			return collected, fmt.Errorf("%s: save backfill progress: %w", dataType, err)
+		}
+
+		logger.WithField("chunk_start", chunkStart.Format(client.DateLayout)).
+			WithField("chunk_end", chunkEnd.Format(client.DateLayout)).
+			WithField("documents", n).
+			Info("Backfilled chunk")
+
+		chunkStart = chunkEnd.AddDate(0, 0, 1)
+	}
+
+	return collected, nil
+}
+
+// collectType walks one data type's pages from cursor and sends each page's
+// documents in batches of sendBatchSize, returning an error if the fetch
+// failed or any document was not acknowledged. After every fully
+// acknowledged page that is not the last, checkpoint (if set) is called with
+// the cursor of the next page.
+func (c *Collector) collectType(ctx context.Context, userID, dataType string, start, end time.Time, cursor string, checkpoint func(next string) error) (int, error) {
+	fetch, ok := c.fetchers[dataType]
+	if !ok {
+		return 0, fmt.Errorf("unsupported data type %q", dataType)
+	}
+
+	var collected, failed int
+	var checkpointErr error
+	err := fetch(ctx, start, end, cursor, func(docs []Document, next string) error {
+		collected += len(docs)
+		c.metrics.DataPointsCollected.WithLabelValues(userID, dataType).Add(float64(len(docs)))
+
+		if failed = c.sendPage(ctx, userID, dataType, docs); failed > 0 {
+			return errPageNotAcknowledged
+		}
+		if next != "" && checkpoint != nil {
+			if checkpointErr = checkpoint(next); checkpointErr != nil {
+				return checkpointErr
+			}
+		}
+		return nil
+	})
+	switch {
+	case failed > 0:
+		return collected, fmt.Errorf("send %s data: %d of %d documents failed", dataType, failed, collected)
+	case checkpointErr != nil:
+		return collected, fmt.Errorf("%s: save sync state: %w", dataType, checkpointErr)
+	case errors.Is(err, client.ErrMissingScope):
+		c.metrics.CollectionErrors.WithLabelValues(userID, dataType, "missing_scope").Inc()
+		return collected, fmt.Errorf("fetch %s data: %w", dataType, err)
+	case err != nil:
+		c.metrics.CollectionErrors.WithLabelValues(userID, dataType, "fetch_failed").Inc()
+		return collected, fmt.Errorf("fetch %s data: %w", dataType, err)
+	}
+	return collected, nil
+}
+
+// errPageNotAcknowledged stops a page walk once the processor did not
+// acknowledge every document of a page, so no later cursor is saved.
+var errPageNotAcknowledged = errors.New("page not acknowledged")
+
+// sendPage sends one page of documents in batches of sendBatchSize and
+// returns how many of them the processor did not acknowledge.
+func (c *Collector) sendPage(ctx context.Context, userID, dataType string, docs []Document) int {
+	var failed int
+	for start := 0; start < len(docs); start += sendBatchSize {
+		end := start + sendBatchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		batch := docs[start:end]
+		rejected, err := c.send(ctx, userID, dataType, batch)
+		if err != nil {
+			c.logger.WithError(err).WithField("data_type", dataType).Error("Failed to send data to processor")
+			rejected = len(batch)
+		}
+		if rejected > 0 {
+			c.metrics.CollectionErrors.WithLabelValues(userID, dataType, "send_failed").Add(float64(rejected))
+			failed += rejected
+		}
+	}
+	return failed
+}
+
+// skipped reports whether a data type failed only because the user's token
+// was not granted its scope, logging a warning if so. Such types are skipped
+// rather than failing every run until the user authorizes again.
+func (c *Collector) skipped(userID, dataType string, err error) bool {
+	if !errors.Is(err, client.ErrMissingScope) {
+		return false
+	}
+	c.logger.WithError(err).
+		WithField("user_id", userID).
+		WithField("data_type", dataType).
+		Warn("Token lacks the scope for data type, skipping until the user authorizes again")
+	return true
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,434 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/client"
+	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/repository"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/ingest"
+	"github.com/asian-code/myapp-kubernetes/services/shared/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+type fetchCall struct {
+	start, end time.Time
+}
+
+type memoryStore struct {
+	completed map[string]time.Time
+	saves     int
+	sync      map[string]*repository.SyncState
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{completed: map[string]time.Time{}, sync: map[string]*repository.SyncState{}}
+}
+
+func (p *memoryStore) GetSyncState(ctx context.Context, userID, provider, dataType string) (*repository.SyncState, error) {
+	return p.sync[userID+"/"+provider+"/"+dataType], nil
+}
+
+func (p *memoryStore) SaveSyncState(ctx context.Context, state *repository.SyncState) error {
+	p.sync[state.UserID+"/"+state.Provider+"/"+state.DataType] = state
+	return nil
+}
+
+func (p *memoryStore) GetBackfillProgress(ctx context.Context, userID, dataType string, rangeStart time.Time) (time.Time, bool, error) {
+	day, ok := p.completed[userID+"/"+dataType+"/"+rangeStart.Format("2006-01-02")]
+	return day, ok, nil
+}
+
+func (p *memoryStore) SaveBackfillProgress(ctx context.Context, userID, dataType string, rangeStart, rangeEnd, completedThrough time.Time) error {
+	p.completed[userID+"/"+dataType+"/"+rangeStart.Format("2006-01-02")] = completedThrough
+	p.saves++
+	return nil
+}
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func recordingFetcher(calls *[]fetchCall, docsPerCall int) FetchFunc {
+	return func(ctx context.Context, start, end time.Time, cursor string, page PageFunc) error {
+		*calls = append(*calls, fetchCall{start, end})
+		docs := make([]Document, docsPerCall)
+		for i := range docs {
+			day := start.AddDate(0, 0, i).Format("2006-01-02")
+			docs[i] = Document{ID: "doc-" + day, Day: day, Data: day}
+		}
+		return page(docs, "")
+	}
+}
+
+// pagedFetcher serves pages documents, one per page, with the cursors page2,
+// page3 and so on, recording the cursor every walk started at. Any other
+// cursor is rejected.
+func pagedFetcher(cursors *[]string, pages int) FetchFunc {
+	return func(ctx context.Context, start, end time.Time, cursor string, page PageFunc) error {
+		*cursors = append(*cursors, cursor)
+		first := 1
+		if cursor != "" {
+			if _, err := fmt.Sscanf(cursor, "page%d", &first); err != nil || first < 2 || first > pages {
+				return fmt.Errorf("oura API returned 400 for next_token %q", cursor)
+			}
+		}
+		for i := first; i <= pages; i++ {
+			var next string
+			if i < pages {
+				next = fmt.Sprintf("page%d", i+1)
+			}
+			doc := Document{ID: fmt.Sprintf("doc-%d", i), Data: i}
+			if err := page([]Document{doc}, next); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func newTestCollector(fetchers map[string]FetchFunc, send SendFunc, store Store) *Collector {
+	return New(fetchers, send, store, log.NewEntry(log.New()), metrics.New("oura-collector-test"))
+}
+
+func TestBackfillWalksRangeInChunks(t *testing.T) {
+	var calls []fetchCall
+	var sent int
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) {
+		if userID != "user-1" || dataType != TypeSleep {
+			t.Errorf("Unexpected send for %s/%s", userID, dataType)
+		}
+		sent += len(docs)
+		return 0, nil
+	}
+	progress := newMemoryStore()
+	c := newTestCollector(map[string]FetchFunc{TypeSleep: recordingFetcher(&calls, 2)}, send, progress)
+
+	n, err := c.Backfill(context.Background(), "user-1", []string{TypeSleep}, day("2024-01-01"), day("2024-01-25"), 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := []fetchCall{
+		{day("2024-01-01"), day("2024-01-10")},
+		{day("2024-01-11"), day("2024-01-20")},
+		{day("2024-01-21"), day("2024-01-25")},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("Expected %d fetches, got %d", len(want), len(calls))
+	}
+	for i := range want {
+		if !calls[i].start.Equal(want[i].start) || !calls[i].end.Equal(want[i].end) {
+			t.Errorf("Chunk %d: expected %v, got %v", i, want[i], calls[i])
+		}
+	}
+	if n != 6 || sent != 6 {
+		t.Errorf("Expected 6 documents collected and sent, got %d and %d", n, sent)
+	}
+	if progress.saves != 3 {
+		t.Errorf("Expected progress saved after each chunk, got %d saves", progress.saves)
+	}
+}
+
+func TestBackfillResumesAfterLastCompletedChunk(t *testing.T) {
+	var calls []fetchCall
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) { return 0, nil }
+	progress := newMemoryStore()
+	progress.completed["user-1/activity/2024-01-01"] = day("2024-01-20")
+	c := newTestCollector(map[string]FetchFunc{TypeActivity: recordingFetcher(&calls, 1)}, send, progress)
+
+	if _, err := c.Backfill(context.Background(), "user-1", []string{TypeActivity}, day("2024-01-01"), day("2024-01-25"), 10); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(calls) != 1 || !calls[0].start.Equal(day("2024-01-21")) || !calls[0].end.Equal(day("2024-01-25")) {
+		t.Errorf("Expected a single fetch for 2024-01-21..2024-01-25, got %v", calls)
+	}
+}
+
+func TestBackfillStopsTypeOnSendFailure(t *testing.T) {
+	var sleepCalls, readinessCalls []fetchCall
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) {
+		if dataType == TypeSleep && docs[0].Data == "2024-01-11" {
+			return 0, errors.New("processor returned 500")
+		}
+		return 0, nil
+	}
+	progress := newMemoryStore()
+	c := newTestCollector(map[string]FetchFunc{
+		TypeSleep:     recordingFetcher(&sleepCalls, 1),
+		TypeReadiness: recordingFetcher(&readinessCalls, 1),
+	}, send, progress)
+
+	_, err := c.Backfill(context.Background(), "user-1", []string{TypeSleep, TypeReadiness}, day("2024-01-01"), day("2024-01-30"), 10)
+	if err == nil {
+		t.Fatal("Expected error when the processor rejects a document")
+	}
+
+	if len(sleepCalls) != 2 {
+		t.Errorf("Expected sleep backfill to stop after the failing chunk, got %d fetches", len(sleepCalls))
+	}
+	if got := progress.completed["user-1/sleep/2024-01-01"]; !got.Equal(day("2024-01-10")) {
+		t.Errorf("Expected sleep progress to stop at 2024-01-10, got %v", got)
+	}
+	if len(readinessCalls) != 3 {
+		t.Errorf("Expected readiness backfill to continue, got %d fetches", len(readinessCalls))
+	}
+}
+
+func TestBackfillSkipsTypesWithoutScope(t *testing.T) {
+	forbidden := func(ctx context.Context, start, end time.Time, cursor string, page PageFunc) error {
+		return fmt.Errorf("oura API returned 403: %w", client.ErrMissingScope)
+	}
+	var sleepCalls []fetchCall
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) { return 0, nil }
+	progress := newMemoryStore()
+	c := newTestCollector(map[string]FetchFunc{
+		ingest.TypeWorkout: forbidden,
+		ingest.TypeSession: forbidden,
+		TypeSleep:          recordingFetcher(&sleepCalls, 1),
+	}, send, progress)
+
+	types := []string{ingest.TypeWorkout, ingest.TypeSession, TypeSleep}
+	if _, err := c.Backfill(context.Background(), "user-1", types, day("2024-01-01"), day("2024-01-20"), 10); err != nil {
+		t.Fatalf("Expected types without scope to be skipped, got %v", err)
+	}
+	if _, ok := progress.completed["user-1/workout/2024-01-01"]; ok {
+		t.Error("Expected no workout progress without scope")
+	}
+	if _, ok := progress.completed["user-1/session/2024-01-01"]; ok {
+		t.Error("Expected no session progress without scope")
+	}
+	if len(sleepCalls) != 2 {
+		t.Errorf("Expected sleep backfill to run, got %d fetches", len(sleepCalls))
+	}
+}
+
+func TestBackfillRejectsInvertedRange(t *testing.T) {
+	c := newTestCollector(nil, nil, newMemoryStore())
+
+	if _, err := c.Backfill(context.Background(), "user-1", AllTypes, day("2024-02-01"), day("2024-01-01"), 10); err == nil {
+		t.Error("Expected error when end is before start")
+	}
+}
+
+func TestSyncFetchesInitialWindowWithoutCheckpoint(t *testing.T) {
+	var calls []fetchCall
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) { return 0, nil }
+	store := newMemoryStore()
+	c := newTestCollector(map[string]FetchFunc{TypeSleep: recordingFetcher(&calls, 2)}, send, store)
+
+	now := time.Date(2024, 3, 10, 15, 30, 0, 0, time.UTC)
+	if _, err := c.Sync(context.Background(), "user-1", []string{TypeSleep}, now, SyncWindow{OverlapDays: 3, InitialDays: 7}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(calls) != 1 || !calls[0].start.Equal(day("2024-03-03")) || !calls[0].end.Equal(day("2024-03-10")) {
+		t.Errorf("Expected a fetch for 2024-03-03..2024-03-10, got %v", calls)
+	}
+
+	state := store.sync["user-1/oura/sleep"]
+	if state == nil {
+		t.Fatal("Expected sync state to be saved")
+	}
+	if !state.LastSyncedDay.Equal(day("2024-03-10")) {
+		t.Errorf("Expected checkpoint at 2024-03-10, got %v", state.LastSyncedDay)
+	}
+	if state.Cursor != "" {
+		t.Errorf("Expected no cursor once the walk completed, got %q", state.Cursor)
+	}
+}
+
+func TestSyncRefetchesOverlapBeforeCheckpoint(t *testing.T) {
+	var calls []fetchCall
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) { return 0, nil }
+	store := newMemoryStore()
+	store.sync["user-1/oura/readiness"] = &repository.SyncState{
+		UserID: "user-1", Provider: Provider, DataType: TypeReadiness, LastSyncedDay: day("2024-03-08"),
+	}
+	c := newTestCollector(map[string]FetchFunc{TypeReadiness: recordingFetcher(&calls, 1)}, send, store)
+
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	if _, err := c.Sync(context.Background(), "user-1", []string{TypeReadiness}, now, SyncWindow{OverlapDays: 3, InitialDays: 7}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(calls) != 1 || !calls[0].start.Equal(day("2024-03-05")) || !calls[0].end.Equal(day("2024-03-10")) {
+		t.Errorf("Expected a fetch for 2024-03-05..2024-03-10, got %v", calls)
+	}
+}
+
+func TestSyncDoesNotAdvanceWithoutAcknowledgement(t *testing.T) {
+	var activityCalls []fetchCall
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) {
+		if dataType == TypeActivity {
+			return 0, errors.New("processor returned 500")
+		}
+		return 0, nil
+	}
+	store := newMemoryStore()
+	c := newTestCollector(map[string]FetchFunc{
+		TypeSleep: func(ctx context.Context, start, end time.Time, cursor string, page PageFunc) error {
+			return errors.New("oura API returned 500")
+		},
+		TypeActivity:  recordingFetcher(&activityCalls, 3),
+		TypeReadiness: recordingFetcher(new([]fetchCall), 1),
+	}, send, store)
+
+	n, err := c.Sync(context.Background(), "user-1", AllTypes, day("2024-03-10"), SyncWindow{OverlapDays: 3, InitialDays: 7})
+	if err == nil {
+		t.Error("Expected error for failed sleep fetch and activity sends")
+	}
+	if n != 4 {
+		t.Errorf("Expected 4 documents collected, got %d", n)
+	}
+
+	if store.sync["user-1/oura/sleep"] != nil {
+		t.Error("Expected sleep checkpoint not to advance after a failed fetch")
+	}
+	if store.sync["user-1/oura/activity"] != nil {
+		t.Error("Expected activity checkpoint not to advance after rejected sends")
+	}
+	if store.sync["user-1/oura/readiness"] == nil {
+		t.Error("Expected readiness checkpoint to advance")
+	}
+}
+
+func TestSyncResumesInterruptedWalkFromSavedCursor(t *testing.T) {
+	var cursors []string
+	failing := true
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) {
+		if failing && docs[0].Data == 3 {
+			return 0, errors.New("processor returned 500")
+		}
+		return 0, nil
+	}
+	store := newMemoryStore()
+	c := newTestCollector(map[string]FetchFunc{TypeSleep: pagedFetcher(&cursors, 4)}, send, store)
+	window := SyncWindow{OverlapDays: 3, InitialDays: 7}
+
+	if _, err := c.Sync(context.Background(), "user-1", []string{TypeSleep}, day("2024-03-10"), window); err == nil {
+		t.Fatal("Expected error when the processor fails on the third page")
+	}
+	state := store.sync["user-1/oura/sleep"]
+	if state == nil || state.Cursor != "page3" {
+		t.Fatalf("Expected the cursor of the first unacknowledged page to be saved, got %+v", state)
+	}
+	if !state.CursorStart.Equal(day("2024-03-03")) || !state.CursorEnd.Equal(day("2024-03-10")) {
+		t.Errorf("Expected the cursor's window to be 2024-03-03..2024-03-10, got %+v", state)
+	}
+	if !state.LastSyncedDay.Equal(day("2024-03-02")) {
+		t.Errorf("Expected the checkpoint to stay before the window, got %v", state.LastSyncedDay)
+	}
+
+	failing = false
+	n, err := c.Sync(context.Background(), "user-1", []string{TypeSleep}, day("2024-03-11"), window)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != 2 || len(cursors) != 2 || cursors[1] != "page3" {
+		t.Errorf("Expected the second sync to fetch only pages 3 and 4, got %d documents from cursors %q", n, cursors)
+	}
+	state = store.sync["user-1/oura/sleep"]
+	if state.Cursor != "" || !state.LastSyncedDay.Equal(day("2024-03-10")) {
+		t.Errorf("Expected the checkpoint at the end of the resumed window without a cursor, got %+v", state)
+	}
+}
+
+func TestSyncRestartsWindowWhenSavedCursorIsRejected(t *testing.T) {
+	var cursors []string
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) { return 0, nil }
+	store := newMemoryStore()
+	store.sync["user-1/oura/sleep"] = &repository.SyncState{
+		UserID: "user-1", Provider: Provider, DataType: TypeSleep, LastSyncedDay: day("2024-03-05"),
+		Cursor: "expired", CursorStart: day("2024-03-02"), CursorEnd: day("2024-03-08"),
+	}
+	c := newTestCollector(map[string]FetchFunc{TypeSleep: pagedFetcher(&cursors, 2)}, send, store)
+
+	n, err := c.Sync(context.Background(), "user-1", []string{TypeSleep}, day("2024-03-10"), SyncWindow{OverlapDays: 3, InitialDays: 7})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != 2 || len(cursors) != 2 || cursors[0] != "expired" || cursors[1] != "" {
+		t.Errorf("Expected the window to be walked again from its first page, got %d documents from cursors %q", n, cursors)
+	}
+	if state := store.sync["user-1/oura/sleep"]; !state.LastSyncedDay.Equal(day("2024-03-08")) || state.Cursor != "" {
+		t.Errorf("Expected the checkpoint at the end of the window without a cursor, got %+v", state)
+	}
+}
+
+func TestSyncSkipsTypesWithoutScope(t *testing.T) {
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) { return 0, nil }
+	store := newMemoryStore()
+	c := newTestCollector(map[string]FetchFunc{
+		ingest.TypeHeartRate: func(ctx context.Context, start, end time.Time, cursor string, page PageFunc) error {
+			return fmt.Errorf("heartrate: oura API returned 403: %w", client.ErrMissingScope)
+		},
+		TypeSleep: recordingFetcher(new([]fetchCall), 2),
+	}, send, store)
+
+	n, err := c.Sync(context.Background(), "user-1", []string{ingest.TypeHeartRate, TypeSleep}, day("2024-03-10"), SyncWindow{InitialDays: 7})
+	if err != nil {
+		t.Fatalf("Expected a type without scope to be skipped, got %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 documents collected, got %d", n)
+	}
+	if store.sync["user-1/oura/heartrate"] != nil {
+		t.Error("Expected heartrate checkpoint not to advance without scope")
+	}
+	if store.sync["user-1/oura/sleep"] == nil {
+		t.Error("Expected sleep checkpoint to advance")
+	}
+}
+
+func TestSyncSendsInBatches(t *testing.T) {
+	var batches []int
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) {
+		batches = append(batches, len(docs))
+		return 0, nil
+	}
+	store := newMemoryStore()
+	c := newTestCollector(map[string]FetchFunc{TypeSleep: recordingFetcher(new([]fetchCall), sendBatchSize+2)}, send, store)
+
+	n, err := c.Sync(context.Background(), "user-1", []string{TypeSleep}, day("2024-03-10"), SyncWindow{InitialDays: 7})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != sendBatchSize+2 || len(batches) != 2 || batches[0] != sendBatchSize || batches[1] != 2 {
+		t.Errorf("Expected batches of %d and 2, got %v", sendBatchSize, batches)
+	}
+}
+
+func TestSyncDoesNotAdvanceWhenDocumentsAreRejected(t *testing.T) {
+	send := func(ctx context.Context, userID, dataType string, docs []Document) (int, error) { return 1, nil }
+	store := newMemoryStore()
+	c := newTestCollector(map[string]FetchFunc{TypeSleep: recordingFetcher(new([]fetchCall), 3)}, send, store)
+
+	if _, err := c.Sync(context.Background(), "user-1", []string{TypeSleep}, day("2024-03-10"), SyncWindow{InitialDays: 7}); err == nil {
+		t.Error("Expected error when the processor rejects a document")
+	}
+	if store.sync["user-1/oura/sleep"] != nil {
+		t.Error("Expected sleep checkpoint not to advance after a rejected document")
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	types, err := ParseTypes("")
+	if err != nil || len(types) != len(AllTypes) {
+		t.Errorf("Expected all types for empty input, got %v (%v)", types, err)
+	}
+
+	types, err = ParseTypes("sleep, readiness")
+	if err != nil || len(types) != 2 || types[0] != TypeSleep || types[1] != TypeReadiness {
+		t.Errorf("Expected [sleep readiness], got %v (%v)", types, err)
+	}
+
+	if _, err := ParseTypes("sleep,steps"); err == nil {
+		t.Error("Expected error for unsupported type")
+	}
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/repository"
+)
+
+// UserResult is the outcome of collecting for a single user.
+type UserResult struct {
+	UserID     string
+	DataPoints int
+	Err        error
+}
+
+// RunFunc collects data for one user.
+type RunFunc func(ctx context.Context, user repository.OuraUser) (int, error)
+
+// FanOut runs fn for every user with at most concurrency runs in flight. Each
+// run gets its own timeout (none if timeout is zero) and a failure or panic in
+// one user's run does not affect the others. Results are returned in the same
+// order as users.
+func FanOut(ctx context.Context, users []repository.OuraUser, concurrency int, timeout time.Duration, fn RunFunc) []UserResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]UserResult, len(users))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, user := range users {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, user repository.OuraUser) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = runUser(ctx, user, timeout, fn)
+		}(i, user)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func runUser(ctx context.Context, user repository.OuraUser, timeout time.Duration, fn RunFunc) (result UserResult) {
+	result.UserID = user.UserID
+
+	defer func() {
+		if r := recover(); r != nil {
+			result.Err = fmt.Errorf("panic collecting for user %s: %v", user.UserID, r)
+		}
+	}()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result.DataPoints, result.Err = fn(ctx, user)
+	return result
+}
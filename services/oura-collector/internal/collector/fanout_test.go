@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/asian-code/myapp-kubernetes/services/oura-collector/internal/repository"
+)
+
+func TestFanOutIsolatesFailures(t *testing.T) {
+	users := []repository.OuraUser{{UserID: "a"}, {UserID: "b"}, {UserID: "c"}}
+
+	results := FanOut(context.Background(), users, 2, 0, func(ctx context.Context, user repository.OuraUser) (int, error) {
+		switch user.UserID {
+		case "b":
+			return 0, errors.New("oura API returned 500")
+		case "c":
+			panic("boom")
+		}
+		return 5, nil
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[0].UserID != "a" || results[0].Err != nil || results[0].DataPoints != 5 {
+		t.Errorf("Expected user a to succeed, got %+v", results[0])
+	}
+	if results[1].Err == nil {
+		t.Error("Expected user b to fail")
+	}
+	if results[2].Err == nil {
+		t.Error("Expected user c's panic to be reported as an error")
+	}
+}
+
+func TestFanOutBoundsConcurrency(t *testing.T) {
+	users := make([]repository.OuraUser, 10)
+	for i := range users {
+		users[i].UserID = string(rune('a' + i))
+	}
+
+	var inFlight, maxInFlight int32
+	FanOut(context.Background(), users, 3, 0, func(ctx context.Context, user repository.OuraUser) (int, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return 0, nil
+	})
+
+	if maxInFlight > 3 {
+		t.Errorf("Expected at most 3 concurrent runs, got %d", maxInFlight)
+	}
+}
+
+func TestFanOutAppliesPerUserTimeout(t *testing.T) {
+	users := []repository.OuraUser{{UserID: "slow"}, {UserID: "fast"}}
+
+	results := FanOut(context.Background(), users, 2, 20*time.Millisecond, func(ctx context.Context, user repository.OuraUser) (int, error) {
+		if user.UserID == "slow" {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return 1, nil
+	})
+
+	if !errors.Is(results[0].Err, context.DeadlineExceeded) {
+		t.Errorf("Expected slow user to time out, got %v", results[0].Err)
+	}
+	if results[1].Err != nil {
+		t.Errorf("Expected fast user to succeed, got %v", results[1].Err)
+	}
+}
@@ -1,25 +1,59 @@
 package config
 
 import (
+	"flag"
 	"os"
+	"strconv"
 
 	"github.com/asian-code/myapp-kubernetes/services/pkg/validation"
 )
 
 type Config struct {
-	ProcessorURL string `validate:"required,url"`
-	LogLevel     string `validate:"required,oneof=debug info warn error"`
-	DBHost       string `validate:"required"`
-	DBPort       string `validate:"required"`
-	DBUser       string `validate:"required"`
-	DBPassword   string `validate:"required"`
-	DBName       string `validate:"required"`
-	DBSSLMode    string `validate:"required,oneof=disable require verify-ca verify-full"`
-	UserID       string `validate:"required"` // The user ID to fetch data for
+	ProcessorURL     string `validate:"required,url"`
+	LogLevel         string `validate:"required,oneof=debug info warn error"`
+	DBHost           string `validate:"required"`
+	DBPort           string `validate:"required"`
+	DBUser           string `validate:"required"`
+	DBPassword       string `validate:"required"`
+	DBName           string `validate:"required"`
+	DBSSLMode        string `validate:"required,oneof=disable require verify-ca verify-full"`
+	OuraClientID     string `validate:"required"`
+	OuraClientSecret string `validate:"required"`
+	// SigningKeys is "id:secret[,id:secret]"; requests to the processor are
+	// signed with the first key
+	SigningKeys string `validate:"required"`
+	// Access tokens expiring within this many seconds are refreshed first
+	TokenRefreshSkewSeconds int `validate:"min=0"`
+
+	UserID string // Restricts collection to one user; empty collects for every connected user
+
+	// Users are collected concurrently, each with its own timeout
+	Concurrency        int `validate:"min=1,max=64"`
+	UserTimeoutSeconds int `validate:"min=1"`
+
+	// Incremental sync re-fetches SyncOverlapDays before each checkpoint and
+	// SyncInitialDays for data types that have never been synced
+	SyncOverlapDays int `validate:"min=0,max=90"`
+	SyncInitialDays int `validate:"min=0,max=365"`
+
+	// Backfill mode is enabled when BackfillFrom is set
+	BackfillFrom      string `validate:"omitempty,datetime=2006-01-02"`
+	BackfillTo        string `validate:"omitempty,datetime=2006-01-02"`
+	BackfillTypes     string // Comma separated data types, empty for all
+	BackfillChunkDays int    `validate:"min=1,max=365"`
 }
 
-// Load loads and validates configuration from environment variables
+// Load loads and validates configuration from environment variables.
+// Backfill settings can be overridden with --from, --to, --types and
+// --chunk-days flags.
 func Load() *Config {
+	refreshSkew, _ := strconv.Atoi(getEnv("TOKEN_REFRESH_SKEW_SECONDS", "300"))
+	concurrency, _ := strconv.Atoi(getEnv("COLLECTOR_CONCURRENCY", "4"))
+	userTimeout, _ := strconv.Atoi(getEnv("COLLECTOR_USER_TIMEOUT_SECONDS", "120"))
+	overlapDays, _ := strconv.Atoi(getEnv("SYNC_OVERLAP_DAYS", "3"))
+	initialDays, _ := strconv.Atoi(getEnv("SYNC_INITIAL_DAYS", "7"))
+	chunkDays, _ := strconv.Atoi(getEnv("BACKFILL_CHUNK_DAYS", "30"))
+
 	cfg := &Config{
 		ProcessorURL: os.Getenv("PROCESSOR_URL"),
 		LogLevel:     getEnv("LOG_LEVEL", "info"),
@@ -30,14 +64,37 @@ func Load() *Config {
 		DBName:       getEnv("DB_NAME", "myhealth"),
 		DBSSLMode:    getEnv("DB_SSLMODE", "require"),
 		UserID:       os.Getenv("USER_ID"),
+
+		OuraClientID:            os.Getenv("OURA_CLIENT_ID"),
+		OuraClientSecret:        os.Getenv("OURA_CLIENT_SECRET"),
+		SigningKeys:             os.Getenv("SIGNING_KEYS"),
+		TokenRefreshSkewSeconds: refreshSkew,
+
+		Concurrency:        concurrency,
+		UserTimeoutSeconds: userTimeout,
+
+		SyncOverlapDays: overlapDays,
+		SyncInitialDays: initialDays,
 	}
 
+	flag.StringVar(&cfg.BackfillFrom, "from", os.Getenv("BACKFILL_FROM"), "backfill start date (YYYY-MM-DD); enables backfill mode")
+	flag.StringVar(&cfg.BackfillTo, "to", os.Getenv("BACKFILL_TO"), "backfill end date (YYYY-MM-DD), defaults to today")
+	flag.StringVar(&cfg.BackfillTypes, "types", os.Getenv("BACKFILL_TYPES"), "comma separated data types to backfill, defaults to all")
+	flag.IntVar(&cfg.BackfillChunkDays, "chunk-days", chunkDays, "number of days fetched per backfill chunk")
+	flag.Parse()
+
 	// Validate configuration and panic if invalid
 	validation.MustValidate(cfg)
 
 	return cfg
 }
 
+// Backfill reports whether the collector should run a historical backfill
+// instead of a regular collection.
+func (c *Config) Backfill() bool {
+	return c.BackfillFrom != ""
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+type Repository struct {
+	db     *pgxpool.Pool
+	logger *log.Entry
+}
+
+func New(db *pgxpool.Pool, logger *log.Entry) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetBackfillProgress returns the last day a backfill starting at rangeStart
+// completed for the user and data type. ok is false if the backfill has not
+// completed any chunk yet.
+func (r *Repository) GetBackfillProgress(ctx context.Context, userID, dataType string, rangeStart time.Time) (completedThrough time.Time, ok bool, err error) {
+	query := `
+		SELECT completed_through FROM backfill_progress
+		WHERE user_id = $1 AND data_type = $2 AND range_start = $3
+	`
+	err = r.db.QueryRow(ctx, query, userID, dataType, rangeStart).Scan(&completedThrough)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return completedThrough, true, nil
+}
+
+// SaveBackfillProgress records that a backfill has completed every day up to
+// and including completedThrough.
+func (r *Repository) SaveBackfillProgress(ctx context.Context, userID, dataType string, rangeStart, rangeEnd, completedThrough time.Time) error {
+	query := `
+		INSERT INTO backfill_progress (user_id, data_type, range_start, range_end, completed_through)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, data_type, range_start)
+		DO UPDATE SET range_end = $4, completed_through = $5, updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := r.db.Exec(ctx, query, userID, dataType, rangeStart, rangeEnd, completedThrough)
+	return err
+}
+
+// SyncState is the incremental collection checkpoint for one user, provider
+// and data type.
+type SyncState struct {
+	UserID        string
+	Provider      string
+	DataType      string
+	LastSyncedDay time.Time
+	// Cursor is the provider's page cursor of an interrupted page walk over
+	// CursorStart through CursorEnd, empty once the walk completed.
+	Cursor      string
+	CursorStart time.Time
+	CursorEnd   time.Time
+}
+
+// GetSyncState returns the checkpoint for the user, provider and data type, or
+// nil if the data type has never been synced.
+func (r *Repository) GetSyncState(ctx context.Context, userID, provider, dataType string) (*SyncState, error) {
+	query := `
+		SELECT user_id, provider, data_type, last_synced_day, COALESCE(last_cursor, ''), cursor_start, cursor_end
+		FROM sync_state
+		WHERE user_id = $1 AND provider = $2 AND data_type = $3
+	`
+	var state SyncState
+	var cursorStart, cursorEnd *time.Time
+	err := r.db.QueryRow(ctx, query, userID, provider, dataType).Scan(
+		&state.UserID, &state.Provider, &state.DataType, &state.LastSyncedDay, &state.Cursor, &cursorStart, &cursorEnd,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cursorStart == nil || cursorEnd == nil {
+		state.Cursor = ""
+	} else {
+		state.CursorStart, state.CursorEnd = *cursorStart, *cursorEnd
+	}
+	return &state, nil
+}
+
+// SaveSyncState records the checkpoint, replacing the stored cursor. It must
+// only be called once the processor has acknowledged every document up to
+// LastSyncedDay and, mid-walk, every page before Cursor. Acknowledged means
+// queued, not stored: a document the processor's workers later dead-letter is
+// not fetched again, and is recovered by replaying the dead letter instead.
+func (r *Repository) SaveSyncState(ctx context.Context, state *SyncState) error {
+	query := `
+		INSERT INTO sync_state (user_id, provider, data_type, last_synced_day, last_cursor, cursor_start, cursor_end)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+		ON CONFLICT (user_id, provider, data_type)
+		DO UPDATE SET last_synced_day = $4, last_cursor = NULLIF($5, ''), cursor_start = $6, cursor_end = $7,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	var cursorStart, cursorEnd *time.Time
+	if state.Cursor != "" {
+		cursorStart, cursorEnd = &state.CursorStart, &state.CursorEnd
+	}
+	_, err := r.db.Exec(ctx, query, state.UserID, state.Provider, state.DataType, state.LastSyncedDay, state.Cursor, cursorStart, cursorEnd)
+	return err
+}
+
+// OuraUser is a user with a connected Oura account.
+type OuraUser struct {
+	UserID string
+}
+
+// ListOuraUsers returns every active user with an Oura token. If userID is
+// non-empty only that user is returned.
+func (r *Repository) ListOuraUsers(ctx context.Context, userID string) ([]OuraUser, error) {
+	query := `
+		SELECT t.user_id::text
+		FROM oauth_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.provider = 'oura'
+			AND COALESCE(u.is_active, true)
+			AND ($1 = '' OR t.user_id::text = $1)
+		ORDER BY t.user_id
+	`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []OuraUser
+	for rows.Next() {
+		var u OuraUser
+		if err := rows.Scan(&u.UserID); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
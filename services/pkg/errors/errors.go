@@ -20,6 +20,7 @@ const (
 	ErrCodeInvalidCredentials ErrorCode = "INVALID_CREDENTIALS"
 	ErrCodeTokenExpired       ErrorCode = "TOKEN_EXPIRED"
 	ErrCodeInvalidToken       ErrorCode = "INVALID_TOKEN"
+	ErrCodeUnsupportedVersion ErrorCode = "UNSUPPORTED_SCHEMA_VERSION"
 
 	// Server errors (5xx)
 	ErrCodeInternal           ErrorCode = "INTERNAL_ERROR"
@@ -81,7 +82,7 @@ func (e *AppError) WithDetails(key string, value interface{}) *AppError {
 // getStatusCode maps error codes to HTTP status codes
 func getStatusCode(code ErrorCode) int {
 	switch code {
-	case ErrCodeBadRequest, ErrCodeValidationFailed:
+	case ErrCodeBadRequest, ErrCodeValidationFailed, ErrCodeUnsupportedVersion:
 		return http.StatusBadRequest
 	case ErrCodeUnauthorized, ErrCodeInvalidCredentials, ErrCodeTokenExpired, ErrCodeInvalidToken:
 		return http.StatusUnauthorized
@@ -142,6 +143,10 @@ func InvalidCredentials(message string) *AppError {
 	return New(ErrCodeInvalidCredentials, message)
 }
 
+func UnsupportedVersion(message string) *AppError {
+	return New(ErrCodeUnsupportedVersion, message)
+}
+
 func Internal(message string) *AppError {
 	return New(ErrCodeInternal, message)
 }
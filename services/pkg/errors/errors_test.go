@@ -25,6 +25,12 @@ func TestNew(t *testing.T) {
 			message:        "Authentication required",
 			expectedStatus: http.StatusUnauthorized,
 		},
+		{
+			name:           "unsupported version error",
+			code:           ErrCodeUnsupportedVersion,
+			message:        "Unsupported schema version",
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "not found error",
 			code:           ErrCodeNotFound,
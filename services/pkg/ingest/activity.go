@@ -0,0 +1,59 @@
+package ingest
+
+// TypeActivity is Oura's daily activity summary.
+const TypeActivity = "activity"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeActivity,
+		Versions:     map[int]func() Payload{1: func() Payload { return &ActivityV1{} }},
+		OuraEndpoint: "daily_activity",
+		Table:        "activity_metrics",
+		Columns: []string{
+			"score", "active_calories", "steps", "medium_activity_minutes", "high_activity_minutes",
+			"day_start", "class_5_min", "met_interval", "met_timestamp", "met_items",
+			"non_wear_time",
+		},
+	})
+}
+
+// ActivityV1 is version 1 of the activity payload. Timestamp is when Oura's
+// activity day starts (4 AM local time) and Class5Min holds one character per
+// 5 minutes from it: 0 non-wear, 1 rest, 2 inactive, 3 low, 4 medium,
+// 5 high. NonWearTime is the seconds the ring was not worn. All three are
+// optional.
+type ActivityV1 struct {
+	ID                string      `json:"id" validate:"required"`
+	Day               string      `json:"day" validate:"required,datetime=2006-01-02"`
+	Score             int         `json:"score" validate:"min=0,max=100"`
+	ActiveCalories    int         `json:"active_calories" validate:"min=0"`
+	Steps             int         `json:"steps" validate:"min=0"`
+	MediumActivityMin int         `json:"medium_activity_minutes" validate:"min=0"`
+	HighActivityMin   int         `json:"high_activity_minutes" validate:"min=0"`
+	Timestamp         string      `json:"timestamp,omitempty" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Class5Min         string      `json:"class_5_min,omitempty" validate:"omitempty,numeric"`
+	MET               *METSamples `json:"met,omitempty"`
+	NonWearTime       *int        `json:"non_wear_time,omitempty" validate:"omitempty,min=0,max=86400"`
+}
+
+// METSamples are metabolic equivalent readings taken every Interval seconds
+// from Timestamp.
+type METSamples struct {
+	Interval  float64   `json:"interval" validate:"gt=0"`
+	Items     []float64 `json:"items" validate:"dive,min=0"`
+	Timestamp string    `json:"timestamp" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+func (p *ActivityV1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *ActivityV1) Values() []interface{} {
+	values := []interface{}{
+		p.Score, p.ActiveCalories, p.Steps, p.MediumActivityMin, p.HighActivityMin,
+		optionalTimestamp(p.Timestamp), optionalString(p.Class5Min), nil, nil, nil,
+		nullable(p.NonWearTime),
+	}
+	if p.MET != nil {
+		values[7], values[8], values[9] = p.MET.Interval, timestamp(p.MET.Timestamp), p.MET.Items
+	}
+	return values
+}
@@ -0,0 +1,29 @@
+package ingest
+
+// TypeCardiovascularAge is Oura's daily estimate of the user's vascular age
+// from their pulse wave velocity.
+const TypeCardiovascularAge = "cardiovascular_age"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeCardiovascularAge,
+		Versions:     map[int]func() Payload{1: func() Payload { return &CardiovascularAgeV1{} }},
+		OuraEndpoint: "daily_cardiovascular_age",
+		Table:        "cardiovascular_age_metrics",
+		Columns:      []string{"vascular_age"},
+		Trend:        "vascular_age",
+	})
+}
+
+// CardiovascularAgeV1 is version 1 of the cardiovascular age payload, in
+// years.
+type CardiovascularAgeV1 struct {
+	Day         string `json:"day" validate:"required,datetime=2006-01-02"`
+	VascularAge *int   `json:"vascular_age" validate:"omitempty,min=0,max=150"`
+}
+
+// Document identifies the payload by its day, as Oura gives cardiovascular
+// age no ID.
+func (p *CardiovascularAgeV1) Document() (string, string) { return p.Day, p.Day }
+
+func (p *CardiovascularAgeV1) Values() []interface{} { return []interface{}{nullable(p.VascularAge)} }
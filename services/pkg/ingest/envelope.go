@@ -0,0 +1,146 @@
+// Package ingest defines the envelope the collector sends to the
+// data-processor. Both sides build and parse records through this package so
+// the wire format cannot drift between them.
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/validation"
+)
+
+// SourceOura identifies records fetched from the Oura API.
+const SourceOura = "oura"
+
+// Envelope wraps a single metric record for ingestion.
+type Envelope struct {
+	Type          string          `json:"type" validate:"required"`
+	SchemaVersion int             `json:"schema_version" validate:"required,min=1"`
+	UserID        string          `json:"user_id" validate:"required,uuid"`
+	Source        string          `json:"source" validate:"required,max=50"`
+	Payload       json.RawMessage `json:"payload" validate:"required"`
+
+	// IdempotencyKey optionally identifies the request carrying the envelope,
+	// as an alternative to the Idempotency-Key header.
+	IdempotencyKey string `json:"idempotency_key,omitempty" validate:"omitempty,max=255"`
+
+	// Raw optionally carries the upstream document the payload was derived
+	// from, exactly as the provider returned it, and FetchedAt when it was
+	// fetched. The processor archives it for reprocessing.
+	Raw       json.RawMessage `json:"raw,omitempty"`
+	FetchedAt *time.Time      `json:"fetched_at,omitempty"`
+}
+
+// WithRaw attaches the upstream document the payload was derived from.
+func (e *Envelope) WithRaw(raw json.RawMessage, fetchedAt time.Time) *Envelope {
+	e.Raw = raw
+	e.FetchedAt = &fetchedAt
+	return e
+}
+
+// CurrentVersion is the schema version New stamps on envelopes.
+const CurrentVersion = 1
+
+// New wraps payload in an envelope at the current schema version and
+// validates the result.
+func New(dataType, userID, source string, payload interface{}) (*Envelope, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", dataType, err)
+	}
+
+	env := &Envelope{
+		Type:          dataType,
+		SchemaVersion: CurrentVersion,
+		UserID:        userID,
+		Source:        source,
+		Payload:       raw,
+	}
+	if err := env.Validate(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// Decode strictly parses and validates a single envelope from r. Unknown
+// fields and trailing data are rejected. Errors are *errors.AppError values
+// suitable for returning to the client.
+func Decode(r io.Reader) (*Envelope, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var env Envelope
+	if err := dec.Decode(&env); err != nil {
+		return nil, apperrors.BadRequest(fmt.Sprintf("invalid envelope: %v", err))
+	}
+	if dec.More() {
+		return nil, apperrors.BadRequest("invalid envelope: unexpected data after envelope")
+	}
+
+	if err := env.Validate(); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+// Validate checks the envelope fields, that the type and schema version are
+// supported, and that the payload matches its schema.
+func (e *Envelope) Validate() error {
+	if err := validation.Validate(e); err != nil {
+		return apperrors.ValidationFailed(err.Error())
+	}
+	if len(e.Raw) > 0 {
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(e.Raw, &doc); err != nil {
+			return apperrors.ValidationFailed("raw must be a JSON object")
+		}
+	}
+
+	_, err := e.DecodePayload()
+	return err
+}
+
+// DecodePayload strictly decodes and validates the payload into the struct
+// for the envelope's type and schema version, e.g. *SleepV1.
+func (e *Envelope) DecodePayload() (Payload, error) {
+	t, ok := registry[e.Type]
+	if !ok {
+		return nil, apperrors.ValidationFailed(fmt.Sprintf("unknown metric type %q (supported: %s)", e.Type, strings.Join(Types(), ", "))).
+			WithDetails("type", e.Type)
+	}
+
+	newPayload, ok := t.Versions[e.SchemaVersion]
+	if !ok {
+		supported := SupportedVersions(e.Type)
+		return nil, apperrors.UnsupportedVersion(fmt.Sprintf("schema_version %d is not supported for type %q (supported: %s)", e.SchemaVersion, e.Type, joinInts(supported))).
+			WithDetails("type", e.Type).
+			WithDetails("schema_version", e.SchemaVersion).
+			WithDetails("supported_versions", supported)
+	}
+
+	payload := newPayload()
+	dec := json.NewDecoder(bytes.NewReader(e.Payload))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(payload); err != nil {
+		return nil, apperrors.ValidationFailed(fmt.Sprintf("invalid %s payload: %v", e.Type, err))
+	}
+	if err := validation.Validate(payload); err != nil {
+		return nil, apperrors.ValidationFailed(fmt.Sprintf("invalid %s payload: %v", e.Type, err))
+	}
+
+	return payload, nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
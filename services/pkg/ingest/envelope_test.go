@@ -0,0 +1,155 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+
+	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+)
+
+const testUserID = "6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b"
+
+func TestNewRoundTrip(t *testing.T) {
+	env, err := New(TypeSleep, testUserID, SourceOura, SleepV1{ID: "s1", Day: "2024-01-01", Score: 80, Duration: 28800})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if env.SchemaVersion != CurrentVersion {
+		t.Errorf("Expected schema version %d, got %d", CurrentVersion, env.SchemaVersion)
+	}
+
+	payload, err := env.DecodePayload()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	sleep, ok := payload.(*SleepV1)
+	if !ok {
+		t.Fatalf("Expected *SleepV1, got %T", payload)
+	}
+	if sleep.ID != "s1" || sleep.Score != 80 {
+		t.Errorf("Unexpected payload: %+v", sleep)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	valid := `{"type":"readiness","schema_version":1,"user_id":"` + testUserID + `","source":"oura","payload":{"id":"r1","day":"2024-01-01","score":75}}`
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode apperrors.ErrorCode
+	}{
+		{name: "valid", body: valid},
+		{
+			name: "with idempotency key",
+			body: strings.Replace(valid, `"source":"oura"`, `"source":"oura","idempotency_key":"sync-42"`, 1),
+		},
+		{
+			name: "with raw document",
+			body: strings.Replace(valid, `"source":"oura"`, `"source":"oura","raw":{"id":"r1","contributors":{}},"fetched_at":"2024-01-02T03:04:05Z"`, 1),
+		},
+		{
+			name:     "raw document not an object",
+			body:     strings.Replace(valid, `"source":"oura"`, `"source":"oura","raw":[1]`, 1),
+			wantCode: apperrors.ErrCodeValidationFailed,
+		},
+		{
+			name:     "idempotency key too long",
+			body:     strings.Replace(valid, `"source":"oura"`, `"source":"oura","idempotency_key":"`+strings.Repeat("k", 256)+`"`, 1),
+			wantCode: apperrors.ErrCodeValidationFailed,
+		},
+		{
+			name:     "unsupported version",
+			body:     strings.Replace(valid, `"schema_version":1`, `"schema_version":7`, 1),
+			wantCode: apperrors.ErrCodeUnsupportedVersion,
+		},
+		{
+			name:     "missing version",
+			body:     strings.Replace(valid, `"schema_version":1,`, ``, 1),
+			wantCode: apperrors.ErrCodeValidationFailed,
+		},
+		{
+			name:     "unknown type",
+			body:     strings.Replace(valid, `"readiness"`, `"steps"`, 1),
+			wantCode: apperrors.ErrCodeValidationFailed,
+		},
+		{
+			name:     "invalid user id",
+			body:     strings.Replace(valid, testUserID, "not-a-uuid", 1),
+			wantCode: apperrors.ErrCodeValidationFailed,
+		},
+		{
+			name:     "missing source",
+			body:     strings.Replace(valid, `"source":"oura",`, ``, 1),
+			wantCode: apperrors.ErrCodeValidationFailed,
+		},
+		{
+			name:     "unknown envelope field",
+			body:     strings.Replace(valid, `"source":"oura"`, `"source":"oura","data":{}`, 1),
+			wantCode: apperrors.ErrCodeBadRequest,
+		},
+		{
+			name:     "unknown payload field",
+			body:     strings.Replace(valid, `"score":75`, `"score":75,"steps":1`, 1),
+			wantCode: apperrors.ErrCodeValidationFailed,
+		},
+		{
+			name:     "invalid day",
+			body:     strings.Replace(valid, `"2024-01-01"`, `"01/01/2024"`, 1),
+			wantCode: apperrors.ErrCodeValidationFailed,
+		},
+		{
+			name:     "score out of range",
+			body:     strings.Replace(valid, `"score":75`, `"score":150`, 1),
+			wantCode: apperrors.ErrCodeValidationFailed,
+		},
+		{
+			name:     "trailing data",
+			body:     valid + valid,
+			wantCode: apperrors.ErrCodeBadRequest,
+		},
+		{
+			name:     "legacy bare payload",
+			body:     `{"id":"r1","day":"2024-01-01","score":75}`,
+			wantCode: apperrors.ErrCodeBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.body))
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			appErr := apperrors.GetAppError(err)
+			if appErr == nil {
+				t.Fatalf("Expected AppError, got %v", err)
+			}
+			if appErr.Code != tt.wantCode {
+				t.Errorf("Expected code %s, got %s (%s)", tt.wantCode, appErr.Code, appErr.Message)
+			}
+		})
+	}
+}
+
+func TestUnsupportedVersionMessage(t *testing.T) {
+	env := &Envelope{Type: TypeSleep, SchemaVersion: 2, UserID: testUserID, Source: SourceOura, Payload: []byte(`{}`)}
+
+	err := env.Validate()
+	appErr := apperrors.GetAppError(err)
+	if appErr == nil {
+		t.Fatalf("Expected AppError, got %v", err)
+	}
+
+	want := `schema_version 2 is not supported for type "sleep" (supported: 1)`
+	if appErr.Message != want {
+		t.Errorf("Expected message %q, got %q", want, appErr.Message)
+	}
+	if appErr.Details["schema_version"] != 2 {
+		t.Errorf("Expected schema_version detail, got %v", appErr.Details)
+	}
+}
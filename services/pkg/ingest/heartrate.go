@@ -0,0 +1,45 @@
+package ingest
+
+// TypeHeartRate is Oura's heart rate time series, a reading every few
+// minutes and more often during workouts.
+const TypeHeartRate = "heartrate"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeHeartRate,
+		Versions:     map[int]func() Payload{1: func() Payload { return &HeartRateV1{} }},
+		OuraEndpoint: "heartrate",
+		Table:        "heartrate",
+		Columns:      []string{"timestamp", "bpm", "source"},
+		Series:       true,
+	})
+}
+
+// HeartRateV1 is version 1 of the heart rate payload: one day's samples.
+type HeartRateV1 struct {
+	Day     string            `json:"day" validate:"required,datetime=2006-01-02"`
+	Samples []HeartRateSample `json:"samples" validate:"required,min=1,dive"`
+}
+
+// HeartRateSample is a single heart rate reading. Source is what Oura was
+// measuring at the time, e.g. awake, rest, sleep or workout.
+type HeartRateSample struct {
+	Timestamp string `json:"timestamp" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	BPM       int    `json:"bpm" validate:"min=1,max=300"`
+	Source    string `json:"source" validate:"max=20"`
+}
+
+// Document identifies the payload by its day, as samples have no upstream
+// ID.
+func (p *HeartRateV1) Document() (string, string) { return p.Day, p.Day }
+
+// Values is unused for a series; see Rows.
+func (p *HeartRateV1) Values() []interface{} { return nil }
+
+func (p *HeartRateV1) Rows() [][]interface{} {
+	rows := make([][]interface{}, len(p.Samples))
+	for i, s := range p.Samples {
+		rows[i] = []interface{}{timestamp(s.Timestamp), s.BPM, s.Source}
+	}
+	return rows
+}
@@ -0,0 +1,27 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FromOura converts a document exactly as the Oura API returned it into the
+// current payload for dataType, e.g. *SleepV1. The collector uses it for live
+// ingest and the processor to reprocess archived documents, so a parser fix
+// applies to both. The current payloads use Oura's field names, so a document
+// decodes straight into them; fields the payload does not carry are ignored.
+// For a series the document is one day of samples as grouped by the
+// collector: {"day": ..., "samples": [...]}.
+func FromOura(dataType string, doc json.RawMessage) (Payload, error) {
+	t, ok := registry[dataType]
+	if !ok || t.OuraEndpoint == "" {
+		return nil, fmt.Errorf("no Oura parser for type %q (supported: %s)", dataType, strings.Join(Types(), ", "))
+	}
+
+	payload := t.Versions[CurrentVersion]()
+	if err := json.Unmarshal(doc, payload); err != nil {
+		return nil, fmt.Errorf("parse Oura %s document: %w", dataType, err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,388 @@
+package ingest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFromOura(t *testing.T) {
+	doc := json.RawMessage(`{
+		"id": "a1",
+		"day": "2024-01-10",
+		"score": 82,
+		"active_calories": 450,
+		"steps": 9100,
+		"medium_activity_minutes": 30,
+		"high_activity_minutes": 12,
+		"contributors": {"stay_active": 90},
+		"timestamp": "2024-01-10T04:00:00+00:00",
+		"class_5_min": "0011233445",
+		"met": {"interval": 60.0, "items": [0.9, 1.2, 3.5], "timestamp": "2024-01-10T04:00:00.000+00:00"},
+		"non_wear_time": 3600
+	}`)
+
+	payload, err := FromOura(TypeActivity, doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := &ActivityV1{
+		ID: "a1", Day: "2024-01-10", Score: 82, ActiveCalories: 450, Steps: 9100, MediumActivityMin: 30, HighActivityMin: 12,
+		Timestamp: "2024-01-10T04:00:00+00:00",
+		Class5Min: "0011233445",
+		MET:       &METSamples{Interval: 60, Items: []float64{0.9, 1.2, 3.5}, Timestamp: "2024-01-10T04:00:00.000+00:00"},
+	}
+	nonWear := 3600
+	want.NonWearTime = &nonWear
+	if !reflect.DeepEqual(payload, want) {
+		t.Errorf("Expected %+v, got %+v", want, payload)
+	}
+
+	activity, _ := Lookup(TypeActivity)
+	row := activity.Row(payload)
+	if want := time.Date(2024, 1, 10, 4, 0, 0, 0, time.UTC); row["day_start"] != want || row["met_timestamp"] != want {
+		t.Errorf("Expected day_start and met_timestamp %v, got %v and %v", want, row["day_start"], row["met_timestamp"])
+	}
+	if row["non_wear_time"] != 3600 {
+		t.Errorf("Expected non_wear_time 3600, got %v", row["non_wear_time"])
+	}
+
+	if _, err := New(TypeActivity, testUserID, SourceOura, payload); err != nil {
+		t.Errorf("Expected parsed payload to validate, got %v", err)
+	}
+}
+
+func TestFromOuraSleepPeriod(t *testing.T) {
+	doc := json.RawMessage(`{
+		"id": "s1",
+		"day": "2024-01-10",
+		"type": "long_sleep",
+		"bedtime_start": "2024-01-09T23:10:00+02:00",
+		"bedtime_end": "2024-01-10T07:05:00+02:00",
+		"deep_sleep_duration": 4500,
+		"light_sleep_duration": 14400,
+		"rem_sleep_duration": 6300,
+		"awake_time": 3300,
+		"efficiency": 88,
+		"latency": 600,
+		"lowest_heart_rate": 48,
+		"average_hrv": 62,
+		"average_breath": 14.5,
+		"sleep_phase_5_min": "4221133224"
+	}`)
+
+	payload, err := FromOura(TypeSleepPeriod, doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := New(TypeSleepPeriod, testUserID, SourceOura, payload); err != nil {
+		t.Errorf("Expected parsed payload to validate, got %v", err)
+	}
+
+	sleepPeriod, _ := Lookup(TypeSleepPeriod)
+	row := sleepPeriod.Row(payload)
+	if want := time.Date(2024, 1, 9, 21, 10, 0, 0, time.UTC); row["bedtime_start"] != want {
+		t.Errorf("Expected bedtime_start %v, got %v", want, row["bedtime_start"])
+	}
+	if row["efficiency"] != 88 || row["sleep_phase_5_min"] != "4221133224" {
+		t.Errorf("Unexpected row %+v", row)
+	}
+
+	// Oura omits fields it could not measure; they are stored as NULL.
+	payload, err = FromOura(TypeSleepPeriod, json.RawMessage(`{"id": "s2", "day": "2024-01-10", "bedtime_start": "2024-01-10T13:00:00+00:00", "bedtime_end": "2024-01-10T13:20:00+00:00"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if row := sleepPeriod.Row(payload); row["average_hrv"] != nil {
+		t.Errorf("Expected NULL average_hrv, got %v", row["average_hrv"])
+	}
+}
+
+func TestActivityWithoutTimeline(t *testing.T) {
+	activity, _ := Lookup(TypeActivity)
+	row := activity.Row(&ActivityV1{ID: "a1", Day: "2024-01-10", Score: 82})
+	for _, col := range []string{"day_start", "class_5_min", "met_interval", "met_timestamp", "met_items"} {
+		if row[col] != nil {
+			t.Errorf("Expected %s to be NULL, got %v", col, row[col])
+		}
+	}
+}
+
+func TestFromOuraWorkout(t *testing.T) {
+	doc := json.RawMessage(`{
+		"id": "w1",
+		"activity": "running",
+		"calories": 412.5,
+		"day": "2024-01-10",
+		"distance": 7200.0,
+		"end_datetime": "2024-01-10T18:45:00+01:00",
+		"intensity": "moderate",
+		"label": null,
+		"source": "confirmed",
+		"start_datetime": "2024-01-10T18:00:00+01:00"
+	}`)
+
+	payload, err := FromOura(TypeWorkout, doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := New(TypeWorkout, testUserID, SourceOura, payload); err != nil {
+		t.Errorf("Expected parsed payload to validate, got %v", err)
+	}
+
+	workout, _ := Lookup(TypeWorkout)
+	row := workout.Row(payload)
+	if row["activity"] != "running" || row["calories"] != 412.5 || row["label"] != nil {
+		t.Errorf("Unexpected row %+v", row)
+	}
+	if want := time.Date(2024, 1, 10, 17, 0, 0, 0, time.UTC); row["start_datetime"] != want {
+		t.Errorf("Expected start_datetime %v, got %v", want, row["start_datetime"])
+	}
+}
+
+func TestFromOuraSession(t *testing.T) {
+	doc := json.RawMessage(`{
+		"id": "m1",
+		"day": "2024-01-10",
+		"start_datetime": "2024-01-10T07:00:00+00:00",
+		"end_datetime": "2024-01-10T07:10:00+00:00",
+		"type": "meditation",
+		"mood": "good",
+		"heart_rate": {"interval": 5.0, "items": [62.0, null, 58.0, 60.0], "timestamp": "2024-01-10T07:00:00+00:00"},
+		"heart_rate_variability": {"interval": 5.0, "items": [40.0, 50.0], "timestamp": "2024-01-10T07:00:00+00:00"},
+		"motion_count": {"interval": 5.0, "items": [0.0, 1.0], "timestamp": "2024-01-10T07:00:00+00:00"}
+	}`)
+
+	payload, err := FromOura(TypeSession, doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := New(TypeSession, testUserID, SourceOura, payload); err != nil {
+		t.Errorf("Expected parsed payload to validate, got %v", err)
+	}
+
+	session, _ := Lookup(TypeSession)
+	row := session.Row(payload)
+	want := map[string]interface{}{"heart_rate_average": 60.0, "heart_rate_min": 58.0, "heart_rate_max": 62.0, "hrv_average": 45.0}
+	for col, v := range want {
+		if row[col] != v {
+			t.Errorf("Expected %s %v, got %v", col, v, row[col])
+		}
+	}
+
+	// Sessions without samples have no summary
+	row = session.Row(&SessionV1{ID: "m2", Day: "2024-01-10", Type: "rest"})
+	if row["heart_rate_average"] != nil || row["hrv_average"] != nil {
+		t.Errorf("Expected no heart rate summary, got %+v", row)
+	}
+}
+
+func TestFromOuraReadinessContributors(t *testing.T) {
+	doc := json.RawMessage(`{
+		"id": "r1",
+		"day": "2024-01-10",
+		"score": 74,
+		"contributors": {
+			"activity_balance": 80, "body_temperature": 95, "hrv_balance": 52, "previous_day_activity": null,
+			"previous_night": 70, "recovery_index": 88, "resting_heart_rate": 90, "sleep_balance": 77
+		},
+		"temperature_deviation": -0.12,
+		"temperature_trend_deviation": 0.05
+	}`)
+
+	payload, err := FromOura(TypeReadiness, doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := New(TypeReadiness, testUserID, SourceOura, payload); err != nil {
+		t.Errorf("Expected parsed payload to validate, got %v", err)
+	}
+
+	readiness, _ := Lookup(TypeReadiness)
+	row := readiness.Row(payload)
+	want := map[string]interface{}{
+		"score":            74,
+		"activity_balance": 80, "body_temperature": 95, "hrv_balance": 52, "previous_day_activity": nil,
+		"previous_night": 70, "recovery_index": 88, "resting_heart_rate": 90, "sleep_balance": 77,
+		"temperature_deviation": -0.12, "temperature_trend_deviation": 0.05,
+	}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("Expected row %v, got %v", want, row)
+	}
+
+	// Payloads stored before contributors were ingested still convert
+	row = readiness.Row(&ReadinessV1{ID: "r0", Day: "2024-01-01", Score: 60})
+	if row["score"] != 60 || row["hrv_balance"] != nil || row["temperature_deviation"] != nil {
+		t.Errorf("Expected score only, got %+v", row)
+	}
+}
+
+func TestFromOuraDailyStressAndRecovery(t *testing.T) {
+	tests := []struct {
+		metricType string
+		doc        string
+		want       map[string]interface{}
+	}{
+		{
+			TypeSpO2,
+			`{"id": "o1", "day": "2024-01-10", "spo2_percentage": {"average": 96.5}, "breathing_disturbance_index": 4}`,
+			map[string]interface{}{"spo2_average": 96.5, "breathing_disturbance_index": 4},
+		},
+		{
+			// Nights Oura could not measure are stored as NULL
+			TypeSpO2,
+			`{"id": "o2", "day": "2024-01-11", "spo2_percentage": null, "breathing_disturbance_index": null}`,
+			map[string]interface{}{"spo2_average": nil, "breathing_disturbance_index": nil},
+		},
+		{
+			TypeStress,
+			`{"id": "s1", "day": "2024-01-10", "stress_high": 5400, "recovery_high": 3600, "day_summary": "normal"}`,
+			map[string]interface{}{"stress_high": 5400, "recovery_high": 3600, "day_summary": "normal"},
+		},
+		{
+			TypeStress,
+			`{"id": "s2", "day": "2024-01-11", "stress_high": null, "recovery_high": null, "day_summary": null}`,
+			map[string]interface{}{"stress_high": nil, "recovery_high": nil, "day_summary": nil},
+		},
+		{
+			TypeResilience,
+			`{"id": "r1", "day": "2024-01-10", "level": "solid", "contributors": {"sleep_recovery": 70.5, "daytime_recovery": 40.0, "stress": 55.2}}`,
+			map[string]interface{}{"level": "solid", "sleep_recovery": 70.5, "daytime_recovery": 40.0, "stress": 55.2},
+		},
+	}
+
+	for _, tt := range tests {
+		payload, err := FromOura(tt.metricType, json.RawMessage(tt.doc))
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.metricType, err)
+		}
+		if _, err := New(tt.metricType, testUserID, SourceOura, payload); err != nil {
+			t.Errorf("%s: expected parsed payload to validate, got %v", tt.metricType, err)
+		}
+		mt, _ := Lookup(tt.metricType)
+		if row := mt.Row(payload); !reflect.DeepEqual(row, tt.want) {
+			t.Errorf("%s: expected row %v, got %v", tt.metricType, tt.want, row)
+		}
+	}
+
+	payload, _ := FromOura(TypeResilience, json.RawMessage(`{"id": "r2", "day": "2024-01-10", "level": "superb"}`))
+	if _, err := New(TypeResilience, testUserID, SourceOura, payload); err == nil {
+		t.Error("Expected unknown resilience level to fail validation")
+	}
+}
+
+func TestFromOuraFitnessMarkers(t *testing.T) {
+	payload, err := FromOura(TypeVO2Max, json.RawMessage(`{"id": "v1", "day": "2024-01-10", "timestamp": "2024-01-10T00:00:00+00:00", "vo2_max": 42.5}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := New(TypeVO2Max, testUserID, SourceOura, payload); err != nil {
+		t.Errorf("Expected parsed payload to validate, got %v", err)
+	}
+	vo2Max, _ := Lookup(TypeVO2Max)
+	if row := vo2Max.Row(payload); row["vo2_max"] != 42.5 {
+		t.Errorf("Expected vo2_max 42.5, got %v", row["vo2_max"])
+	}
+
+	// Cardiovascular age documents have no ID and are keyed by day
+	payload, err = FromOura(TypeCardiovascularAge, json.RawMessage(`{"day": "2024-01-10", "vascular_age": 38}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := New(TypeCardiovascularAge, testUserID, SourceOura, payload); err != nil {
+		t.Errorf("Expected parsed payload to validate, got %v", err)
+	}
+	if id, day := payload.Document(); id != "2024-01-10" || day != "2024-01-10" {
+		t.Errorf("Expected document keyed by day, got %s %s", id, day)
+	}
+	cardiovascularAge, _ := Lookup(TypeCardiovascularAge)
+	if row := cardiovascularAge.Row(payload); row["vascular_age"] != 38 {
+		t.Errorf("Expected vascular_age 38, got %v", row["vascular_age"])
+	}
+}
+
+func TestFromOuraTag(t *testing.T) {
+	doc := json.RawMessage(`{
+		"id": "t1",
+		"tag_type_code": "tag_generic_alcohol",
+		"start_time": "2024-01-10T21:30:00+01:00",
+		"end_time": null,
+		"start_day": "2024-01-10",
+		"end_day": null,
+		"comment": "two glasses of wine",
+		"custom_name": null
+	}`)
+
+	payload, err := FromOura(TypeTag, doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := New(TypeTag, testUserID, SourceOura, payload); err != nil {
+		t.Errorf("Expected parsed payload to validate, got %v", err)
+	}
+	if id, day := payload.Document(); id != "t1" || day != "2024-01-10" {
+		t.Errorf("Expected document t1 on its start day, got %s %s", id, day)
+	}
+
+	tag, _ := Lookup(TypeTag)
+	row := tag.Row(payload)
+	want := map[string]interface{}{
+		"tag_type_code": "tag_generic_alcohol",
+		"custom_name":   nil,
+		"comment":       "two glasses of wine",
+		"start_time":    time.Date(2024, 1, 10, 20, 30, 0, 0, time.UTC),
+		"end_time":      nil,
+		"end_day":       nil,
+	}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("Expected row %v, got %v", want, row)
+	}
+
+	// A custom tag spanning several days
+	row = tag.Row(&TagV1{
+		ID: "t2", TagTypeCode: "custom", CustomName: "Jet lag",
+		StartTime: "2024-01-12T08:00:00Z", EndTime: "2024-01-14T20:00:00Z", StartDay: "2024-01-12", EndDay: "2024-01-14",
+	})
+	if row["custom_name"] != "Jet lag" || row["end_day"] != time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("Expected custom tag ending 2024-01-14, got %+v", row)
+	}
+}
+
+func TestFromOuraRestMode(t *testing.T) {
+	doc := json.RawMessage(`{
+		"id": "rm1",
+		"start_day": "2024-01-08",
+		"start_time": "2024-01-08T09:15:00+01:00",
+		"end_day": null,
+		"end_time": null,
+		"episodes": [{"tags": ["tag_generic_flu"], "timestamp": "2024-01-08T09:15:00+01:00"}]
+	}`)
+
+	payload, err := FromOura(TypeRestMode, doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := New(TypeRestMode, testUserID, SourceOura, payload); err != nil {
+		t.Errorf("Expected parsed payload to validate, got %v", err)
+	}
+	if id, day := payload.Document(); id != "rm1" || day != "2024-01-08" {
+		t.Errorf("Expected document rm1 on its start day, got %s %s", id, day)
+	}
+
+	restMode, _ := Lookup(TypeRestMode)
+	want := map[string]interface{}{"start_time": time.Date(2024, 1, 8, 8, 15, 0, 0, time.UTC), "end_time": nil, "end_day": nil}
+	if row := restMode.Row(payload); !reflect.DeepEqual(row, want) {
+		t.Errorf("Expected ongoing period %v, got %v", want, row)
+	}
+}
+
+func TestFromOuraErrors(t *testing.T) {
+	if _, err := FromOura("heart_rate", json.RawMessage(`{}`)); err == nil {
+		t.Error("Expected error for type without a parser")
+	}
+	if _, err := FromOura(TypeSleep, json.RawMessage(`{"score": "high"}`)); err == nil {
+		t.Error("Expected error for mistyped field")
+	}
+}
@@ -0,0 +1,63 @@
+package ingest
+
+// TypeReadiness is Oura's daily readiness summary.
+const TypeReadiness = "readiness"
+
+// ReadinessContributorNames are the columns holding the scores readiness is
+// derived from, in Oura's order.
+var ReadinessContributorNames = []string{
+	"activity_balance", "body_temperature", "hrv_balance", "previous_day_activity",
+	"previous_night", "recovery_index", "resting_heart_rate", "sleep_balance",
+}
+
+func init() {
+	columns := append([]string{"score"}, ReadinessContributorNames...)
+	Register(&MetricType{
+		Name:         TypeReadiness,
+		Versions:     map[int]func() Payload{1: func() Payload { return &ReadinessV1{} }},
+		OuraEndpoint: "daily_readiness",
+		Table:        "readiness_metrics",
+		Columns:      append(columns, "temperature_deviation", "temperature_trend_deviation"),
+	})
+}
+
+// ReadinessV1 is version 1 of the readiness payload. The temperature
+// deviations are in degrees Celsius from the user's baseline. Contributors
+// and temperatures are optional, as payloads stored before they were
+// ingested lack them.
+type ReadinessV1 struct {
+	ID                        string                 `json:"id" validate:"required"`
+	Day                       string                 `json:"day" validate:"required,datetime=2006-01-02"`
+	Score                     int                    `json:"score" validate:"min=0,max=100"`
+	Contributors              *ReadinessContributors `json:"contributors,omitempty"`
+	TemperatureDeviation      *float64               `json:"temperature_deviation,omitempty"`
+	TemperatureTrendDeviation *float64               `json:"temperature_trend_deviation,omitempty"`
+}
+
+// ReadinessContributors are the 1-100 scores readiness is derived from.
+// Oura leaves a contributor null when it lacks the data to score it.
+type ReadinessContributors struct {
+	ActivityBalance     *int `json:"activity_balance" validate:"omitempty,min=0,max=100"`
+	BodyTemperature     *int `json:"body_temperature" validate:"omitempty,min=0,max=100"`
+	HRVBalance          *int `json:"hrv_balance" validate:"omitempty,min=0,max=100"`
+	PreviousDayActivity *int `json:"previous_day_activity" validate:"omitempty,min=0,max=100"`
+	PreviousNight       *int `json:"previous_night" validate:"omitempty,min=0,max=100"`
+	RecoveryIndex       *int `json:"recovery_index" validate:"omitempty,min=0,max=100"`
+	RestingHeartRate    *int `json:"resting_heart_rate" validate:"omitempty,min=0,max=100"`
+	SleepBalance        *int `json:"sleep_balance" validate:"omitempty,min=0,max=100"`
+}
+
+func (p *ReadinessV1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *ReadinessV1) Values() []interface{} {
+	c := p.Contributors
+	if c == nil {
+		c = &ReadinessContributors{}
+	}
+	return []interface{}{
+		p.Score,
+		nullable(c.ActivityBalance), nullable(c.BodyTemperature), nullable(c.HRVBalance), nullable(c.PreviousDayActivity),
+		nullable(c.PreviousNight), nullable(c.RecoveryIndex), nullable(c.RestingHeartRate), nullable(c.SleepBalance),
+		nullableFloat(p.TemperatureDeviation), nullableFloat(p.TemperatureTrendDeviation),
+	}
+}
@@ -0,0 +1,157 @@
+package ingest
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Payload is implemented by every payload schema.
+type Payload interface {
+	// Document returns the upstream document's ID and day (YYYY-MM-DD).
+	Document() (id, day string)
+	// Values returns the payload's column values, in the order of its
+	// MetricType's Columns.
+	Values() []interface{}
+}
+
+// SeriesPayload is implemented by the payloads of Series types, which carry
+// many timestamped samples rather than one row of values.
+type SeriesPayload interface {
+	Payload
+	// Rows returns each sample's column values, in the order of its
+	// MetricType's Columns.
+	Rows() [][]interface{}
+}
+
+// MetricType declares everything the services need to know about one metric
+// type: its payload schemas, where it comes from upstream, and how it is
+// stored and read back. Each type registers itself from its own file, so
+// adding one does not touch ingest, retrieval or the api-service.
+type MetricType struct {
+	Name string
+
+	// Versions constructs an empty payload for each supported schema
+	// version. Payloads are validated with their `validate` struct tags.
+	Versions map[int]func() Payload
+
+	// OuraEndpoint is the Oura usercollection endpoint the type is fetched
+	// from. Documents decode into the CurrentVersion payload.
+	OuraEndpoint string
+
+	// Table stores one row per upstream document, keyed by (user_id,
+	// oura_id), with a day column and Columns.
+	Table string
+	// Columns are the table's value columns, which are also their field
+	// names when read back.
+	Columns []string
+
+	// Series marks a time series. Its payloads implement SeriesPayload and
+	// Table instead stores one row per sample, keyed by (user_id, Columns[0])
+	// where the first column is the sample's timestamp, in monthly
+	// partitions. Its Oura endpoint is queried by datetime and returns bare
+	// samples, which the collector groups into one document per day.
+	Series bool
+
+	// Trend names the column of a slow-moving marker, such as VO2 max, that
+	// is summarised by month rather than read day by day.
+	Trend string
+}
+
+var registry = map[string]*MetricType{}
+
+// Register makes a metric type available. It panics if the type is
+// registered twice or is incomplete, as that is a programming error.
+func Register(t *MetricType) {
+	if _, ok := registry[t.Name]; ok {
+		panic(fmt.Sprintf("ingest: metric type %q registered twice", t.Name))
+	}
+	if t.Versions[CurrentVersion] == nil || t.Table == "" {
+		panic(fmt.Sprintf("ingest: metric type %q needs a current schema version and a table", t.Name))
+	}
+	if _, ok := t.Versions[CurrentVersion]().(SeriesPayload); ok != t.Series {
+		panic(fmt.Sprintf("ingest: metric type %q must be a series exactly when its payload is a SeriesPayload", t.Name))
+	}
+	if t.Trend != "" && !contains(t.Columns, t.Trend) {
+		panic(fmt.Sprintf("ingest: metric type %q trends unknown column %q", t.Name, t.Trend))
+	}
+	registry[t.Name] = t
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the registered metric type with the given name.
+func Lookup(name string) (*MetricType, bool) {
+	t, ok := registry[name]
+	return t, ok
+}
+
+// Registered returns every registered metric type, sorted by name.
+func Registered() []*MetricType {
+	types := make([]*MetricType, 0, len(registry))
+	for _, name := range Types() {
+		types = append(types, registry[name])
+	}
+	return types
+}
+
+// Types returns the supported metric types in sorted order.
+func Types() []string {
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// SupportedVersions returns the schema versions accepted for a metric type.
+func SupportedVersions(dataType string) []int {
+	var versions []int
+	if t, ok := registry[dataType]; ok {
+		for v := range t.Versions {
+			versions = append(versions, v)
+		}
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Records returns the registered metric types that are stored one row per
+// upstream document, i.e. that are not a Series, sorted by name.
+func Records() []*MetricType {
+	var types []*MetricType
+	for _, t := range Registered() {
+		if !t.Series {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// Trends returns the registered metric types with a Trend, sorted by name.
+func Trends() []*MetricType {
+	var types []*MetricType
+	for _, t := range Registered() {
+		if t.Trend != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// Row maps a payload's values onto the type's columns.
+func (t *MetricType) Row(p Payload) map[string]interface{} {
+	values := p.Values()
+	row := make(map[string]interface{}, len(t.Columns))
+	for i, col := range t.Columns {
+		row[col] = values[i]
+	}
+	return row
+}
@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegisteredTypes(t *testing.T) {
+	want := []string{
+		TypeActivity, TypeCardiovascularAge, TypeHeartRate, TypeReadiness, TypeResilience,
+		TypeRestMode, TypeSession, TypeSleep, TypeSleepPeriod, TypeSpO2, TypeStress, TypeTag, TypeVO2Max, TypeWorkout,
+	}
+	if got := Types(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected types %v, got %v", want, got)
+	}
+
+	for _, mt := range Records() {
+		payload := mt.Versions[CurrentVersion]()
+		if got := len(payload.Values()); got != len(mt.Columns) {
+			t.Errorf("%s: payload has %d values for %d columns", mt.Name, got, len(mt.Columns))
+		}
+	}
+}
+
+func TestSeriesSamples(t *testing.T) {
+	mt, ok := Lookup(TypeHeartRate)
+	if !ok || !mt.Series {
+		t.Fatal("Expected heartrate to be registered as a series")
+	}
+	for _, r := range Records() {
+		if r.Series {
+			t.Errorf("Expected Records to leave out series type %s", r.Name)
+		}
+	}
+
+	payload := &HeartRateV1{Day: "2024-01-10", Samples: []HeartRateSample{
+		{Timestamp: "2024-01-10T08:00:00+02:00", BPM: 61, Source: "awake"},
+	}}
+	samples := payload.Rows()
+	if len(samples) != 1 || len(samples[0]) != len(mt.Columns) {
+		t.Fatalf("Expected one sample with %d values, got %v", len(mt.Columns), samples)
+	}
+	if want := time.Date(2024, 1, 10, 6, 0, 0, 0, time.UTC); samples[0][0] != want {
+		t.Errorf("Expected timestamp %v, got %v", want, samples[0][0])
+	}
+
+	if _, err := New(TypeHeartRate, testUserID, SourceOura, &HeartRateV1{Day: "2024-01-10"}); err == nil {
+		t.Error("Expected a payload without samples to be rejected")
+	}
+}
+
+func TestRow(t *testing.T) {
+	mt, ok := Lookup(TypeSleep)
+	if !ok {
+		t.Fatal("Expected sleep to be registered")
+	}
+
+	row := mt.Row(&SleepV1{ID: "s1", Day: "2024-01-01", Score: 80, Duration: 28800})
+	want := map[string]interface{}{"score": 80, "duration": 28800}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("Expected row %v, got %v", want, row)
+	}
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a type twice to panic")
+		}
+	}()
+	Register(&MetricType{
+		Name:     TypeSleep,
+		Versions: map[int]func() Payload{1: func() Payload { return &SleepV1{} }},
+		Table:    "sleep_metrics",
+	})
+}
+
+func TestTrends(t *testing.T) {
+	var got []string
+	for _, mt := range Trends() {
+		got = append(got, mt.Name)
+	}
+	if want := []string{TypeCardiovascularAge, TypeVO2Max}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected trend types %v, got %v", want, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected trending an unknown column to panic")
+		}
+	}()
+	Register(&MetricType{
+		Name:     "trend_test",
+		Versions: map[int]func() Payload{1: func() Payload { return &SleepV1{} }},
+		Table:    "trend_test",
+		Columns:  []string{"score", "duration"},
+		Trend:    "vo2_max",
+	})
+}
@@ -0,0 +1,37 @@
+package ingest
+
+// TypeResilience is Oura's daily resilience summary: how well the user is
+// recovering from stress.
+const TypeResilience = "resilience"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeResilience,
+		Versions:     map[int]func() Payload{1: func() Payload { return &ResilienceV1{} }},
+		OuraEndpoint: "daily_resilience",
+		Table:        "resilience_metrics",
+		Columns:      []string{"level", "sleep_recovery", "daytime_recovery", "stress"},
+	})
+}
+
+// ResilienceV1 is version 1 of the resilience payload. Level is limited,
+// adequate, solid, strong or exceptional.
+type ResilienceV1 struct {
+	ID           string                 `json:"id" validate:"required"`
+	Day          string                 `json:"day" validate:"required,datetime=2006-01-02"`
+	Level        string                 `json:"level" validate:"required,oneof=limited adequate solid strong exceptional"`
+	Contributors ResilienceContributors `json:"contributors"`
+}
+
+// ResilienceContributors are the 0-100 scores resilience is derived from.
+type ResilienceContributors struct {
+	SleepRecovery   float64 `json:"sleep_recovery" validate:"min=0,max=100"`
+	DaytimeRecovery float64 `json:"daytime_recovery" validate:"min=0,max=100"`
+	Stress          float64 `json:"stress" validate:"min=0,max=100"`
+}
+
+func (p *ResilienceV1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *ResilienceV1) Values() []interface{} {
+	return []interface{}{p.Level, p.Contributors.SleepRecovery, p.Contributors.DaytimeRecovery, p.Contributors.Stress}
+}
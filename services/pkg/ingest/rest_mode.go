@@ -0,0 +1,32 @@
+package ingest
+
+// TypeRestMode is a period the user put their Oura ring in rest mode, e.g.
+// while ill, during which Oura pauses activity goals.
+const TypeRestMode = "rest_mode"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeRestMode,
+		Versions:     map[int]func() Payload{1: func() Payload { return &RestModeV1{} }},
+		OuraEndpoint: "rest_mode_period",
+		Table:        "rest_mode_periods",
+		Columns:      []string{"start_time", "end_time", "end_day"},
+	})
+}
+
+// RestModeV1 is version 1 of the rest mode payload. A period without an end
+// is still ongoing.
+type RestModeV1 struct {
+	ID        string `json:"id" validate:"required"`
+	StartDay  string `json:"start_day" validate:"required,datetime=2006-01-02"`
+	EndDay    string `json:"end_day" validate:"omitempty,datetime=2006-01-02"`
+	StartTime string `json:"start_time" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	EndTime   string `json:"end_time" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// Document identifies the payload by its ID and the day it started.
+func (p *RestModeV1) Document() (string, string) { return p.ID, p.StartDay }
+
+func (p *RestModeV1) Values() []interface{} {
+	return []interface{}{optionalTimestamp(p.StartTime), optionalTimestamp(p.EndTime), optionalDate(p.EndDay)}
+}
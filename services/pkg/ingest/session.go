@@ -0,0 +1,78 @@
+package ingest
+
+// TypeSession is a guided or unguided session recorded with Oura, such as
+// breathing or meditation.
+const TypeSession = "session"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeSession,
+		Versions:     map[int]func() Payload{1: func() Payload { return &SessionV1{} }},
+		OuraEndpoint: "session",
+		Table:        "sessions",
+		Columns: []string{
+			"type", "mood", "start_datetime", "end_datetime",
+			"heart_rate_average", "heart_rate_min", "heart_rate_max", "hrv_average",
+		},
+	})
+}
+
+// SessionV1 is version 1 of the session payload. Type is e.g. breathing,
+// meditation, nap, relaxation or rest. The heart rate and HRV samples are
+// stored as a summary.
+type SessionV1 struct {
+	ID                   string        `json:"id" validate:"required"`
+	Day                  string        `json:"day" validate:"required,datetime=2006-01-02"`
+	Type                 string        `json:"type" validate:"required,max=50"`
+	Mood                 string        `json:"mood,omitempty" validate:"max=20"`
+	StartDatetime        string        `json:"start_datetime" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	EndDatetime          string        `json:"end_datetime" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	HeartRate            *SampleSeries `json:"heart_rate,omitempty"`
+	HeartRateVariability *SampleSeries `json:"heart_rate_variability,omitempty"`
+}
+
+// SampleSeries is Oura's sample model: readings taken every Interval
+// seconds from Timestamp, with null for intervals without a reading.
+type SampleSeries struct {
+	Interval  float64    `json:"interval" validate:"gt=0"`
+	Items     []*float64 `json:"items"`
+	Timestamp string     `json:"timestamp" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// stats returns the mean, minimum and maximum of the readings, or nils if
+// there are none.
+func (s *SampleSeries) stats() (avg, min, max interface{}) {
+	if s == nil {
+		return nil, nil, nil
+	}
+	var sum, lo, hi float64
+	var n int
+	for _, v := range s.Items {
+		if v == nil {
+			continue
+		}
+		if n == 0 || *v < lo {
+			lo = *v
+		}
+		if n == 0 || *v > hi {
+			hi = *v
+		}
+		sum += *v
+		n++
+	}
+	if n == 0 {
+		return nil, nil, nil
+	}
+	return sum / float64(n), lo, hi
+}
+
+func (p *SessionV1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *SessionV1) Values() []interface{} {
+	hrAvg, hrMin, hrMax := p.HeartRate.stats()
+	hrvAvg, _, _ := p.HeartRateVariability.stats()
+	return []interface{}{
+		p.Type, optionalString(p.Mood), timestamp(p.StartDatetime), timestamp(p.EndDatetime),
+		hrAvg, hrMin, hrMax, hrvAvg,
+	}
+}
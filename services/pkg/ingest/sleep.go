@@ -0,0 +1,26 @@
+package ingest
+
+// TypeSleep is Oura's daily sleep summary.
+const TypeSleep = "sleep"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeSleep,
+		Versions:     map[int]func() Payload{1: func() Payload { return &SleepV1{} }},
+		OuraEndpoint: "daily_sleep",
+		Table:        "sleep_metrics",
+		Columns:      []string{"score", "duration"},
+	})
+}
+
+// SleepV1 is version 1 of the sleep payload.
+type SleepV1 struct {
+	ID       string `json:"id" validate:"required"`
+	Day      string `json:"day" validate:"required,datetime=2006-01-02"`
+	Score    int    `json:"score" validate:"min=0,max=100"`
+	Duration int    `json:"duration" validate:"min=0"`
+}
+
+func (p *SleepV1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *SleepV1) Values() []interface{} { return []interface{}{p.Score, p.Duration} }
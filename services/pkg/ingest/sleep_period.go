@@ -0,0 +1,51 @@
+package ingest
+
+// TypeSleepPeriod is a single Oura sleep period, such as a night's sleep or
+// a nap. A day can have several; the one it belongs to is the day it ended.
+const TypeSleepPeriod = "sleep_period"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeSleepPeriod,
+		Versions:     map[int]func() Payload{1: func() Payload { return &SleepPeriodV1{} }},
+		OuraEndpoint: "sleep",
+		Table:        "sleep_periods",
+		Columns: []string{
+			"type", "bedtime_start", "bedtime_end",
+			"deep_sleep_duration", "light_sleep_duration", "rem_sleep_duration", "awake_time",
+			"efficiency", "latency", "lowest_heart_rate", "average_hrv",
+			"sleep_phase_5_min",
+		},
+	})
+}
+
+// SleepPeriodV1 is version 1 of the sleep period payload. Durations are in
+// seconds. SleepPhase5Min holds one character per 5 minutes from
+// BedtimeStart: 1 deep, 2 light, 3 REM, 4 awake.
+type SleepPeriodV1 struct {
+	ID                 string `json:"id" validate:"required"`
+	Day                string `json:"day" validate:"required,datetime=2006-01-02"`
+	Type               string `json:"type" validate:"max=50"`
+	BedtimeStart       string `json:"bedtime_start" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	BedtimeEnd         string `json:"bedtime_end" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	DeepSleepDuration  *int   `json:"deep_sleep_duration" validate:"omitempty,min=0"`
+	LightSleepDuration *int   `json:"light_sleep_duration" validate:"omitempty,min=0"`
+	REMSleepDuration   *int   `json:"rem_sleep_duration" validate:"omitempty,min=0"`
+	AwakeTime          *int   `json:"awake_time" validate:"omitempty,min=0"`
+	Efficiency         *int   `json:"efficiency" validate:"omitempty,min=0,max=100"`
+	Latency            *int   `json:"latency" validate:"omitempty,min=0"`
+	LowestHeartRate    *int   `json:"lowest_heart_rate" validate:"omitempty,min=0"`
+	AverageHRV         *int   `json:"average_hrv" validate:"omitempty,min=0"`
+	SleepPhase5Min     string `json:"sleep_phase_5_min" validate:"omitempty,numeric"`
+}
+
+func (p *SleepPeriodV1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *SleepPeriodV1) Values() []interface{} {
+	return []interface{}{
+		p.Type, timestamp(p.BedtimeStart), timestamp(p.BedtimeEnd),
+		nullable(p.DeepSleepDuration), nullable(p.LightSleepDuration), nullable(p.REMSleepDuration), nullable(p.AwakeTime),
+		nullable(p.Efficiency), nullable(p.Latency), nullable(p.LowestHeartRate), nullable(p.AverageHRV),
+		p.SleepPhase5Min,
+	}
+}
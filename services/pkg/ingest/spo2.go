@@ -0,0 +1,38 @@
+package ingest
+
+// TypeSpO2 is Oura's daily blood oxygen summary, measured during sleep.
+const TypeSpO2 = "spo2"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeSpO2,
+		Versions:     map[int]func() Payload{1: func() Payload { return &SpO2V1{} }},
+		OuraEndpoint: "daily_spo2",
+		Table:        "spo2_metrics",
+		Columns:      []string{"spo2_average", "breathing_disturbance_index"},
+	})
+}
+
+// SpO2V1 is version 1 of the SpO2 payload. Oura leaves both values null on
+// nights it could not measure.
+type SpO2V1 struct {
+	ID                        string          `json:"id" validate:"required"`
+	Day                       string          `json:"day" validate:"required,datetime=2006-01-02"`
+	SpO2Percentage            *SpO2Percentage `json:"spo2_percentage"`
+	BreathingDisturbanceIndex *int            `json:"breathing_disturbance_index" validate:"omitempty,min=0"`
+}
+
+// SpO2Percentage is the average blood oxygen saturation over the night.
+type SpO2Percentage struct {
+	Average float64 `json:"average" validate:"min=0,max=100"`
+}
+
+func (p *SpO2V1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *SpO2V1) Values() []interface{} {
+	var average interface{}
+	if p.SpO2Percentage != nil {
+		average = p.SpO2Percentage.Average
+	}
+	return []interface{}{average, nullable(p.BreathingDisturbanceIndex)}
+}
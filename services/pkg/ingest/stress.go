@@ -0,0 +1,31 @@
+package ingest
+
+// TypeStress is Oura's daily stress summary.
+const TypeStress = "stress"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeStress,
+		Versions:     map[int]func() Payload{1: func() Payload { return &StressV1{} }},
+		OuraEndpoint: "daily_stress",
+		Table:        "stress_metrics",
+		Columns:      []string{"stress_high", "recovery_high", "day_summary"},
+	})
+}
+
+// StressV1 is version 1 of the stress payload. StressHigh and RecoveryHigh
+// are the seconds spent in high stress and high recovery; DaySummary is
+// restored, normal or stressful.
+type StressV1 struct {
+	ID           string `json:"id" validate:"required"`
+	Day          string `json:"day" validate:"required,datetime=2006-01-02"`
+	StressHigh   *int   `json:"stress_high" validate:"omitempty,min=0"`
+	RecoveryHigh *int   `json:"recovery_high" validate:"omitempty,min=0"`
+	DaySummary   string `json:"day_summary" validate:"max=20"`
+}
+
+func (p *StressV1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *StressV1) Values() []interface{} {
+	return []interface{}{nullable(p.StressHigh), nullable(p.RecoveryHigh), optionalString(p.DaySummary)}
+}
@@ -0,0 +1,50 @@
+package ingest
+
+// TypeTag is an Oura enhanced tag: something the user noted, such as
+// alcohol, caffeine, a late meal or sickness, or a custom tag, over a period
+// that may span several days.
+const TypeTag = "tag"
+
+// TagLags lists the types whose history can be filtered by tag, with how
+// many days after a tagged day their record is the one the tag affects. A
+// night's sleep and the readiness that follows it fall on the next day; a
+// day's activity on the same day.
+var TagLags = map[string]int{
+	TypeSleep:     1,
+	TypeReadiness: 1,
+	TypeActivity:  0,
+}
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeTag,
+		Versions:     map[int]func() Payload{1: func() Payload { return &TagV1{} }},
+		OuraEndpoint: "enhanced_tag",
+		Table:        "tags",
+		Columns:      []string{"tag_type_code", "custom_name", "comment", "start_time", "end_time", "end_day"},
+	})
+}
+
+// TagV1 is version 1 of the tag payload. TagTypeCode is e.g.
+// tag_generic_alcohol, or custom with CustomName set. A tag without an end
+// covers only its start.
+type TagV1 struct {
+	ID          string `json:"id" validate:"required"`
+	TagTypeCode string `json:"tag_type_code" validate:"max=100"`
+	CustomName  string `json:"custom_name" validate:"max=255"`
+	Comment     string `json:"comment" validate:"max=1000"`
+	StartTime   string `json:"start_time" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	EndTime     string `json:"end_time" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	StartDay    string `json:"start_day" validate:"required,datetime=2006-01-02"`
+	EndDay      string `json:"end_day" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// Document identifies the payload by its ID and the day it started.
+func (p *TagV1) Document() (string, string) { return p.ID, p.StartDay }
+
+func (p *TagV1) Values() []interface{} {
+	return []interface{}{
+		optionalString(p.TagTypeCode), optionalString(p.CustomName), optionalString(p.Comment),
+		timestamp(p.StartTime), optionalTimestamp(p.EndTime), optionalDate(p.EndDay),
+	}
+}
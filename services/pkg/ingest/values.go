@@ -0,0 +1,54 @@
+package ingest
+
+import "time"
+
+// Helpers for converting payload fields into column values.
+
+// timestamp parses a validated RFC 3339 value, normalised to UTC.
+func timestamp(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t.UTC()
+}
+
+// optionalTimestamp is timestamp for an optional value, or nil if it is
+// empty.
+func optionalTimestamp(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return timestamp(s)
+}
+
+// optionalDate parses a validated YYYY-MM-DD value, or returns nil if it is
+// empty.
+func optionalDate(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+// optionalString returns s, or nil if it is empty, so it is stored as NULL.
+func optionalString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullable returns v's value, or nil if it is unset, so it is stored as NULL.
+func nullable(v *int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// nullableFloat returns v's value, or nil if it is unset.
+func nullableFloat(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -0,0 +1,27 @@
+package ingest
+
+// TypeVO2Max is Oura's VO2 max estimate, which changes over weeks rather
+// than days.
+const TypeVO2Max = "vo2_max"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeVO2Max,
+		Versions:     map[int]func() Payload{1: func() Payload { return &VO2MaxV1{} }},
+		OuraEndpoint: "vO2_max",
+		Table:        "vo2_max_metrics",
+		Columns:      []string{"vo2_max"},
+		Trend:        "vo2_max",
+	})
+}
+
+// VO2MaxV1 is version 1 of the VO2 max payload, in ml/kg/min.
+type VO2MaxV1 struct {
+	ID     string   `json:"id" validate:"required"`
+	Day    string   `json:"day" validate:"required,datetime=2006-01-02"`
+	VO2Max *float64 `json:"vo2_max" validate:"omitempty,gt=0,max=100"`
+}
+
+func (p *VO2MaxV1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *VO2MaxV1) Values() []interface{} { return []interface{}{nullableFloat(p.VO2Max)} }
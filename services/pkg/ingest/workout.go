@@ -0,0 +1,42 @@
+package ingest
+
+// TypeWorkout is a workout logged or detected by Oura.
+const TypeWorkout = "workout"
+
+func init() {
+	Register(&MetricType{
+		Name:         TypeWorkout,
+		Versions:     map[int]func() Payload{1: func() Payload { return &WorkoutV1{} }},
+		OuraEndpoint: "workout",
+		Table:        "workouts",
+		Columns: []string{
+			"activity", "intensity", "label", "source",
+			"start_datetime", "end_datetime", "calories", "distance",
+		},
+	})
+}
+
+// WorkoutV1 is version 1 of the workout payload. Intensity is easy, moderate
+// or hard; Source says how it was recorded, e.g. manual or autodetected.
+// Calories are kcal and Distance is in meters.
+type WorkoutV1 struct {
+	ID            string   `json:"id" validate:"required"`
+	Day           string   `json:"day" validate:"required,datetime=2006-01-02"`
+	Activity      string   `json:"activity" validate:"required,max=100"`
+	Intensity     string   `json:"intensity" validate:"max=20"`
+	Label         string   `json:"label,omitempty" validate:"max=255"`
+	Source        string   `json:"source" validate:"max=50"`
+	StartDatetime string   `json:"start_datetime" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	EndDatetime   string   `json:"end_datetime" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	Calories      *float64 `json:"calories" validate:"omitempty,min=0"`
+	Distance      *float64 `json:"distance" validate:"omitempty,min=0"`
+}
+
+func (p *WorkoutV1) Document() (string, string) { return p.ID, p.Day }
+
+func (p *WorkoutV1) Values() []interface{} {
+	return []interface{}{
+		p.Activity, p.Intensity, optionalString(p.Label), p.Source,
+		timestamp(p.StartDatetime), timestamp(p.EndDatetime), nullableFloat(p.Calories), nullableFloat(p.Distance),
+	}
+}
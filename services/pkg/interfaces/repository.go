@@ -24,28 +24,26 @@ type OAuthRepository interface {
 	GetToken(ctx context.Context, userID, provider string) (*OAuthToken, error)
 	UpdateToken(ctx context.Context, token *OAuthToken) error
 	DeleteToken(ctx context.Context, userID, provider string) error
-	RefreshToken(ctx context.Context, userID, provider string, newAccessToken, newRefreshToken string, expiresAt time.Time) error
 }
 
 // MetricsRepository defines operations for health metrics
 type MetricsRepository interface {
-	// Sleep metrics
-	SaveSleepMetric(ctx context.Context, metric *SleepMetric) error
-	GetSleepMetrics(ctx context.Context, userID string, startDate, endDate time.Time) ([]*SleepMetric, error)
-	GetSleepMetricByID(ctx context.Context, metricID string) (*SleepMetric, error)
+	// Metrics of any type registered with pkg/ingest, by type name
+	SaveMetric(ctx context.Context, metricType string, metric *Metric) error
+	GetMetrics(ctx context.Context, metricType, userID string, startDate, endDate time.Time) ([]*Metric, error)
+	GetMetricByOuraID(ctx context.Context, metricType, userID, ouraID string) (*Metric, error)
+	// Records of a type in ingest.TagLags, narrowed by the tags affecting them
+	GetMetricsByTag(ctx context.Context, metricType, userID string, startDate, endDate time.Time, filter TagFilter) ([]*Metric, error)
 
-	// Activity metrics
-	SaveActivityMetric(ctx context.Context, metric *ActivityMetric) error
-	GetActivityMetrics(ctx context.Context, userID string, startDate, endDate time.Time) ([]*ActivityMetric, error)
-	GetActivityMetricByID(ctx context.Context, metricID string) (*ActivityMetric, error)
+	// Heart rate samples taken in [start, end), oldest first
+	GetHeartRate(ctx context.Context, userID string, start, end time.Time) ([]*HeartRateSample, error)
 
-	// Readiness metrics
-	SaveReadinessMetric(ctx context.Context, metric *ReadinessMetric) error
-	GetReadinessMetrics(ctx context.Context, userID string, startDate, endDate time.Time) ([]*ReadinessMetric, error)
-	GetReadinessMetricByID(ctx context.Context, metricID string) (*ReadinessMetric, error)
+	// Monthly aggregates of a trend type's Trend column over the days in
+	// [start, end], oldest first
+	GetMonthlyTrend(ctx context.Context, metricType, userID string, start, end time.Time) ([]*MonthlyAggregate, error)
 
 	// Dashboard aggregations
-	GetDashboardSummary(ctx context.Context, userID string, days int) (*DashboardSummary, error)
+	GetDashboardSummary(ctx context.Context, userID string, days int, includeFlagged bool) (*DashboardSummary, error)
 }
 
 // User represents a user entity
@@ -74,42 +72,52 @@ type OAuthToken struct {
 	UpdatedAt    time.Time
 }
 
-// SleepMetric represents sleep data
-type SleepMetric struct {
-	ID        string
-	UserID    string
-	OuraID    string
-	Day       time.Time
-	Score     int
-	Duration  int
-	CreatedAt time.Time
-	UpdatedAt time.Time
+// HeartRateSample represents a single heart rate reading, with its UTC
+// day's rest mode and low confidence flags
+type HeartRateSample struct {
+	Timestamp     time.Time
+	BPM           int
+	Source        string
+	RestMode      bool
+	LowConfidence bool
+}
+
+// TagFilter narrows a history to the records a tag affected or, if Without
+// is set, to those it did not. Tag matches a tag type code with or without
+// its tag_generic_ prefix, e.g. alcohol, or a custom tag's name, ignoring
+// case.
+type TagFilter struct {
+	Tag     string
+	Without bool
 }
 
-// ActivityMetric represents activity data
-type ActivityMetric struct {
-	ID                string
-	UserID            string
-	OuraID            string
-	Day               time.Time
-	Score             int
-	ActiveCalories    int
-	Steps             int
-	MediumActivityMin int
-	HighActivityMin   int
-	CreatedAt         time.Time
-	UpdatedAt         time.Time
+// MonthlyAggregate summarises a slow-moving marker's readings in one month.
+// RestMode and LowConfidence are set if any reading's day was flagged.
+type MonthlyAggregate struct {
+	Month         time.Time
+	Average       float64
+	Min           float64
+	Max           float64
+	Samples       int
+	RestMode      bool
+	LowConfidence bool
 }
 
-// ReadinessMetric represents readiness data
-type ReadinessMetric struct {
-	ID        string
-	UserID    string
-	OuraID    string
-	Day       time.Time
-	Score     int
+// Metric represents one day's record of any metric type
+type Metric struct {
+	ID     string
+	UserID string
+	OuraID string
+	Day    time.Time
+	// Values holds the type's value columns by name
+	Values    map[string]interface{}
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// RestMode is set if the day fell in a rest mode period and
+	// LowConfidence if the ring was off for much of it
+	RestMode      bool
+	LowConfidence bool
 }
 
 // DashboardSummary aggregates metrics for dashboard display
@@ -120,4 +128,17 @@ type DashboardSummary struct {
 	AvgReadinessScore float64
 	TotalSteps        int
 	AvgSleepDuration  float64
+
+	AvgSpO2                      float64
+	AvgBreathingDisturbanceIndex float64
+	AvgStressHigh                float64
+	AvgRecoveryHigh              float64
+	AvgResilienceSleepRecovery   float64
+	AvgResilienceDaytimeRecovery float64
+	AvgResilienceStress          float64
+
+	WeakestReadinessContributor      string
+	WeakestReadinessContributorScore float64
+
+	ExcludedDays int
 }
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 )
 
@@ -20,22 +21,24 @@ type OAuthService interface {
 	// OAuth flow
 	GenerateAuthURL(ctx context.Context, userID string) (string, error)
 	HandleCallback(ctx context.Context, code, state string) (*OAuthResult, error)
-	RefreshAccessToken(ctx context.Context, userID, provider string) error
 	RevokeToken(ctx context.Context, userID, provider string) error
 }
 
 // MetricsService defines business logic for health metrics
 type MetricsService interface {
-	// Data ingestion
-	IngestSleepData(ctx context.Context, userID string, data *SleepDataDTO) error
-	IngestActivityData(ctx context.Context, userID string, data *ActivityDataDTO) error
-	IngestReadinessData(ctx context.Context, userID string, data *ReadinessDataDTO) error
+	// Data ingestion, validated against the type's current payload schema
+	IngestMetric(ctx context.Context, userID, metricType string, payload json.RawMessage) error
 
 	// Data retrieval
-	GetSleepHistory(ctx context.Context, userID string, startDate, endDate time.Time) ([]*SleepDataDTO, error)
-	GetActivityHistory(ctx context.Context, userID string, startDate, endDate time.Time) ([]*ActivityDataDTO, error)
-	GetReadinessHistory(ctx context.Context, userID string, startDate, endDate time.Time) ([]*ReadinessDataDTO, error)
-	GetDashboard(ctx context.Context, userID string, days int) (*DashboardDTO, error)
+	GetHistory(ctx context.Context, userID, metricType string, startDate, endDate time.Time) ([]*MetricDTO, error)
+	GetTaggedHistory(ctx context.Context, userID, metricType string, startDate, endDate time.Time, filter TagFilter) ([]*MetricDTO, error)
+	GetRecord(ctx context.Context, userID, metricType, ouraID string) (*MetricDTO, error)
+	GetDashboard(ctx context.Context, userID string, days int, includeFlagged bool) (*DashboardDTO, error)
+	GetSleepPeriods(ctx context.Context, userID string, day time.Time) ([]*SleepPeriodDTO, error)
+	GetHeartRate(ctx context.Context, userID string, startDate, endDate time.Time, points int) ([]*HeartRateDTO, error)
+	GetActivityTimeline(ctx context.Context, userID string, day time.Time) (*ActivityTimelineDTO, error)
+	GetReadinessBreakdown(ctx context.Context, userID string, day time.Time) (*ReadinessBreakdownDTO, error)
+	GetTrend(ctx context.Context, userID, metricType string, months int) (*TrendDTO, error)
 }
 
 // OuraClient defines operations for interacting with Oura API
@@ -92,27 +95,152 @@ type OAuthResult struct {
 	ExpiresAt   time.Time
 }
 
-type SleepDataDTO struct {
-	OuraID   string    `json:"oura_id"`
-	Day      time.Time `json:"day"`
-	Score    int       `json:"score"`
-	Duration int       `json:"duration"`
+// MetricDTO is one day's record of any metric type. Values are flattened
+// into the JSON object alongside oura_id, day and the rest_mode and
+// low_confidence flags, which mark days the ring was in rest mode or off for
+// much of the day.
+type MetricDTO struct {
+	OuraID        string
+	Day           time.Time
+	Values        map[string]interface{}
+	RestMode      bool
+	LowConfidence bool
 }
 
-type ActivityDataDTO struct {
-	OuraID            string    `json:"oura_id"`
-	Day               time.Time `json:"day"`
-	Score             int       `json:"score"`
-	ActiveCalories    int       `json:"active_calories"`
-	Steps             int       `json:"steps"`
-	MediumActivityMin int       `json:"medium_activity_minutes"`
-	HighActivityMin   int       `json:"high_activity_minutes"`
+func (m *MetricDTO) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.fields(0))
 }
 
-type ReadinessDataDTO struct {
-	OuraID string    `json:"oura_id"`
-	Day    time.Time `json:"day"`
-	Score  int       `json:"score"`
+// fields flattens the record into a JSON object, sized for extra fields the
+// caller adds
+func (m *MetricDTO) fields(extra int) map[string]interface{} {
+	fields := make(map[string]interface{}, len(m.Values)+4+extra)
+	for name, v := range m.Values {
+		fields[name] = v
+	}
+	fields["oura_id"] = m.OuraID
+	fields["day"] = m.Day
+	fields["rest_mode"] = m.RestMode
+	fields["low_confidence"] = m.LowConfidence
+	return fields
+}
+
+// SleepPeriodDTO is one sleep period with its sleep_phase_5_min string
+// decoded into a hypnogram.
+type SleepPeriodDTO struct {
+	*MetricDTO
+	Hypnogram []HypnogramSegment
+}
+
+func (p *SleepPeriodDTO) MarshalJSON() ([]byte, error) {
+	fields := p.MetricDTO.fields(1)
+	fields["hypnogram"] = p.Hypnogram
+	return json.Marshal(fields)
+}
+
+// HypnogramSegment is a stretch of time spent in one sleep stage: deep,
+// light, rem or awake.
+type HypnogramSegment struct {
+	Stage    string    `json:"stage"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration int       `json:"duration"` // seconds
+}
+
+// ActivityTimelineDTO is one day's activity over time: intensity segments
+// decoded from class_5_min, starting at Start, and the MET readings, with the
+// day's rest_mode and low_confidence flags
+type ActivityTimelineDTO struct {
+	OuraID        string             `json:"oura_id"`
+	Day           time.Time          `json:"day"`
+	Start         *time.Time         `json:"start"`
+	Intensity     []IntensitySegment `json:"intensity"`
+	MET           []METSample        `json:"met"`
+	RestMode      bool               `json:"rest_mode"`
+	LowConfidence bool               `json:"low_confidence"`
+}
+
+// ReadinessBreakdownDTO explains one day's readiness score. Contributors are
+// ordered weakest first, with those Oura could not score last, and Weakest
+// names the lowest scored. Temperature deviations are in degrees Celsius
+// from the user's baseline. RestMode and LowConfidence flag the day as for
+// MetricDTO.
+type ReadinessBreakdownDTO struct {
+	OuraID                    string                    `json:"oura_id"`
+	Day                       time.Time                 `json:"day"`
+	Score                     int                       `json:"score"`
+	Contributors              []ReadinessContributorDTO `json:"contributors"`
+	Weakest                   string                    `json:"weakest,omitempty"`
+	TemperatureDeviation      *float64                  `json:"temperature_deviation"`
+	TemperatureTrendDeviation *float64                  `json:"temperature_trend_deviation"`
+	RestMode                  bool                      `json:"rest_mode"`
+	LowConfidence             bool                      `json:"low_confidence"`
+}
+
+// ReadinessContributorDTO is one contributor's 1-100 score, or null if Oura
+// could not score it
+type ReadinessContributorDTO struct {
+	Name  string `json:"name"`
+	Score *int   `json:"score"`
+}
+
+// IntensitySegment is a stretch of time spent at one activity intensity:
+// non_wear, rest, inactive, low, medium or high.
+type IntensitySegment struct {
+	Intensity string    `json:"intensity"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Duration  int       `json:"duration"` // seconds
+}
+
+// METSample is one metabolic equivalent reading
+type METSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	MET       float64   `json:"met"`
+}
+
+// TrendDTO is a slow-moving marker, such as VO2 max, by month. Baseline is
+// the average of the first month with readings and Change how far the
+// latest month's average has moved from it; both are null without readings.
+type TrendDTO struct {
+	Type     string          `json:"type"`
+	Months   []TrendMonthDTO `json:"months"`
+	Baseline *float64        `json:"baseline"`
+	Change   *float64        `json:"change"`
+}
+
+// TrendMonthDTO summarises one month's readings. Month is YYYY-MM.
+// RestMode and LowConfidence are set if any reading's day was flagged.
+type TrendMonthDTO struct {
+	Month               string  `json:"month"`
+	Average             float64 `json:"average"`
+	Min                 float64 `json:"min"`
+	Max                 float64 `json:"max"`
+	Samples             int     `json:"samples"`
+	ChangeSinceBaseline float64 `json:"change_since_baseline"`
+	RestMode            bool    `json:"rest_mode"`
+	LowConfidence       bool    `json:"low_confidence"`
+}
+
+// HeartRateSummaryDTO summarises the heart rate readings over a period.
+// RestMode and LowConfidence are set if any reading's day was flagged.
+type HeartRateSummaryDTO struct {
+	Average       float64 `json:"average"`
+	Min           int     `json:"min"`
+	Max           int     `json:"max"`
+	Samples       int     `json:"samples"`
+	RestMode      bool    `json:"rest_mode"`
+	LowConfidence bool    `json:"low_confidence"`
+}
+
+// HeartRateDTO is one heart rate reading, possibly chosen by downsampling,
+// with its UTC day's rest_mode and low_confidence flags
+type HeartRateDTO struct {
+	Timestamp     time.Time `json:"timestamp"`
+	BPM           int       `json:"bpm"`
+	Source        string    `json:"source,omitempty"`
+	RestMode      bool      `json:"rest_mode"`
+	LowConfidence bool      `json:"low_confidence"`
 }
 
 type OAuthTokenDTO struct {
@@ -124,10 +252,19 @@ type OAuthTokenDTO struct {
 }
 
 type DashboardDTO struct {
-	Summary       *DashboardSummaryDTO `json:"summary"`
-	RecentSleep   []*SleepDataDTO      `json:"recent_sleep"`
-	RecentActivity []*ActivityDataDTO   `json:"recent_activity"`
-	RecentReadiness []*ReadinessDataDTO `json:"recent_readiness"`
+	Summary *DashboardSummaryDTO `json:"summary"`
+	// Recent holds the last week's records by metric type, serialised as
+	// recent_<type>
+	Recent map[string][]*MetricDTO `json:"-"`
+}
+
+func (d *DashboardDTO) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(d.Recent)+1)
+	for metricType, records := range d.Recent {
+		fields["recent_"+metricType] = records
+	}
+	fields["summary"] = d.Summary
+	return json.Marshal(fields)
 }
 
 type DashboardSummaryDTO struct {
@@ -137,6 +274,21 @@ type DashboardSummaryDTO struct {
 	AvgReadinessScore float64 `json:"avg_readiness_score"`
 	TotalSteps        int     `json:"total_steps"`
 	AvgSleepDuration  float64 `json:"avg_sleep_duration_hours"`
+
+	AvgSpO2                      float64 `json:"avg_spo2"`
+	AvgBreathingDisturbanceIndex float64 `json:"avg_breathing_disturbance_index"`
+	AvgStressHigh                float64 `json:"avg_stress_high_hours"`
+	AvgRecoveryHigh              float64 `json:"avg_recovery_high_hours"`
+	AvgResilienceSleepRecovery   float64 `json:"avg_resilience_sleep_recovery"`
+	AvgResilienceDaytimeRecovery float64 `json:"avg_resilience_daytime_recovery"`
+	AvgResilienceStress          float64 `json:"avg_resilience_stress"`
+
+	// The readiness contributor with the lowest average score
+	WeakestReadinessContributor      string  `json:"weakest_readiness_contributor,omitempty"`
+	WeakestReadinessContributorScore float64 `json:"weakest_readiness_contributor_score,omitempty"`
+
+	// Days in rest mode or with low wear left out of the summary
+	ExcludedDays int `json:"excluded_days"`
 }
 
 // Oura API response types
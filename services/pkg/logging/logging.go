@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+// logrusLogger adapts a logrus entry to the interfaces.Logger contract
+// used by the domain services
+type logrusLogger struct {
+	entry *log.Entry
+}
+
+// New wraps a logrus entry so it can be injected into domain services
+func New(entry *log.Entry) interfaces.Logger {
+	return &logrusLogger{entry: entry}
+}
+
+func (l *logrusLogger) Debug(msg string, fields map[string]interface{}) {
+	l.entry.WithFields(fields).Debug(msg)
+}
+
+func (l *logrusLogger) Info(msg string, fields map[string]interface{}) {
+	l.entry.WithFields(fields).Info(msg)
+}
+
+func (l *logrusLogger) Warn(msg string, fields map[string]interface{}) {
+	l.entry.WithFields(fields).Warn(msg)
+}
+
+func (l *logrusLogger) Error(msg string, err error, fields map[string]interface{}) {
+	l.entry.WithFields(fields).WithError(err).Error(msg)
+}
+
+func (l *logrusLogger) Fatal(msg string, err error, fields map[string]interface{}) {
+	l.entry.WithFields(fields).WithError(err).Fatal(msg)
+}
+
+func (l *logrusLogger) WithFields(fields map[string]interface{}) interfaces.Logger {
+	return &logrusLogger{entry: l.entry.WithFields(fields)}
+}
+
+// WithContext attaches the request ID set by middleware.RequestLogger, if any
+func (l *logrusLogger) WithContext(ctx context.Context) interfaces.Logger {
+	entry := l.entry.WithContext(ctx)
+	if requestID := ctx.Value("request-id"); requestID != nil {
+		entry = entry.WithField("request_id", requestID)
+	}
+	return &logrusLogger{entry: entry}
+}
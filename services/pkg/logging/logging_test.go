@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newTestLogger() (*bytes.Buffer, *log.Entry) {
+	var buf bytes.Buffer
+	l := log.New()
+	l.SetOutput(&buf)
+	l.SetFormatter(&log.JSONFormatter{})
+	return &buf, log.NewEntry(l)
+}
+
+func TestLogger_FieldsAndError(t *testing.T) {
+	buf, entry := newTestLogger()
+	logger := New(entry)
+
+	logger.Error("save failed", errors.New("boom"), map[string]interface{}{"user_id": "user-123"})
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected JSON log line, got %q", buf.String())
+	}
+
+	if line["msg"] != "save failed" {
+		t.Errorf("expected msg 'save failed', got %v", line["msg"])
+	}
+	if line["user_id"] != "user-123" {
+		t.Errorf("expected user_id field, got %v", line["user_id"])
+	}
+	if line["error"] != "boom" {
+		t.Errorf("expected error field 'boom', got %v", line["error"])
+	}
+}
+
+func TestLogger_WithContextAddsRequestID(t *testing.T) {
+	buf, entry := newTestLogger()
+	ctx := context.WithValue(context.Background(), "request-id", "req-1")
+
+	New(entry).WithContext(ctx).Info("hello", nil)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected JSON log line, got %q", buf.String())
+	}
+	if line["request_id"] != "req-1" {
+		t.Errorf("expected request_id 'req-1', got %v", line["request_id"])
+	}
+}
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	apperrors "github.com/asian-code/myapp-kubernetes/services/pkg/errors"
+	"github.com/asian-code/myapp-kubernetes/services/pkg/signing"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -79,6 +84,49 @@ func RequestID() func(http.Handler) http.Handler {
 	}
 }
 
+// BearerToken rejects requests whose Authorization header does not carry the
+// given static bearer token. It is meant for internal admin endpoints.
+func BearerToken(token string, logger *log.Entry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				apperrors.WriteError(w, logger, apperrors.Unauthorized("Invalid or missing admin token"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// VerifySignature rejects requests that are not signed by a key v knows, or
+// that are stale or replayed. Bodies larger than maxBytes are rejected; the
+// body is restored for the next handler.
+func VerifySignature(v *signing.Verifier, maxBytes int64, logger *log.Entry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			if err != nil {
+				apperrors.WriteError(w, logger, apperrors.BadRequest("Request body too large or unreadable"))
+				return
+			}
+
+			if err := v.Verify(r, body); err != nil {
+				logger.WithFields(log.Fields{
+					"method": r.Method,
+					"path":   r.URL.Path,
+					"remote": r.RemoteAddr,
+				}).WithError(err).Warn("Rejected unauthenticated service request")
+				apperrors.WriteError(w, logger, err)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
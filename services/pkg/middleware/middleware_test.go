@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/asian-code/myapp-kubernetes/services/pkg/signing"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestBearerToken(t *testing.T) {
+	l := log.New()
+	l.SetOutput(io.Discard)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		wantStatus int
+	}{
+		{"valid token", "s3cret", "Bearer s3cret", http.StatusNoContent},
+		{"wrong token", "s3cret", "Bearer other", http.StatusUnauthorized},
+		{"missing header", "s3cret", "", http.StatusUnauthorized},
+		{"wrong scheme", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+		{"unconfigured token", "", "Bearer ", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			BearerToken(tt.token, log.NewEntry(l))(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	l := log.New()
+	l.SetOutput(io.Discard)
+	key := signing.Key{ID: "k1", Secret: strings.Repeat("s", signing.MinSecretLength)}
+	mw := VerifySignature(signing.NewVerifier([]signing.Key{key}, signing.NewMemoryNonceCache()), 1<<20, log.NewEntry(l))
+
+	var got string
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	body := `{"type":"sleep"}`
+	req := httptest.NewRequest("POST", "/api/v1/ingest", strings.NewReader(body))
+	signing.NewSigner(key).Sign(req, []byte(body))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted || got != body {
+		t.Errorf("Expected signed request to reach handler with its body, got %d %q", rec.Code, got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/ingest", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unsigned request to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package signing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	currentKey  = Key{ID: "k2", Secret: strings.Repeat("b", MinSecretLength)}
+	previousKey = Key{ID: "k1", Secret: strings.Repeat("a", MinSecretLength)}
+)
+
+func sign(t *testing.T, key Key, method, target, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if err := NewSigner(key).Sign(req, []byte(body)); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	body := `{"type":"sleep"}`
+
+	tests := []struct {
+		name    string
+		req     func() *http.Request
+		body    string
+		wantErr string
+	}{
+		{
+			name: "current key",
+			req:  func() *http.Request { return sign(t, currentKey, "POST", "/api/v1/ingest", body) },
+			body: body,
+		},
+		{
+			name: "previous key during rotation",
+			req:  func() *http.Request { return sign(t, previousKey, "POST", "/api/v1/ingest", body) },
+			body: body,
+		},
+		{
+			name:    "unsigned",
+			req:     func() *http.Request { return httptest.NewRequest("POST", "/api/v1/ingest", nil) },
+			body:    body,
+			wantErr: "not signed",
+		},
+		{
+			name: "unknown key",
+			req: func() *http.Request {
+				return sign(t, Key{ID: "k0", Secret: currentKey.Secret}, "POST", "/api/v1/ingest", body)
+			},
+			body:    body,
+			wantErr: "Unknown signing key",
+		},
+		{
+			name:    "tampered body",
+			req:     func() *http.Request { return sign(t, currentKey, "POST", "/api/v1/ingest", body) },
+			body:    `{"type":"activity"}`,
+			wantErr: "Invalid request signature",
+		},
+		{
+			name: "different path",
+			req: func() *http.Request {
+				req := sign(t, currentKey, "GET", "/api/v1/metrics/sleep?user_id=a", "")
+				req.URL.RawQuery = "user_id=b"
+				return req
+			},
+			wantErr: "Invalid request signature",
+		},
+		{
+			name: "stale timestamp",
+			req: func() *http.Request {
+				s := NewSigner(currentKey)
+				s.now = func() time.Time { return time.Now().Add(-MaxSkew - time.Minute) }
+				req := httptest.NewRequest("POST", "/api/v1/ingest", strings.NewReader(body))
+				s.Sign(req, []byte(body))
+				return req
+			},
+			body:    body,
+			wantErr: "expired",
+		},
+		{
+			name: "forged timestamp",
+			req: func() *http.Request {
+				req := sign(t, currentKey, "POST", "/api/v1/ingest", body)
+				req.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix()+1, 10))
+				return req
+			},
+			body:    body,
+			wantErr: "Invalid request signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier([]Key{currentKey, previousKey}, NewMemoryNonceCache())
+			err := v.Verify(tt.req(), []byte(tt.body))
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Expected request to verify, got %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsReplay(t *testing.T) {
+	v := NewVerifier([]Key{currentKey}, NewMemoryNonceCache())
+	req := sign(t, currentKey, "POST", "/api/v1/ingest", "{}")
+
+	if err := v.Verify(req, []byte("{}")); err != nil {
+		t.Fatalf("Expected first request to verify, got %v", err)
+	}
+	if err := v.Verify(req, []byte("{}")); err == nil || !strings.Contains(err.Error(), "already been used") {
+		t.Errorf("Expected replay to be rejected, got %v", err)
+	}
+}
+
+func TestMemoryNonceCacheExpires(t *testing.T) {
+	c := NewMemoryNonceCache()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	if fresh, _ := c.StoreNonce(context.Background(), "n", now.Add(time.Minute)); !fresh {
+		t.Fatal("Expected new nonce to be fresh")
+	}
+	if fresh, _ := c.StoreNonce(context.Background(), "n", now.Add(time.Minute)); fresh {
+		t.Error("Expected repeated nonce to be rejected")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if fresh, _ := c.StoreNonce(context.Background(), "n", now.Add(time.Minute)); !fresh {
+		t.Error("Expected expired nonce to be forgotten")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := strings.Repeat("s", MinSecretLength)
+
+	keys, err := ParseKeys("new:" + secret + ", old:" + secret)
+	if err != nil {
+		t.Fatalf("ParseKeys failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "new" || keys[1].ID != "old" {
+		t.Errorf("Unexpected keys %+v", keys)
+	}
+
+	for _, bad := range []string{"", "nosecret", ":" + secret, "k:short", "k:" + secret + ",k:" + secret} {
+		if _, err := ParseKeys(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
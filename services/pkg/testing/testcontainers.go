@@ -123,40 +123,331 @@ func (pc *PostgresContainer) RunMigrations(ctx context.Context, pool *pgxpool.Po
 		// Sleep metrics table
 		`CREATE TABLE IF NOT EXISTS sleep_metrics (
 			id SERIAL PRIMARY KEY,
-			oura_id VARCHAR(255) UNIQUE NOT NULL,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
 			day DATE NOT NULL,
 			score INTEGER,
 			duration INTEGER,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
 		)`,
-		`CREATE INDEX IF NOT EXISTS idx_sleep_day ON sleep_metrics(day)`,
+		`CREATE INDEX IF NOT EXISTS idx_sleep_user_day ON sleep_metrics(user_id, day DESC)`,
 
 		// Activity metrics table
 		`CREATE TABLE IF NOT EXISTS activity_metrics (
 			id SERIAL PRIMARY KEY,
-			oura_id VARCHAR(255) UNIQUE NOT NULL,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
 			day DATE NOT NULL,
 			score INTEGER,
 			active_calories INTEGER,
 			steps INTEGER,
 			medium_activity_minutes INTEGER,
 			high_activity_minutes INTEGER,
+			day_start TIMESTAMPTZ,
+			class_5_min TEXT,
+			met_interval DOUBLE PRECISION,
+			met_timestamp TIMESTAMPTZ,
+			met_items DOUBLE PRECISION[],
+			non_wear_time INTEGER,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
 		)`,
-		`CREATE INDEX IF NOT EXISTS idx_activity_day ON activity_metrics(day)`,
+		`CREATE INDEX IF NOT EXISTS idx_activity_user_day ON activity_metrics(user_id, day DESC)`,
 
 		// Readiness metrics table
 		`CREATE TABLE IF NOT EXISTS readiness_metrics (
 			id SERIAL PRIMARY KEY,
-			oura_id VARCHAR(255) UNIQUE NOT NULL,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
 			day DATE NOT NULL,
 			score INTEGER,
+			activity_balance INTEGER,
+			body_temperature INTEGER,
+			hrv_balance INTEGER,
+			previous_day_activity INTEGER,
+			previous_night INTEGER,
+			recovery_index INTEGER,
+			resting_heart_rate INTEGER,
+			sleep_balance INTEGER,
+			temperature_deviation DOUBLE PRECISION,
+			temperature_trend_deviation DOUBLE PRECISION,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_readiness_user_day ON readiness_metrics(user_id, day DESC)`,
+
+		// Backfill progress table
+		`CREATE TABLE IF NOT EXISTS backfill_progress (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			data_type VARCHAR(50) NOT NULL,
+			range_start DATE NOT NULL,
+			range_end DATE NOT NULL,
+			completed_through DATE NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, data_type, range_start)
+		)`,
+
+		// Sync state table
+		`CREATE TABLE IF NOT EXISTS sync_state (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			provider VARCHAR(50) NOT NULL DEFAULT 'oura',
+			data_type VARCHAR(50) NOT NULL,
+			last_synced_day DATE NOT NULL,
+			last_cursor TEXT,
+			cursor_start DATE,
+			cursor_end DATE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, provider, data_type)
+		)`,
+
+		// Ingest queue table
+		`CREATE TABLE IF NOT EXISTS ingest_queue (
+			id BIGSERIAL PRIMARY KEY,
+			envelope JSONB NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			available_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
-		`CREATE INDEX IF NOT EXISTS idx_readiness_day ON readiness_metrics(day)`,
+		`CREATE INDEX IF NOT EXISTS idx_ingest_queue_available ON ingest_queue(available_at, id)`,
+
+		// Dead letters table
+		`CREATE TABLE IF NOT EXISTS dead_letters (
+			id BIGSERIAL PRIMARY KEY,
+			source VARCHAR(20) NOT NULL,
+			data_type VARCHAR(50),
+			user_id UUID,
+			payload BYTEA NOT NULL,
+			payload_hash CHAR(64) NOT NULL,
+			error TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 1,
+			first_failed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_failed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT dead_letters_payload_hash_key UNIQUE (payload_hash)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_dead_letters_last_failed_at ON dead_letters(last_failed_at DESC)`,
+
+		// Idempotency keys table
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			endpoint VARCHAR(100) NOT NULL,
+			key VARCHAR(255) NOT NULL,
+			fingerprint CHAR(64) NOT NULL,
+			status_code INTEGER,
+			content_type VARCHAR(100),
+			response BYTEA,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (endpoint, key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at)`,
+
+		// Request nonces table
+		`CREATE TABLE IF NOT EXISTS request_nonces (
+			nonce VARCHAR(200) PRIMARY KEY,
+			expires_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_request_nonces_expires_at ON request_nonces(expires_at)`,
+
+		// Raw documents table
+		`CREATE TABLE IF NOT EXISTS raw_documents (
+			provider VARCHAR(50) NOT NULL,
+			data_type VARCHAR(50) NOT NULL,
+			upstream_id VARCHAR(255) NOT NULL,
+			fetched_at TIMESTAMP NOT NULL,
+			last_seen_at TIMESTAMP NOT NULL,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			content_hash CHAR(64) NOT NULL,
+			document JSONB NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, provider, data_type, upstream_id, fetched_at),
+			CONSTRAINT raw_documents_content_key UNIQUE (user_id, provider, data_type, upstream_id, content_hash)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_raw_documents_user_type_day ON raw_documents(user_id, data_type, day)`,
+
+		// Sleep periods table
+		`CREATE TABLE IF NOT EXISTS sleep_periods (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			type VARCHAR(50),
+			bedtime_start TIMESTAMPTZ NOT NULL,
+			bedtime_end TIMESTAMPTZ NOT NULL,
+			deep_sleep_duration INTEGER,
+			light_sleep_duration INTEGER,
+			rem_sleep_duration INTEGER,
+			awake_time INTEGER,
+			efficiency INTEGER,
+			latency INTEGER,
+			lowest_heart_rate INTEGER,
+			average_hrv INTEGER,
+			sleep_phase_5_min TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sleep_periods_user_day ON sleep_periods(user_id, day DESC)`,
+
+		// Heart rate table, partitioned by month
+		`CREATE TABLE IF NOT EXISTS heartrate (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			timestamp TIMESTAMPTZ NOT NULL,
+			bpm SMALLINT NOT NULL,
+			source VARCHAR(20),
+			PRIMARY KEY (user_id, timestamp)
+		) PARTITION BY RANGE (timestamp)`,
+		`CREATE OR REPLACE FUNCTION ensure_monthly_partition(parent TEXT, ts TIMESTAMPTZ) RETURNS VOID AS $$
+		DECLARE
+			month_start TIMESTAMP := date_trunc('month', ts AT TIME ZONE 'UTC');
+			partition_name TEXT := parent || '_' || to_char(ts AT TIME ZONE 'UTC', 'YYYY_MM');
+		BEGIN
+			IF to_regclass(partition_name) IS NOT NULL THEN
+				RETURN;
+			END IF;
+			PERFORM pg_advisory_xact_lock(hashtext(partition_name));
+			EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+				partition_name, parent, month_start AT TIME ZONE 'UTC', (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC');
+		END;
+		$$ LANGUAGE plpgsql`,
+
+		// Workouts table
+		`CREATE TABLE IF NOT EXISTS workouts (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			activity VARCHAR(100) NOT NULL,
+			intensity VARCHAR(20),
+			label VARCHAR(255),
+			source VARCHAR(50),
+			start_datetime TIMESTAMPTZ NOT NULL,
+			end_datetime TIMESTAMPTZ NOT NULL,
+			calories DOUBLE PRECISION,
+			distance DOUBLE PRECISION,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_workouts_user_day ON workouts(user_id, day DESC)`,
+
+		// Sessions table
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			type VARCHAR(50) NOT NULL,
+			mood VARCHAR(20),
+			start_datetime TIMESTAMPTZ NOT NULL,
+			end_datetime TIMESTAMPTZ NOT NULL,
+			heart_rate_average DOUBLE PRECISION,
+			heart_rate_min DOUBLE PRECISION,
+			heart_rate_max DOUBLE PRECISION,
+			hrv_average DOUBLE PRECISION,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_user_day ON sessions(user_id, day DESC)`,
+		`CREATE TABLE IF NOT EXISTS spo2_metrics (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			spo2_average DOUBLE PRECISION,
+			breathing_disturbance_index INTEGER,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_spo2_user_day ON spo2_metrics(user_id, day DESC)`,
+		`CREATE TABLE IF NOT EXISTS stress_metrics (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			stress_high INTEGER,
+			recovery_high INTEGER,
+			day_summary VARCHAR(20),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_stress_user_day ON stress_metrics(user_id, day DESC)`,
+		`CREATE TABLE IF NOT EXISTS resilience_metrics (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			level VARCHAR(20) NOT NULL,
+			sleep_recovery DOUBLE PRECISION,
+			daytime_recovery DOUBLE PRECISION,
+			stress DOUBLE PRECISION,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_resilience_user_day ON resilience_metrics(user_id, day DESC)`,
+		`CREATE TABLE IF NOT EXISTS vo2_max_metrics (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			vo2_max DOUBLE PRECISION,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_vo2_max_user_day ON vo2_max_metrics(user_id, day DESC)`,
+		`CREATE TABLE IF NOT EXISTS cardiovascular_age_metrics (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			vascular_age INTEGER,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_cardiovascular_age_user_day ON cardiovascular_age_metrics(user_id, day DESC)`,
+		`CREATE TABLE IF NOT EXISTS tags (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			tag_type_code VARCHAR(100),
+			custom_name VARCHAR(255),
+			comment TEXT,
+			start_time TIMESTAMPTZ NOT NULL,
+			end_time TIMESTAMPTZ,
+			end_day DATE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_tags_user_day ON tags(user_id, day DESC)`,
+		`CREATE TABLE IF NOT EXISTS rest_mode_periods (
+			id SERIAL PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			oura_id VARCHAR(255) NOT NULL,
+			day DATE NOT NULL,
+			start_time TIMESTAMPTZ,
+			end_time TIMESTAMPTZ,
+			end_day DATE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, oura_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_rest_mode_periods_user_day ON rest_mode_periods(user_id, day DESC)`,
 	}
 
 	for _, migration := range migrations {
@@ -28,12 +28,13 @@ type Metrics struct {
 	ProcessingDuration     prometheus.Histogram
 	ProcessingErrors       prometheus.CounterVec
 	ProcessingQueueDepth   prometheus.Gauge
+	DeadLetterBacklog      prometheus.Gauge
 	LastProcessedTimestamp prometheus.Gauge
 
 	// Oura Collector metrics
 	CollectionRunsTotal   prometheus.Counter
 	CollectionDuration    prometheus.Histogram
-	DataPointsCollected   prometheus.Counter
+	DataPointsCollected   prometheus.CounterVec
 	CollectionErrors      prometheus.CounterVec
 	LastSuccessfulRunTime prometheus.Gauge
 }
@@ -150,6 +151,13 @@ func New(serviceName string) *Metrics {
 					"service": serviceName,
 				},
 			}),
+			DeadLetterBacklog: promauto.NewGauge(prometheus.GaugeOpts{
+				Name: "dead_letter_backlog",
+				Help: "Records waiting in the dead-letter store",
+				ConstLabels: map[string]string{
+					"service": serviceName,
+				},
+			}),
 			LastProcessedTimestamp: promauto.NewGauge(prometheus.GaugeOpts{
 				Name: "last_processed_timestamp_seconds",
 				Help: "Timestamp of last processed record",
@@ -174,22 +182,25 @@ func New(serviceName string) *Metrics {
 					"service": serviceName,
 				},
 			}),
-			DataPointsCollected: promauto.NewCounter(prometheus.CounterOpts{
-				Name: "data_points_collected_total",
-				Help: "Total data points collected from Oura API",
-				ConstLabels: map[string]string{
-					"service": serviceName,
+			DataPointsCollected: *promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "data_points_collected_total",
+					Help: "Total data points collected from Oura API by user and data type",
+					ConstLabels: map[string]string{
+						"service": serviceName,
+					},
 				},
-			}),
+				[]string{"user_id", "data_type"},
+			),
 			CollectionErrors: *promauto.NewCounterVec(
 				prometheus.CounterOpts{
 					Name: "collector_errors_total",
-					Help: "Total collector errors by data type and error type",
+					Help: "Total collector errors by user, data type and error type",
 					ConstLabels: map[string]string{
 						"service": serviceName,
 					},
 				},
-				[]string{"data_type", "error_type"},
+				[]string{"user_id", "data_type", "error_type"},
 			),
 			LastSuccessfulRunTime: promauto.NewGauge(prometheus.GaugeOpts{
 				Name: "collector_last_successful_run_timestamp_seconds",
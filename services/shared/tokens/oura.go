@@ -0,0 +1,81 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OuraTokenURL is Oura's OAuth2 token endpoint.
+const OuraTokenURL = "https://api.ouraring.com/oauth/token"
+
+// OuraRefresher refreshes tokens against Oura's OAuth2 token endpoint.
+type OuraRefresher struct {
+	clientID     string
+	clientSecret string
+	tokenURL     string
+	client       *http.Client
+}
+
+func NewOuraRefresher(clientID, clientSecret string) *OuraRefresher {
+	return &OuraRefresher{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		tokenURL:     OuraTokenURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func (r *OuraRefresher) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+	data.Set("client_id", r.clientID)
+	data.Set("client_secret", r.clientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp tokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("token refresh response has no access token")
+	}
+
+	return &Token{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}, nil
+}
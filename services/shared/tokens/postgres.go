@@ -0,0 +1,79 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps tokens in the oauth_tokens table.
+type PostgresStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, userID, provider string) (*Token, error) {
+	query := `
+		SELECT access_token, refresh_token, expires_at
+		FROM oauth_tokens
+		WHERE user_id = $1 AND provider = $2
+	`
+	return scanToken(s.db.QueryRow(ctx, query, userID, provider))
+}
+
+// Update holds a FOR UPDATE lock on the token row for the duration of fn, so
+// only one refresher at a time can spend the current refresh token.
+func (s *PostgresStore) Update(ctx context.Context, userID, provider string, fn func(current *Token) (*Token, error)) (*Token, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT access_token, refresh_token, expires_at
+		FROM oauth_tokens
+		WHERE user_id = $1 AND provider = $2
+		FOR UPDATE
+	`
+	current, err := scanToken(tx.QueryRow(ctx, query, userID, provider))
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := fn(current)
+	if err != nil {
+		return nil, err
+	}
+	if next == current {
+		return current, tx.Commit(ctx)
+	}
+
+	update := `
+		UPDATE oauth_tokens
+		SET access_token = $1, refresh_token = $2, expires_at = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $4 AND provider = $5
+	`
+	if _, err := tx.Exec(ctx, update, next.AccessToken, next.RefreshToken, next.ExpiresAt, userID, provider); err != nil {
+		return nil, err
+	}
+
+	return next, tx.Commit(ctx)
+}
+
+func scanToken(row pgx.Row) (*Token, error) {
+	var t Token
+	err := row.Scan(&t.AccessToken, &t.RefreshToken, &t.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
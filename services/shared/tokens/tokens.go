@@ -0,0 +1,131 @@
+// Package tokens provides OAuth access tokens that refresh themselves ahead of
+// expiry. Refreshes are serialised through a row lock on oauth_tokens so that
+// concurrent refreshers never overwrite each other's rotated refresh tokens.
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrNoToken is returned when the user has not authorized the provider.
+var ErrNoToken = errors.New("no OAuth token found for user")
+
+// Token is an OAuth token pair.
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// expiresWithin reports whether the token expires within d of now.
+func (t *Token) expiresWithin(d time.Duration) bool {
+	return time.Now().Add(d).After(t.ExpiresAt)
+}
+
+// Refresher exchanges a refresh token for a new token pair with the provider.
+type Refresher interface {
+	Refresh(ctx context.Context, refreshToken string) (*Token, error)
+}
+
+// Store loads tokens and applies refreshes atomically.
+type Store interface {
+	// Get returns the stored token, or ErrNoToken.
+	Get(ctx context.Context, userID, provider string) (*Token, error)
+	// Update locks the stored token, passes it to fn and saves whatever fn
+	// returns before releasing the lock. If fn returns the token it was given
+	// nothing is written.
+	Update(ctx context.Context, userID, provider string, fn func(current *Token) (*Token, error)) (*Token, error)
+}
+
+// Source hands out a valid access token for one user and provider.
+type Source struct {
+	userID    string
+	provider  string
+	store     Store
+	refresher Refresher
+	skew      time.Duration
+	logger    *log.Entry
+
+	mu      sync.Mutex
+	current *Token
+	stale   string // access token rejected by the provider
+}
+
+// NewSource creates a token source that refreshes tokens expiring within skew.
+func NewSource(userID, provider string, store Store, refresher Refresher, skew time.Duration, logger *log.Entry) *Source {
+	return &Source{
+		userID:    userID,
+		provider:  provider,
+		store:     store,
+		refresher: refresher,
+		skew:      skew,
+		logger:    logger,
+	}
+}
+
+// Token returns an access token that is valid for at least the configured
+// skew, refreshing it first if necessary.
+func (s *Source) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil {
+		token, err := s.store.Get(ctx, s.userID, s.provider)
+		if err != nil {
+			return "", err
+		}
+		s.current = token
+	}
+
+	if !s.needsRefresh(s.current) {
+		return s.current.AccessToken, nil
+	}
+
+	token, err := s.store.Update(ctx, s.userID, s.provider, func(locked *Token) (*Token, error) {
+		// Another refresher may have rotated the token while we waited for
+		// the lock; its refresh token is the only valid one now
+		if !s.needsRefresh(locked) {
+			return locked, nil
+		}
+
+		refreshed, err := s.refresher.Refresh(ctx, locked.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if refreshed.RefreshToken == "" {
+			refreshed.RefreshToken = locked.RefreshToken
+		}
+
+		s.logger.WithField("user_id", s.userID).
+			WithField("provider", s.provider).
+			WithField("expires_at", refreshed.ExpiresAt).
+			Info("Refreshed OAuth token")
+		return refreshed, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("refresh %s token: %w", s.provider, err)
+	}
+
+	s.current = token
+	s.stale = ""
+	return token.AccessToken, nil
+}
+
+// Invalidate marks an access token the provider rejected so the next call to
+// Token refreshes it, unless it has already been replaced.
+func (s *Source) Invalidate(accessToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stale = accessToken
+}
+
+func (s *Source) needsRefresh(t *Token) bool {
+	return t.expiresWithin(s.skew) || (s.stale != "" && t.AccessToken == s.stale)
+}
@@ -0,0 +1,200 @@
+package tokens
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// memoryStore mimics PostgresStore's row lock with a mutex.
+type memoryStore struct {
+	lock  sync.Mutex
+	token *Token
+}
+
+func (m *memoryStore) Get(ctx context.Context, userID, provider string) (*Token, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.token == nil {
+		return nil, ErrNoToken
+	}
+	t := *m.token
+	return &t, nil
+}
+
+func (m *memoryStore) Update(ctx context.Context, userID, provider string, fn func(current *Token) (*Token, error)) (*Token, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	current := *m.token
+	next, err := fn(&current)
+	if err != nil {
+		return nil, err
+	}
+	m.token = next
+	return next, nil
+}
+
+// rotatingRefresher issues a new token pair and rejects refresh tokens that
+// have already been spent, like Oura does.
+type rotatingRefresher struct {
+	mu    sync.Mutex
+	calls int
+	spent map[string]bool
+}
+
+func (r *rotatingRefresher) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.spent == nil {
+		r.spent = map[string]bool{}
+	}
+	if r.spent[refreshToken] {
+		return nil, fmt.Errorf("refresh token %s already used", refreshToken)
+	}
+	r.spent[refreshToken] = true
+	r.calls++
+	return &Token{
+		AccessToken:  fmt.Sprintf("access-%d", r.calls),
+		RefreshToken: fmt.Sprintf("refresh-%d", r.calls),
+		ExpiresAt:    time.Now().Add(24 * time.Hour),
+	}, nil
+}
+
+func newTestSource(store Store, refresher Refresher) *Source {
+	return NewSource("user-1", "oura", store, refresher, 5*time.Minute, log.NewEntry(log.New()))
+}
+
+func TestSourceUsesValidToken(t *testing.T) {
+	store := &memoryStore{token: &Token{AccessToken: "access-0", RefreshToken: "refresh-0", ExpiresAt: time.Now().Add(time.Hour)}}
+	refresher := &rotatingRefresher{}
+
+	token, err := newTestSource(store, refresher).Token(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token != "access-0" {
+		t.Errorf("Expected stored token, got %s", token)
+	}
+	if refresher.calls != 0 {
+		t.Errorf("Expected no refresh, got %d", refresher.calls)
+	}
+}
+
+func TestSourceRefreshesAheadOfExpiry(t *testing.T) {
+	store := &memoryStore{token: &Token{AccessToken: "access-0", RefreshToken: "refresh-0", ExpiresAt: time.Now().Add(time.Minute)}}
+	refresher := &rotatingRefresher{}
+
+	token, err := newTestSource(store, refresher).Token(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token != "access-1" {
+		t.Errorf("Expected refreshed token, got %s", token)
+	}
+	if store.token.RefreshToken != "refresh-1" {
+		t.Errorf("Expected rotated refresh token to be saved, got %s", store.token.RefreshToken)
+	}
+}
+
+func TestSourceConcurrentRefreshersDoNotClobber(t *testing.T) {
+	store := &memoryStore{token: &Token{AccessToken: "access-0", RefreshToken: "refresh-0", ExpiresAt: time.Now().Add(-time.Minute)}}
+	refresher := &rotatingRefresher{}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Separate sources model separate collector processes
+			_, errs[i] = newTestSource(store, refresher).Token(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Refresher %d failed: %v", i, err)
+		}
+	}
+	if refresher.calls != 1 {
+		t.Errorf("Expected a single refresh, got %d", refresher.calls)
+	}
+}
+
+func TestSourceInvalidateForcesRefresh(t *testing.T) {
+	store := &memoryStore{token: &Token{AccessToken: "access-0", RefreshToken: "refresh-0", ExpiresAt: time.Now().Add(time.Hour)}}
+	refresher := &rotatingRefresher{}
+	source := newTestSource(store, refresher)
+
+	if _, err := source.Token(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	source.Invalidate("access-0")
+
+	token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token != "access-1" {
+		t.Errorf("Expected refreshed token after invalidation, got %s", token)
+	}
+}
+
+func TestSourceNoToken(t *testing.T) {
+	_, err := newTestSource(&memoryStore{}, &rotatingRefresher{}).Token(context.Background())
+	if err != ErrNoToken {
+		t.Errorf("Expected ErrNoToken, got %v", err)
+	}
+}
+
+func TestOuraRefresher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "refresh-0" {
+			t.Errorf("Unexpected form: %v", r.Form)
+		}
+		if r.Form.Get("client_id") != "id" || r.Form.Get("client_secret") != "secret" {
+			t.Errorf("Expected client credentials, got %v", r.Form)
+		}
+		fmt.Fprint(w, `{"access_token":"access-1","refresh_token":"refresh-1","expires_in":86400}`)
+	}))
+	defer server.Close()
+
+	refresher := NewOuraRefresher("id", "secret")
+	refresher.tokenURL = server.URL
+
+	token, err := refresher.Refresh(context.Background(), "refresh-0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" {
+		t.Errorf("Unexpected token: %+v", token)
+	}
+	if token.ExpiresAt.Before(time.Now().Add(23 * time.Hour)) {
+		t.Errorf("Expected expiry about a day out, got %v", token.ExpiresAt)
+	}
+}
+
+func TestOuraRefresherRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant"}`)
+	}))
+	defer server.Close()
+
+	refresher := NewOuraRefresher("id", "secret")
+	refresher.tokenURL = server.URL
+
+	if _, err := refresher.Refresh(context.Background(), "refresh-0"); err == nil {
+		t.Error("Expected error for rejected refresh token")
+	}
+}